@@ -10,7 +10,44 @@ type FileUsecase interface {
 	RegisterUpload(context.Context, *dto.RegisterUploadRequest) (*dto.RegisterUploadResponse, error)
 	CreatePresignedURL(context.Context, *dto.CreatePresignedURLRequest) (*dto.CreatePresignedURLResponse, error)
 	ChangeRefCount(context.Context, *dto.ChangeRefcountRequest) (*dto.ChangeRefcountResponse, error)
+	AddReference(context.Context, *dto.AddReferenceRequest) (*dto.AddReferenceResponse, error)
+	RemoveReference(context.Context, *dto.RemoveReferenceRequest) (*dto.RemoveReferenceResponse, error)
+	ListReferences(context.Context, *dto.ListReferencesRequest) (*dto.ListReferencesResponse, error)
 
 	Upload(context.Context, *dto.UploadRequest) (*dto.UploadResponse, error)
+	InstantUpload(context.Context, *dto.InstantUploadRequest) (*dto.UploadResponse, error)
+	FinalizeUpload(context.Context, *dto.FinalizeUploadRequest) (*dto.UploadResponse, error)
 	RetrieveFileToken(context.Context, *dto.RetrieveFileTokenRequest) (*dto.RetrieveFileTokenResponse, error)
+	VerifyFileToken(context.Context, *dto.VerifyFileTokenRequest) (*dto.VerifyFileTokenResponse, error)
+	RevokeFileToken(context.Context, *dto.RevokeFileTokenRequest) (*dto.RevokeFileTokenResponse, error)
+	GetFileInfo(context.Context, *dto.GetFileInfoRequest) (*dto.GetFileInfoResponse, error)
+	GetOwnership(context.Context, *dto.GetOwnershipRequest) (*dto.GetOwnershipResponse, error)
+	BatchGetOwnerships(context.Context, *dto.BatchGetOwnershipsRequest) (*dto.BatchGetOwnershipsResponse, error)
+	ListOwnerships(context.Context, *dto.ListOwnershipsRequest) (*dto.ListOwnershipsResponse, error)
+	UpdateOwnership(context.Context, *dto.UpdateOwnershipRequest) (*dto.UpdateOwnershipResponse, error)
+	DeleteOwnership(context.Context, *dto.DeleteOwnershipRequest) (*dto.DeleteOwnershipResponse, error)
+	Download(context.Context, *dto.DownloadRequest) (*dto.DownloadResponse, error)
+	DownloadByFileToken(context.Context, *dto.DownloadByFileTokenRequest) (*dto.DownloadResponse, error)
+
+	CreateResumableUpload(context.Context, *dto.CreateResumableUploadRequest) (*dto.CreateResumableUploadResponse, error)
+	GetResumableUpload(context.Context, *dto.GetResumableUploadRequest) (*dto.GetResumableUploadResponse, error)
+	AppendResumableUpload(context.Context, *dto.AppendResumableUploadRequest) (*dto.AppendResumableUploadResponse, error)
+	TerminateResumableUpload(context.Context, *dto.TerminateResumableUploadRequest) (*dto.TerminateResumableUploadResponse, error)
+}
+
+type TransformUsecase interface {
+	CreateTransformURL(context.Context, *dto.CreateTransformURLRequest) (*dto.CreateTransformURLResponse, error)
+	Transform(context.Context, *dto.TransformRequest) (*dto.DownloadResponse, error)
+}
+
+type JanitorUsecase interface {
+	CleanUp(context.Context, *dto.CleanUpRequest) (*dto.CleanUpResponse, error)
+}
+
+type RelocationUsecase interface {
+	Relocate(context.Context, *dto.RelocateRequest) (*dto.RelocateResponse, error)
+}
+
+type RelayUsecase interface {
+	Relay(context.Context, *dto.RelayRequest) (*dto.RelayResponse, error)
 }
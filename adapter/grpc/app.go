@@ -18,9 +18,20 @@ func App(config *config.Config, usecases *wiring.Usecases) *grpc.Server {
 				WithAuthenticate().
 				Interceptor(config),
 		),
+		// Streams last as long as their content is transferred, so they have
+		// no timeout.
+		grpc.StreamInterceptor(
+			NewStreamInterceptor(
+				interceptor.NewUnaryInterceptor().
+					WithBasicContext().
+					WithLogRoundTripTime().
+					WithAuthenticate().
+					Interceptor(config),
+			),
+		),
 	)
 
-	service.RegisterFileServer(s, NewFileServer(usecases.FileUsecase))
+	service.RegisterFileServer(s, NewFileServer(usecases.FileUsecase, usecases.TransformUsecase))
 
 	return s
 }
@@ -0,0 +1,39 @@
+//go:build proto_next
+
+package conversion
+
+import (
+	ucdto "github.com/todennus/file-service/usecase/dto"
+	pbdto "github.com/todennus/proto/gen/service/dto"
+	"github.com/xybor-x/snowflake"
+)
+
+func NewUsecaseGetFileInfoRequest(req *pbdto.FileGetInfoRequest) *ucdto.GetFileInfoRequest {
+	return &ucdto.GetFileInfoRequest{
+		OwnershipID: snowflake.ParseInt64(req.GetOwnershipId()),
+	}
+}
+
+func NewPbGetFileInfoResponse(resp *ucdto.GetFileInfoResponse) *pbdto.FileGetInfoResponse {
+	if resp == nil {
+		return nil
+	}
+
+	pbresp := &pbdto.FileGetInfoResponse{
+		FileId:    resp.FileID,
+		Bucket:    resp.Bucket,
+		Type:      resp.Type,
+		Size:      int64(resp.Size),
+		CreatedAt: resp.CreatedAt.UnixMilli(),
+		OwnerId:   resp.OwnerID.Int64(),
+		Refcount:  int64(resp.RefCount),
+	}
+
+	if resp.Image != nil {
+		pbresp.ImageFormat = resp.Image.Format
+		pbresp.ImageWidth = int64(resp.Image.Width)
+		pbresp.ImageHeight = int64(resp.Image.Height)
+	}
+
+	return pbresp
+}
@@ -0,0 +1,50 @@
+//go:build proto_next
+
+package conversion
+
+import (
+	"time"
+
+	ucdto "github.com/todennus/file-service/usecase/dto"
+	pbdto "github.com/todennus/proto/gen/service/dto"
+	"github.com/xybor-x/snowflake"
+)
+
+func NewUsecaseRegisterUploadRequest(req *pbdto.FileRegisterUploadRequest) *ucdto.RegisterUploadRequest {
+	return &ucdto.RegisterUploadRequest{
+		UserID:        snowflake.ParseInt64(req.GetUserId()),
+		MaxSize:       req.GetMaxSize(),
+		AllowedTypes:  req.GetAllowedTypes(),
+		DeniedTypes:   req.GetDeniedTypes(),
+		Direct:        req.GetDirect(),
+		StripMetadata: req.GetStripMetadata(),
+		SanitizeSVG:   req.GetSanitizeSvg(),
+		MaxWidth:      int(req.GetMaxWidth()),
+		MaxHeight:     int(req.GetMaxHeight()),
+		MaxMegapixels: req.GetMaxMegapixels(),
+		MaxFrames:     int(req.GetMaxFrames()),
+		Purpose:       req.GetPurpose(),
+	}
+}
+
+func NewPbFileRegisterUploadResponse(resp *ucdto.RegisterUploadResponse) *pbdto.FileRegisterUploadResponse {
+	if resp == nil {
+		return nil
+	}
+
+	return &pbdto.FileRegisterUploadResponse{
+		UploadToken:           resp.UploadToken,
+		PresignedPutUrl:       resp.PresignedPutURL,
+		PresignedPostUrl:      resp.PresignedPostURL,
+		PresignedPostFormData: resp.PresignedPostFormData,
+	}
+}
+
+func NewUsecaseCreatePresignedURLRequest(req *pbdto.FileCreatePresignedURLRequest) *ucdto.CreatePresignedURLRequest {
+	return &ucdto.CreatePresignedURLRequest{
+		FileID:      req.GetFileId(),
+		OwnershipID: snowflake.ParseInt64(req.GetOwnershipId()),
+		Variant:     req.GetVariant(),
+		Expiration:  time.Duration(req.GetExpiration()) * time.Second,
+	}
+}
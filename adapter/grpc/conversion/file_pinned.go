@@ -0,0 +1,40 @@
+//go:build !proto_next
+
+package conversion
+
+import (
+	"time"
+
+	ucdto "github.com/todennus/file-service/usecase/dto"
+	pbdto "github.com/todennus/proto/gen/service/dto"
+	"github.com/xybor-x/snowflake"
+)
+
+// The pinned proto release only defines the fields below, the upload options
+// added since are mapped by the proto_next build.
+
+func NewUsecaseRegisterUploadRequest(req *pbdto.FileRegisterUploadRequest) *ucdto.RegisterUploadRequest {
+	return &ucdto.RegisterUploadRequest{
+		UserID:       snowflake.ParseInt64(req.GetUserId()),
+		MaxSize:      req.GetMaxSize(),
+		AllowedTypes: req.GetAllowedTypes(),
+	}
+}
+
+func NewPbFileRegisterUploadResponse(resp *ucdto.RegisterUploadResponse) *pbdto.FileRegisterUploadResponse {
+	if resp == nil {
+		return nil
+	}
+
+	return &pbdto.FileRegisterUploadResponse{
+		UploadToken: resp.UploadToken,
+	}
+}
+
+func NewUsecaseCreatePresignedURLRequest(req *pbdto.FileCreatePresignedURLRequest) *ucdto.CreatePresignedURLRequest {
+	return &ucdto.CreatePresignedURLRequest{
+		FileID:      req.GetFileId(),
+		OwnershipID: snowflake.ParseInt64(req.GetOwnershipId()),
+		Expiration:  time.Duration(req.GetExpiration()) * time.Second,
+	}
+}
@@ -0,0 +1,79 @@
+//go:build proto_next
+
+package conversion
+
+import (
+	ucdto "github.com/todennus/file-service/usecase/dto"
+	pbdto "github.com/todennus/proto/gen/service/dto"
+	"github.com/xybor-x/snowflake"
+)
+
+func NewPbFileOwnership(ownership *ucdto.OwnershipInfo) *pbdto.FileOwnership {
+	if ownership == nil {
+		return nil
+	}
+
+	pbownership := &pbdto.FileOwnership{
+		OwnershipId: ownership.OwnershipID.Int64(),
+		OwnerId:     ownership.OwnerID.Int64(),
+		Refcount:    int64(ownership.RefCount),
+		DisplayName: ownership.DisplayName,
+		Description: ownership.Description,
+		UpdatedAt:   ownership.UpdatedAt.UnixMilli(),
+		FileId:      ownership.FileID,
+		Bucket:      ownership.Bucket,
+		Type:        ownership.Type,
+		Size:        int64(ownership.Size),
+		CreatedAt:   ownership.CreatedAt.UnixMilli(),
+	}
+
+	if ownership.Image != nil {
+		pbownership.ImageFormat = ownership.Image.Format
+		pbownership.ImageWidth = int64(ownership.Image.Width)
+		pbownership.ImageHeight = int64(ownership.Image.Height)
+	}
+
+	return pbownership
+}
+
+func NewUsecaseGetOwnershipRequest(req *pbdto.FileGetOwnershipRequest) *ucdto.GetOwnershipRequest {
+	return &ucdto.GetOwnershipRequest{
+		OwnershipID: snowflake.ParseInt64(req.GetOwnershipId()),
+	}
+}
+
+func NewPbGetOwnershipResponse(resp *ucdto.GetOwnershipResponse) *pbdto.FileGetOwnershipResponse {
+	if resp == nil {
+		return nil
+	}
+
+	return &pbdto.FileGetOwnershipResponse{
+		Ownership: NewPbFileOwnership(resp.Ownership),
+	}
+}
+
+func NewUsecaseBatchGetOwnershipsRequest(req *pbdto.FileBatchGetOwnershipsRequest) *ucdto.BatchGetOwnershipsRequest {
+	ids := make([]snowflake.ID, 0, len(req.GetOwnershipIds()))
+	for _, id := range req.GetOwnershipIds() {
+		ids = append(ids, snowflake.ParseInt64(id))
+	}
+
+	return &ucdto.BatchGetOwnershipsRequest{
+		OwnershipIDs: ids,
+	}
+}
+
+func NewPbBatchGetOwnershipsResponse(resp *ucdto.BatchGetOwnershipsResponse) *pbdto.FileBatchGetOwnershipsResponse {
+	if resp == nil {
+		return nil
+	}
+
+	ownerships := make([]*pbdto.FileOwnership, 0, len(resp.Ownerships))
+	for i := range resp.Ownerships {
+		ownerships = append(ownerships, NewPbFileOwnership(resp.Ownerships[i]))
+	}
+
+	return &pbdto.FileBatchGetOwnershipsResponse{
+		Ownerships: ownerships,
+	}
+}
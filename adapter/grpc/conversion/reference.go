@@ -0,0 +1,74 @@
+//go:build proto_next
+
+package conversion
+
+import (
+	ucdto "github.com/todennus/file-service/usecase/dto"
+	pbdto "github.com/todennus/proto/gen/service/dto"
+	"github.com/xybor-x/snowflake"
+)
+
+func NewUsecaseAddReferenceRequest(req *pbdto.FileAddReferenceRequest) *ucdto.AddReferenceRequest {
+	return &ucdto.AddReferenceRequest{
+		OwnershipID: snowflake.ParseInt64(req.GetOwnershipId()),
+		Service:     req.GetService(),
+		EntityType:  req.GetEntityType(),
+		EntityID:    req.GetEntityId(),
+	}
+}
+
+func NewPbAddReferenceResponse(resp *ucdto.AddReferenceResponse) *pbdto.FileAddReferenceResponse {
+	if resp == nil {
+		return nil
+	}
+
+	return &pbdto.FileAddReferenceResponse{
+		Refcount: int64(resp.RefCount),
+	}
+}
+
+func NewUsecaseRemoveReferenceRequest(req *pbdto.FileRemoveReferenceRequest) *ucdto.RemoveReferenceRequest {
+	return &ucdto.RemoveReferenceRequest{
+		OwnershipID: snowflake.ParseInt64(req.GetOwnershipId()),
+		Service:     req.GetService(),
+		EntityType:  req.GetEntityType(),
+		EntityID:    req.GetEntityId(),
+	}
+}
+
+func NewPbRemoveReferenceResponse(resp *ucdto.RemoveReferenceResponse) *pbdto.FileRemoveReferenceResponse {
+	if resp == nil {
+		return nil
+	}
+
+	return &pbdto.FileRemoveReferenceResponse{
+		Refcount: int64(resp.RefCount),
+	}
+}
+
+func NewUsecaseListReferencesRequest(req *pbdto.FileListReferencesRequest) *ucdto.ListReferencesRequest {
+	return &ucdto.ListReferencesRequest{
+		OwnershipID: snowflake.ParseInt64(req.GetOwnershipId()),
+	}
+}
+
+func NewPbListReferencesResponse(resp *ucdto.ListReferencesResponse) *pbdto.FileListReferencesResponse {
+	if resp == nil {
+		return nil
+	}
+
+	references := make([]*pbdto.FileReference, 0, len(resp.References))
+	for i := range resp.References {
+		references = append(references, &pbdto.FileReference{
+			OwnershipId: resp.References[i].OwnershipID.Int64(),
+			Service:     resp.References[i].Service,
+			EntityType:  resp.References[i].EntityType,
+			EntityId:    resp.References[i].EntityID,
+			CreatedAt:   resp.References[i].CreatedAt.UnixMilli(),
+		})
+	}
+
+	return &pbdto.FileListReferencesResponse{
+		References: references,
+	}
+}
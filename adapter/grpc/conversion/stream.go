@@ -0,0 +1,59 @@
+//go:build proto_next
+
+package conversion
+
+import (
+	ucdto "github.com/todennus/file-service/usecase/dto"
+	pbdto "github.com/todennus/proto/gen/service/dto"
+	"github.com/xybor-x/snowflake"
+)
+
+func NewPbFileUploadResponse(resp *ucdto.UploadResponse) *pbdto.FileUploadResponse {
+	if resp == nil {
+		return nil
+	}
+
+	pbresp := &pbdto.FileUploadResponse{
+		FileId:      resp.FileID,
+		Bucket:      resp.Bucket,
+		OwnershipId: resp.OwnershipID.Int64(),
+		FileToken:   resp.FileToken,
+	}
+
+	if resp.Image != nil {
+		pbresp.ImageFormat = resp.Image.Format
+		pbresp.ImageWidth = int64(resp.Image.Width)
+		pbresp.ImageHeight = int64(resp.Image.Height)
+	}
+
+	return pbresp
+}
+
+func NewUsecaseDownloadRequest(req *pbdto.FileDownloadRequest) *ucdto.DownloadRequest {
+	return &ucdto.DownloadRequest{
+		OwnershipID: snowflake.ParseInt64(req.GetOwnershipId()),
+		Variant:     req.GetVariant(),
+	}
+}
+
+func NewUsecaseDownloadByFileTokenRequest(req *pbdto.FileDownloadRequest) *ucdto.DownloadByFileTokenRequest {
+	return &ucdto.DownloadByFileTokenRequest{
+		FileToken: req.GetFileToken(),
+		Variant:   req.GetVariant(),
+	}
+}
+
+// NewPbFileDownloadResponse returns the first message of a download stream,
+// without its chunk.
+func NewPbFileDownloadResponse(resp *ucdto.DownloadResponse) *pbdto.FileDownloadResponse {
+	if resp == nil {
+		return nil
+	}
+
+	return &pbdto.FileDownloadResponse{
+		FileId:    resp.FileID,
+		Type:      resp.Type,
+		Size:      int64(resp.Size),
+		CreatedAt: resp.CreatedAt.UnixMilli(),
+	}
+}
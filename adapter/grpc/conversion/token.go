@@ -0,0 +1,58 @@
+//go:build proto_next
+
+package conversion
+
+import (
+	ucdto "github.com/todennus/file-service/usecase/dto"
+	pbdto "github.com/todennus/proto/gen/service/dto"
+	"github.com/xybor-x/snowflake"
+)
+
+func NewUsecaseVerifyFileTokenRequest(req *pbdto.FileVerifyTokenRequest) *ucdto.VerifyFileTokenRequest {
+	return &ucdto.VerifyFileTokenRequest{
+		FileToken:      req.GetFileToken(),
+		ExpectedUserID: snowflake.ParseInt64(req.GetExpectedUserId()),
+	}
+}
+
+func NewPbVerifyFileTokenResponse(resp *ucdto.VerifyFileTokenResponse) *pbdto.FileVerifyTokenResponse {
+	if resp == nil {
+		return nil
+	}
+
+	pbresp := &pbdto.FileVerifyTokenResponse{
+		TokenId:     resp.TokenID.Int64(),
+		OwnershipId: resp.OwnershipID.Int64(),
+		FileId:      resp.FileID,
+		UserId:      resp.UserID.Int64(),
+		Type:        resp.Type,
+		Size:        int64(resp.Size),
+		ExpiresAt:   resp.ExpiresAt.UnixMilli(),
+	}
+
+	if resp.Image != nil {
+		pbresp.ImageFormat = resp.Image.Format
+		pbresp.ImageWidth = int64(resp.Image.Width)
+		pbresp.ImageHeight = int64(resp.Image.Height)
+	}
+
+	return pbresp
+}
+
+func NewUsecaseRevokeFileTokenRequest(req *pbdto.FileRevokeTokenRequest) *ucdto.RevokeFileTokenRequest {
+	return &ucdto.RevokeFileTokenRequest{
+		TokenID:     snowflake.ParseInt64(req.GetTokenId()),
+		OwnershipID: snowflake.ParseInt64(req.GetOwnershipId()),
+		UserID:      snowflake.ParseInt64(req.GetUserId()),
+	}
+}
+
+func NewPbRevokeFileTokenResponse(resp *ucdto.RevokeFileTokenResponse) *pbdto.FileRevokeTokenResponse {
+	if resp == nil {
+		return nil
+	}
+
+	return &pbdto.FileRevokeTokenResponse{
+		ExpiresAt: resp.ExpiresAt.UnixMilli(),
+	}
+}
@@ -0,0 +1,27 @@
+//go:build proto_next
+
+package conversion
+
+import (
+	ucdto "github.com/todennus/file-service/usecase/dto"
+	pbdto "github.com/todennus/proto/gen/service/dto"
+	"github.com/xybor-x/snowflake"
+)
+
+func NewUsecaseCreateTransformURLRequest(req *pbdto.FileCreateTransformURLRequest) *ucdto.CreateTransformURLRequest {
+	return &ucdto.CreateTransformURLRequest{
+		OwnershipID: snowflake.ParseInt64(req.GetOwnershipId()),
+		FileID:      req.GetFileId(),
+		Options:     req.GetOptions(),
+	}
+}
+
+func NewPbCreateTransformURLResponse(resp *ucdto.CreateTransformURLResponse) *pbdto.FileCreateTransformURLResponse {
+	if resp == nil {
+		return nil
+	}
+
+	return &pbdto.FileCreateTransformURLResponse{
+		Path: resp.Path,
+	}
+}
@@ -0,0 +1,7 @@
+// Package grpc serves the file service over gRPC.
+//
+// The RPCs and fields which are not defined by the todennus/proto release
+// pinned in go.mod are only built with the proto_next tag, against the proto
+// release which defines them. Their build constraints are dropped once that
+// release is pinned.
+package grpc
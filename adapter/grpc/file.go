@@ -14,12 +14,13 @@ import (
 )
 
 type FileServer struct {
-	fileUsecase abstraction.FileUsecase
+	fileUsecase      abstraction.FileUsecase
+	transformUsecase abstraction.TransformUsecase
 	service.UnimplementedFileServer
 }
 
-func NewFileServer(fileUsecase abstraction.FileUsecase) *FileServer {
-	return &FileServer{fileUsecase: fileUsecase}
+func NewFileServer(fileUsecase abstraction.FileUsecase, transformUsecase abstraction.TransformUsecase) *FileServer {
+	return &FileServer{fileUsecase: fileUsecase, transformUsecase: transformUsecase}
 }
 
 func (server *FileServer) RegisterUpload(
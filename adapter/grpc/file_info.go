@@ -0,0 +1,28 @@
+//go:build proto_next
+
+package grpc
+
+import (
+	"context"
+
+	"github.com/todennus/file-service/adapter/grpc/conversion"
+	pbdto "github.com/todennus/proto/gen/service/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/interceptor"
+	"github.com/todennus/shared/response"
+	"google.golang.org/grpc/codes"
+)
+
+func (server *FileServer) GetFileInfo(
+	ctx context.Context,
+	req *pbdto.FileGetInfoRequest,
+) (*pbdto.FileGetInfoResponse, error) {
+	if err := interceptor.RequireAuthentication(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := server.fileUsecase.GetFileInfo(ctx, conversion.NewUsecaseGetFileInfoRequest(req))
+	return response.NewGRPCResponseHandler(ctx, conversion.NewPbGetFileInfoResponse(resp), err).
+		Map(codes.PermissionDenied, errordef.ErrForbidden).
+		Finalize(ctx)
+}
@@ -0,0 +1,42 @@
+//go:build proto_next
+
+package grpc
+
+import (
+	"context"
+
+	"github.com/todennus/file-service/adapter/grpc/conversion"
+	pbdto "github.com/todennus/proto/gen/service/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/interceptor"
+	"github.com/todennus/shared/response"
+	"google.golang.org/grpc/codes"
+)
+
+func (server *FileServer) GetOwnership(
+	ctx context.Context,
+	req *pbdto.FileGetOwnershipRequest,
+) (*pbdto.FileGetOwnershipResponse, error) {
+	if err := interceptor.RequireAuthentication(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := server.fileUsecase.GetOwnership(ctx, conversion.NewUsecaseGetOwnershipRequest(req))
+	return response.NewGRPCResponseHandler(ctx, conversion.NewPbGetOwnershipResponse(resp), err).
+		Map(codes.PermissionDenied, errordef.ErrForbidden).
+		Finalize(ctx)
+}
+
+func (server *FileServer) BatchGetOwnerships(
+	ctx context.Context,
+	req *pbdto.FileBatchGetOwnershipsRequest,
+) (*pbdto.FileBatchGetOwnershipsResponse, error) {
+	if err := interceptor.RequireAuthentication(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := server.fileUsecase.BatchGetOwnerships(ctx, conversion.NewUsecaseBatchGetOwnershipsRequest(req))
+	return response.NewGRPCResponseHandler(ctx, conversion.NewPbBatchGetOwnershipsResponse(resp), err).
+		Map(codes.InvalidArgument, errordef.ErrRequestInvalid).
+		Finalize(ctx)
+}
@@ -0,0 +1,58 @@
+//go:build proto_next
+
+package grpc
+
+import (
+	"context"
+
+	"github.com/todennus/file-service/adapter/grpc/conversion"
+	pbdto "github.com/todennus/proto/gen/service/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/interceptor"
+	"github.com/todennus/shared/response"
+	"google.golang.org/grpc/codes"
+)
+
+func (server *FileServer) AddReference(
+	ctx context.Context, req *pbdto.FileAddReferenceRequest,
+) (*pbdto.FileAddReferenceResponse, error) {
+	if err := interceptor.RequireAuthentication(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := server.fileUsecase.AddReference(ctx, conversion.NewUsecaseAddReferenceRequest(req))
+	return response.NewGRPCResponseHandler(ctx, conversion.NewPbAddReferenceResponse(resp), err).
+		Map(codes.InvalidArgument, errordef.ErrRequestInvalid).
+		Map(codes.PermissionDenied, errordef.ErrForbidden).
+		Map(codes.NotFound, errordef.ErrNotFound).
+		Finalize(ctx)
+}
+
+func (server *FileServer) RemoveReference(
+	ctx context.Context, req *pbdto.FileRemoveReferenceRequest,
+) (*pbdto.FileRemoveReferenceResponse, error) {
+	if err := interceptor.RequireAuthentication(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := server.fileUsecase.RemoveReference(ctx, conversion.NewUsecaseRemoveReferenceRequest(req))
+	return response.NewGRPCResponseHandler(ctx, conversion.NewPbRemoveReferenceResponse(resp), err).
+		Map(codes.InvalidArgument, errordef.ErrRequestInvalid).
+		Map(codes.PermissionDenied, errordef.ErrForbidden).
+		Map(codes.NotFound, errordef.ErrNotFound).
+		Finalize(ctx)
+}
+
+func (server *FileServer) ListReferences(
+	ctx context.Context, req *pbdto.FileListReferencesRequest,
+) (*pbdto.FileListReferencesResponse, error) {
+	if err := interceptor.RequireAuthentication(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := server.fileUsecase.ListReferences(ctx, conversion.NewUsecaseListReferencesRequest(req))
+	return response.NewGRPCResponseHandler(ctx, conversion.NewPbListReferencesResponse(resp), err).
+		Map(codes.PermissionDenied, errordef.ErrForbidden).
+		Map(codes.NotFound, errordef.ErrNotFound).
+		Finalize(ctx)
+}
@@ -0,0 +1,184 @@
+//go:build proto_next
+
+package grpc
+
+import (
+	"errors"
+	"io"
+
+	"github.com/todennus/file-service/adapter/grpc/conversion"
+	ucdto "github.com/todennus/file-service/usecase/dto"
+	"github.com/todennus/proto/gen/service"
+	pbdto "github.com/todennus/proto/gen/service/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/interceptor"
+	"github.com/todennus/shared/response"
+	"github.com/todennus/x/xerror"
+	"google.golang.org/grpc/codes"
+)
+
+// downloadChunkSize is the size of the chunks sent by download streams, it is
+// far below the default maximum message size of gRPC.
+const downloadChunkSize = 64 << 10 // 64KiB
+
+// Upload receives the upload token in the first message, then the content in
+// chunks until the client closes the stream.
+func (server *FileServer) Upload(stream service.File_UploadServer) error {
+	ctx := stream.Context()
+	if err := interceptor.RequireAuthentication(ctx); err != nil {
+		return err
+	}
+
+	req, err := newUsecaseUploadRequest(stream)
+	if err != nil {
+		_, err = response.NewGRPCResponseHandler(ctx, (*pbdto.FileUploadResponse)(nil), err).
+			Map(codes.InvalidArgument, errordef.ErrRequestInvalid).
+			Finalize(ctx)
+		return err
+	}
+
+	resp, err := server.fileUsecase.Upload(ctx, req)
+	pbresp, err := response.NewGRPCResponseHandler(ctx, conversion.NewPbFileUploadResponse(resp), err).
+		Map(codes.InvalidArgument,
+			errordef.ErrRequestInvalid,
+			errordef.ErrFileInvalidContent,
+			errordef.ErrFileMismatchedType,
+			errordef.ErrFileMismatchedSize,
+		).
+		Map(codes.PermissionDenied, errordef.ErrForbidden).
+		Map(codes.ResourceExhausted, errordef.ErrRequestTooLarge).
+		Finalize(ctx)
+	if err != nil {
+		return err
+	}
+
+	return stream.SendAndClose(pbresp)
+}
+
+// Download sends the metadata of the file with the first chunk of its content.
+// The file is identified by a file token, or by an ownership of the
+// authenticated user.
+func (server *FileServer) Download(req *pbdto.FileDownloadRequest, stream service.File_DownloadServer) error {
+	ctx := stream.Context()
+
+	var resp *ucdto.DownloadResponse
+	var err error
+	if req.GetFileToken() != "" {
+		resp, err = server.fileUsecase.DownloadByFileToken(ctx, conversion.NewUsecaseDownloadByFileTokenRequest(req))
+	} else {
+		if err := interceptor.RequireAuthentication(ctx); err != nil {
+			return err
+		}
+
+		resp, err = server.fileUsecase.Download(ctx, conversion.NewUsecaseDownloadRequest(req))
+	}
+
+	first, err := response.NewGRPCResponseHandler(ctx, conversion.NewPbFileDownloadResponse(resp), err).
+		Map(codes.InvalidArgument, errordef.ErrRequestInvalid).
+		Map(codes.PermissionDenied, errordef.ErrForbidden).
+		Map(codes.NotFound, errordef.ErrNotFound).
+		Finalize(ctx)
+	if err != nil {
+		return err
+	}
+	defer resp.Content.Close()
+
+	if err := sendDownloadStream(stream, first, resp.Content); err != nil {
+		_, err = response.NewGRPCResponseHandler(ctx, (*pbdto.FileDownloadResponse)(nil), err).Finalize(ctx)
+		return err
+	}
+
+	return nil
+}
+
+// newUsecaseUploadRequest reads the first message of an upload stream, which
+// carries the upload token and optionally the first chunk. The other messages
+// are read while the content is streamed to the storage.
+func newUsecaseUploadRequest(stream service.File_UploadServer) (*ucdto.UploadRequest, error) {
+	first, err := stream.Recv()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, xerror.Enrich(errordef.ErrRequestInvalid, "require the upload token")
+		}
+
+		return nil, err
+	}
+
+	if first.GetUploadToken() == "" {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "require the upload token in the first message")
+	}
+
+	return &ucdto.UploadRequest{
+		UploadToken: first.GetUploadToken(),
+		File:        &uploadStreamReader{stream: stream, chunk: first.GetChunk()},
+	}, nil
+}
+
+// uploadStreamReader reads the chunks of an upload stream as a single content,
+// which ends when the client closes the stream.
+type uploadStreamReader struct {
+	stream service.File_UploadServer
+	chunk  []byte
+	err    error
+}
+
+func (r *uploadStreamReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		msg, err := r.stream.Recv()
+		if err != nil {
+			r.err = err
+			continue
+		}
+
+		if msg.GetUploadToken() != "" {
+			r.err = errors.New("the upload token is only allowed in the first message")
+			continue
+		}
+
+		r.chunk = msg.GetChunk()
+	}
+
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+func (r *uploadStreamReader) Close() error {
+	return nil
+}
+
+// sendDownloadStream sends the metadata with the first chunk, then the other
+// chunks. An empty content is sent as a single message.
+func sendDownloadStream(
+	stream service.File_DownloadServer,
+	first *pbdto.FileDownloadResponse,
+	content io.Reader,
+) error {
+	msg := first
+	for {
+		// gRPC may still use a message after sending it, so the buffer is not
+		// reused.
+		buffer := make([]byte, downloadChunkSize)
+		n, err := io.ReadFull(content, buffer)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return errordef.ErrServer.Hide(err, "failed-to-read-file-content")
+		}
+
+		if n > 0 || msg == first {
+			msg.Chunk = buffer[:n]
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+
+		if n < len(buffer) {
+			return nil
+		}
+
+		msg = &pbdto.FileDownloadResponse{}
+	}
+}
@@ -0,0 +1,44 @@
+//go:build proto_next
+
+package grpc
+
+import (
+	"context"
+
+	"github.com/todennus/file-service/adapter/grpc/conversion"
+	pbdto "github.com/todennus/proto/gen/service/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/interceptor"
+	"github.com/todennus/shared/response"
+	"google.golang.org/grpc/codes"
+)
+
+func (server *FileServer) VerifyToken(
+	ctx context.Context,
+	req *pbdto.FileVerifyTokenRequest,
+) (*pbdto.FileVerifyTokenResponse, error) {
+	if err := interceptor.RequireAuthentication(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := server.fileUsecase.VerifyFileToken(ctx, conversion.NewUsecaseVerifyFileTokenRequest(req))
+	return response.NewGRPCResponseHandler(ctx, conversion.NewPbVerifyFileTokenResponse(resp), err).
+		Map(codes.InvalidArgument, errordef.ErrRequestInvalid).
+		Map(codes.PermissionDenied, errordef.ErrForbidden).
+		Finalize(ctx)
+}
+
+func (server *FileServer) RevokeToken(
+	ctx context.Context,
+	req *pbdto.FileRevokeTokenRequest,
+) (*pbdto.FileRevokeTokenResponse, error) {
+	if err := interceptor.RequireAuthentication(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := server.fileUsecase.RevokeFileToken(ctx, conversion.NewUsecaseRevokeFileTokenRequest(req))
+	return response.NewGRPCResponseHandler(ctx, conversion.NewPbRevokeFileTokenResponse(resp), err).
+		Map(codes.InvalidArgument, errordef.ErrRequestInvalid).
+		Map(codes.PermissionDenied, errordef.ErrForbidden).
+		Finalize(ctx)
+}
@@ -0,0 +1,30 @@
+//go:build proto_next
+
+package grpc
+
+import (
+	"context"
+
+	"github.com/todennus/file-service/adapter/grpc/conversion"
+	pbdto "github.com/todennus/proto/gen/service/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/interceptor"
+	"github.com/todennus/shared/response"
+	"google.golang.org/grpc/codes"
+)
+
+func (server *FileServer) CreateTransformURL(
+	ctx context.Context,
+	req *pbdto.FileCreateTransformURLRequest,
+) (*pbdto.FileCreateTransformURLResponse, error) {
+	if err := interceptor.RequireAuthentication(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := server.transformUsecase.CreateTransformURL(ctx, conversion.NewUsecaseCreateTransformURLRequest(req))
+	return response.NewGRPCResponseHandler(ctx, conversion.NewPbCreateTransformURLResponse(resp), err).
+		Map(codes.InvalidArgument, errordef.ErrRequestInvalid).
+		Map(codes.PermissionDenied, errordef.ErrForbidden).
+		Map(codes.NotFound, errordef.ErrNotFound).
+		Finalize(ctx)
+}
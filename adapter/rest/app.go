@@ -22,6 +22,7 @@ func App(
 	r.Use(middleware.Authentication(config.TokenEngine))
 	r.Use(middleware.WithSession(config.SessionManager))
 
+	r.Route("/files/transform", NewTransformAdapter(usecases.TransformUsecase).Router)
 	r.Route("/files", NewFileAdapter(usecases.FileUsecase).Router)
 
 	r.NotFound(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) })
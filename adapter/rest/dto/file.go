@@ -1,18 +1,60 @@
 package dto
 
 import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
 	"github.com/todennus/file-service/usecase/dto"
 	"github.com/todennus/shared/errordef"
 	"github.com/todennus/x/xerror"
-	"github.com/todennus/x/xhttp"
 	"github.com/xybor-x/snowflake"
 )
 
-var _ xhttp.FormDataRequest = (*UploadRequest)(nil)
+// maxUploadTokenLength bounds the upload_token field which is read into memory.
+const maxUploadTokenLength = 1024
 
+// UploadRequest is parsed from the multipart form as a stream, so the file is
+// never buffered. The upload_token field must precede the file field, the
+// fields after the file are ignored.
 type UploadRequest struct {
-	UploadToken string      `multipart:"upload_token"`
-	File        *xhttp.File `multipart:"file,file"`
+	UploadToken string
+	File        io.ReadCloser
+}
+
+func NewUploadRequest(r *http.Request) (*UploadRequest, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "require a multipart form")
+	}
+
+	req := &UploadRequest{}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return req, nil
+		}
+
+		if err != nil {
+			return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid multipart form")
+		}
+
+		switch part.FormName() {
+		case "upload_token":
+			value, err := io.ReadAll(io.LimitReader(part, maxUploadTokenLength))
+			if err != nil {
+				return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid upload_token")
+			}
+
+			req.UploadToken = string(value)
+
+		case "file":
+			req.File = part
+			return req, nil
+		}
+	}
 }
 
 func (req *UploadRequest) To() (*dto.UploadRequest, error) {
@@ -26,15 +68,12 @@ func (req *UploadRequest) To() (*dto.UploadRequest, error) {
 	}, nil
 }
 
-func (req *UploadRequest) NumFiles() int {
-	return 1
-}
-
 type UploadResponse struct {
-	Bucket      string `json:"bucket"`
-	FileID      string `json:"file_id"`
-	OwnershipID string `json:"ownership_id"`
-	FileToken   string `json:"file_token"`
+	Bucket      string         `json:"bucket"`
+	FileID      string         `json:"file_id"`
+	OwnershipID string         `json:"ownership_id"`
+	FileToken   string         `json:"file_token"`
+	Image       *ImageMetadata `json:"image,omitempty"`
 }
 
 func NewUploadResponse(resp *dto.UploadResponse) *UploadResponse {
@@ -47,7 +86,177 @@ func NewUploadResponse(resp *dto.UploadResponse) *UploadResponse {
 		FileID:      resp.FileID,
 		OwnershipID: resp.OwnershipID.String(),
 		FileToken:   resp.FileToken,
+		Image:       NewImageMetadata(resp.Image),
+	}
+}
+
+// ImageMetadata is only returned for images whose format can be decoded.
+type ImageMetadata struct {
+	Format string `json:"format"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+func NewImageMetadata(image *dto.ImageMetadata) *ImageMetadata {
+	if image == nil {
+		return nil
+	}
+
+	return &ImageMetadata{
+		Format: image.Format,
+		Width:  image.Width,
+		Height: image.Height,
+	}
+}
+
+type InstantUploadRequest struct {
+	UploadToken string `json:"upload_token"`
+	SHA256      string `json:"sha256"` // hex-encoded
+	Size        int64  `json:"size"`
+}
+
+func (req *InstantUploadRequest) To() (*dto.InstantUploadRequest, error) {
+	hash, err := hex.DecodeString(req.SHA256)
+	if err != nil {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid sha256")
+	}
+
+	return &dto.InstantUploadRequest{
+		UploadToken: req.UploadToken,
+		SHA256:      hash,
+		Size:        req.Size,
+	}, nil
+}
+
+type FinalizeUploadRequest struct {
+	UploadToken string `json:"upload_token"`
+}
+
+func (req *FinalizeUploadRequest) To() *dto.FinalizeUploadRequest {
+	return &dto.FinalizeUploadRequest{UploadToken: req.UploadToken}
+}
+
+type GetFileInfoRequest struct {
+	OwnershipID int64 `param:"ownership_id"`
+}
+
+func (req *GetFileInfoRequest) To() *dto.GetFileInfoRequest {
+	return &dto.GetFileInfoRequest{OwnershipID: snowflake.ID(req.OwnershipID)}
+}
+
+type GetFileInfoResponse struct {
+	FileID    string         `json:"file_id"`
+	Bucket    string         `json:"bucket"`
+	Type      string         `json:"type"`
+	Size      int            `json:"size"`
+	Image     *ImageMetadata `json:"image,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	OwnerID   string         `json:"owner_id"`
+	RefCount  int            `json:"refcount"`
+}
+
+func NewGetFileInfoResponse(resp *dto.GetFileInfoResponse) *GetFileInfoResponse {
+	if resp == nil {
+		return nil
+	}
+
+	return &GetFileInfoResponse{
+		FileID:    resp.FileID,
+		Bucket:    resp.Bucket,
+		Type:      resp.Type,
+		Size:      resp.Size,
+		Image:     NewImageMetadata(resp.Image),
+		CreatedAt: resp.CreatedAt,
+		OwnerID:   resp.OwnerID.String(),
+		RefCount:  resp.RefCount,
+	}
+}
+
+type Ownership struct {
+	OwnershipID string         `json:"ownership_id"`
+	OwnerID     string         `json:"owner_id"`
+	RefCount    int            `json:"refcount"`
+	DisplayName string         `json:"display_name,omitempty"`
+	Description string         `json:"description,omitempty"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	FileID      string         `json:"file_id"`
+	Bucket      string         `json:"bucket"`
+	Type        string         `json:"type"`
+	Size        int            `json:"size"`
+	Image       *ImageMetadata `json:"image,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+func NewOwnership(ownership *dto.OwnershipInfo) *Ownership {
+	if ownership == nil {
+		return nil
+	}
+
+	return &Ownership{
+		OwnershipID: ownership.OwnershipID.String(),
+		OwnerID:     ownership.OwnerID.String(),
+		RefCount:    ownership.RefCount,
+		DisplayName: ownership.DisplayName,
+		Description: ownership.Description,
+		UpdatedAt:   ownership.UpdatedAt,
+		FileID:      ownership.FileID,
+		Bucket:      ownership.Bucket,
+		Type:        ownership.Type,
+		Size:        ownership.Size,
+		Image:       NewImageMetadata(ownership.Image),
+		CreatedAt:   ownership.CreatedAt,
+	}
+}
+
+type GetOwnershipRequest struct {
+	OwnershipID int64 `param:"ownership_id"`
+}
+
+func (req *GetOwnershipRequest) To() *dto.GetOwnershipRequest {
+	return &dto.GetOwnershipRequest{OwnershipID: snowflake.ID(req.OwnershipID)}
+}
+
+func NewGetOwnershipResponse(resp *dto.GetOwnershipResponse) *Ownership {
+	if resp == nil {
+		return nil
+	}
+
+	return NewOwnership(resp.Ownership)
+}
+
+type BatchGetOwnershipsRequest struct {
+	OwnershipIDs []string `json:"ownership_ids"`
+}
+
+func (req *BatchGetOwnershipsRequest) To() (*dto.BatchGetOwnershipsRequest, error) {
+	ids := make([]snowflake.ID, 0, len(req.OwnershipIDs))
+	for _, s := range req.OwnershipIDs {
+		id, err := snowflake.ParseString(s)
+		if err != nil {
+			return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid ownership id %s", s)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return &dto.BatchGetOwnershipsRequest{OwnershipIDs: ids}, nil
+}
+
+type BatchGetOwnershipsResponse struct {
+	Ownerships []*Ownership `json:"ownerships"`
+}
+
+func NewBatchGetOwnershipsResponse(resp *dto.BatchGetOwnershipsResponse) *BatchGetOwnershipsResponse {
+	if resp == nil {
+		return nil
 	}
+
+	ownerships := make([]*Ownership, 0, len(resp.Ownerships))
+	for i := range resp.Ownerships {
+		ownerships = append(ownerships, NewOwnership(resp.Ownerships[i]))
+	}
+
+	return &BatchGetOwnershipsResponse{Ownerships: ownerships}
 }
 
 type RetrieveFileTokenRequest struct {
@@ -58,6 +267,22 @@ func (req *RetrieveFileTokenRequest) To() *dto.RetrieveFileTokenRequest {
 	return &dto.RetrieveFileTokenRequest{OwnershipID: snowflake.ID(req.OwnershipID)}
 }
 
+type DownloadRequest struct {
+	OwnershipID int64 `param:"ownership_id"`
+}
+
+func (req *DownloadRequest) To() *dto.DownloadRequest {
+	return &dto.DownloadRequest{OwnershipID: snowflake.ID(req.OwnershipID)}
+}
+
+type DownloadByFileTokenRequest struct {
+	FileToken string `param:"file_token"`
+}
+
+func (req *DownloadByFileTokenRequest) To() *dto.DownloadByFileTokenRequest {
+	return &dto.DownloadByFileTokenRequest{FileToken: req.FileToken}
+}
+
 type RetrieveFileTokenResponse struct {
 	FileToken string `json:"file_token"`
 }
@@ -71,3 +296,91 @@ func NewRetrieveFileTokenResponse(resp *dto.RetrieveFileTokenResponse) *Retrieve
 		FileToken: resp.FileToken,
 	}
 }
+
+type VerifyFileTokenRequest struct {
+	FileToken      string `json:"file_token"`
+	ExpectedUserID string `json:"expected_user_id,omitempty"`
+}
+
+func (req *VerifyFileTokenRequest) To() (*dto.VerifyFileTokenRequest, error) {
+	var userID snowflake.ID
+	if req.ExpectedUserID != "" {
+		var err error
+		if userID, err = snowflake.ParseString(req.ExpectedUserID); err != nil {
+			return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid expected user id")
+		}
+	}
+
+	return &dto.VerifyFileTokenRequest{
+		FileToken:      req.FileToken,
+		ExpectedUserID: userID,
+	}, nil
+}
+
+type RevokeFileTokenRequest struct {
+	TokenID     string `json:"token_id,omitempty"`
+	OwnershipID string `json:"ownership_id,omitempty"`
+	UserID      string `json:"user_id,omitempty"`
+}
+
+func (req *RevokeFileTokenRequest) To() (*dto.RevokeFileTokenRequest, error) {
+	ids := make([]snowflake.ID, 3)
+	for i, s := range []string{req.TokenID, req.OwnershipID, req.UserID} {
+		if s == "" {
+			continue
+		}
+
+		id, err := snowflake.ParseString(s)
+		if err != nil {
+			return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid id %s", s)
+		}
+
+		ids[i] = id
+	}
+
+	return &dto.RevokeFileTokenRequest{
+		TokenID:     ids[0],
+		OwnershipID: ids[1],
+		UserID:      ids[2],
+	}, nil
+}
+
+type RevokeFileTokenResponse struct {
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func NewRevokeFileTokenResponse(resp *dto.RevokeFileTokenResponse) *RevokeFileTokenResponse {
+	if resp == nil {
+		return nil
+	}
+
+	return &RevokeFileTokenResponse{ExpiresAt: resp.ExpiresAt}
+}
+
+type VerifyFileTokenResponse struct {
+	TokenID     string         `json:"token_id"`
+	OwnershipID string         `json:"ownership_id"`
+	FileID      string         `json:"file_id"`
+	UserID      string         `json:"user_id"`
+	Type        string         `json:"type"`
+	Size        int            `json:"size"`
+	Image       *ImageMetadata `json:"image,omitempty"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+}
+
+func NewVerifyFileTokenResponse(resp *dto.VerifyFileTokenResponse) *VerifyFileTokenResponse {
+	if resp == nil {
+		return nil
+	}
+
+	return &VerifyFileTokenResponse{
+		TokenID:     resp.TokenID.String(),
+		OwnershipID: resp.OwnershipID.String(),
+		FileID:      resp.FileID,
+		UserID:      resp.UserID.String(),
+		Type:        resp.Type,
+		Size:        resp.Size,
+		Image:       NewImageMetadata(resp.Image),
+		ExpiresAt:   resp.ExpiresAt,
+	}
+}
@@ -0,0 +1,134 @@
+package dto
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/todennus/file-service/usecase/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/x/xerror"
+	"github.com/xybor-x/snowflake"
+)
+
+// ListOwnershipsRequest is parsed from the query. The times are in RFC 3339.
+type ListOwnershipsRequest struct {
+	Cursor        snowflake.ID
+	Limit         int
+	Type          string
+	Bucket        string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+func NewListOwnershipsRequest(r *http.Request) (*ListOwnershipsRequest, error) {
+	query := r.URL.Query()
+	req := &ListOwnershipsRequest{
+		Type:   query.Get("type"),
+		Bucket: query.Get("bucket"),
+	}
+
+	var err error
+	if s := query.Get("cursor"); s != "" {
+		if req.Cursor, err = snowflake.ParseString(s); err != nil {
+			return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid cursor")
+		}
+	}
+
+	if s := query.Get("limit"); s != "" {
+		if req.Limit, err = strconv.Atoi(s); err != nil {
+			return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid limit")
+		}
+	}
+
+	if s := query.Get("created_after"); s != "" {
+		if req.CreatedAfter, err = time.Parse(time.RFC3339, s); err != nil {
+			return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid created_after")
+		}
+	}
+
+	if s := query.Get("created_before"); s != "" {
+		if req.CreatedBefore, err = time.Parse(time.RFC3339, s); err != nil {
+			return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid created_before")
+		}
+	}
+
+	return req, nil
+}
+
+func (req *ListOwnershipsRequest) To() *dto.ListOwnershipsRequest {
+	return &dto.ListOwnershipsRequest{
+		Cursor:        req.Cursor,
+		Limit:         req.Limit,
+		Type:          req.Type,
+		Bucket:        req.Bucket,
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+	}
+}
+
+type ListOwnershipsResponse struct {
+	Ownerships []*Ownership `json:"ownerships"`
+
+	// NextCursor is omitted on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func NewListOwnershipsResponse(resp *dto.ListOwnershipsResponse) *ListOwnershipsResponse {
+	if resp == nil {
+		return nil
+	}
+
+	ownerships := make([]*Ownership, 0, len(resp.Ownerships))
+	for i := range resp.Ownerships {
+		ownerships = append(ownerships, NewOwnership(resp.Ownerships[i]))
+	}
+
+	result := &ListOwnershipsResponse{Ownerships: ownerships}
+	if resp.NextCursor != 0 {
+		result.NextCursor = resp.NextCursor.String()
+	}
+
+	return result
+}
+
+// UpdateOwnershipRequest only changes the fields which are present in the body.
+type UpdateOwnershipRequest struct {
+	OwnershipID int64   `param:"ownership_id"`
+	DisplayName *string `json:"display_name"`
+	Description *string `json:"description"`
+}
+
+func (req *UpdateOwnershipRequest) To() *dto.UpdateOwnershipRequest {
+	return &dto.UpdateOwnershipRequest{
+		OwnershipID: snowflake.ID(req.OwnershipID),
+		DisplayName: req.DisplayName,
+		Description: req.Description,
+	}
+}
+
+func NewUpdateOwnershipResponse(resp *dto.UpdateOwnershipResponse) *Ownership {
+	if resp == nil {
+		return nil
+	}
+
+	return NewOwnership(resp.Ownership)
+}
+
+type DeleteOwnershipRequest struct {
+	OwnershipID int64 `param:"ownership_id"`
+}
+
+func (req *DeleteOwnershipRequest) To() *dto.DeleteOwnershipRequest {
+	return &dto.DeleteOwnershipRequest{OwnershipID: snowflake.ID(req.OwnershipID)}
+}
+
+type DeleteOwnershipResponse struct{}
+
+func NewDeleteOwnershipResponse(resp *dto.DeleteOwnershipResponse) *DeleteOwnershipResponse {
+	if resp == nil {
+		return nil
+	}
+
+	return &DeleteOwnershipResponse{}
+}
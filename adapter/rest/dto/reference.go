@@ -0,0 +1,45 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/todennus/file-service/usecase/dto"
+	"github.com/xybor-x/snowflake"
+)
+
+type ListReferencesRequest struct {
+	OwnershipID int64 `param:"ownership_id"`
+}
+
+func (req *ListReferencesRequest) To() *dto.ListReferencesRequest {
+	return &dto.ListReferencesRequest{OwnershipID: snowflake.ID(req.OwnershipID)}
+}
+
+type Reference struct {
+	Service    string    `json:"service"`
+	EntityType string    `json:"entity_type"`
+	EntityID   string    `json:"entity_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ListReferencesResponse struct {
+	References []Reference `json:"references"`
+}
+
+func NewListReferencesResponse(resp *dto.ListReferencesResponse) *ListReferencesResponse {
+	if resp == nil {
+		return nil
+	}
+
+	references := make([]Reference, 0, len(resp.References))
+	for i := range resp.References {
+		references = append(references, Reference{
+			Service:    resp.References[i].Service,
+			EntityType: resp.References[i].EntityType,
+			EntityID:   resp.References[i].EntityID,
+			CreatedAt:  resp.References[i].CreatedAt,
+		})
+	}
+
+	return &ListReferencesResponse{References: references}
+}
@@ -0,0 +1,125 @@
+package dto
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/todennus/file-service/usecase/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/x/xerror"
+)
+
+// The requests of the tus protocol are described by headers, they are parsed
+// from the http request directly.
+
+type CreateResumableUploadRequest struct {
+	UploadLength   string
+	UploadMetadata string
+}
+
+func NewCreateResumableUploadRequest(r *http.Request) *CreateResumableUploadRequest {
+	return &CreateResumableUploadRequest{
+		UploadLength:   r.Header.Get("Upload-Length"),
+		UploadMetadata: r.Header.Get("Upload-Metadata"),
+	}
+}
+
+func (req *CreateResumableUploadRequest) To() (*dto.CreateResumableUploadRequest, error) {
+	length, err := strconv.ParseInt(req.UploadLength, 10, 64)
+	if err != nil {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid Upload-Length")
+	}
+
+	metadata, err := parseUploadMetadata(req.UploadMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.CreateResumableUploadRequest{
+		UploadToken: metadata["upload_token"],
+		Length:      length,
+	}, nil
+}
+
+type GetResumableUploadRequest struct {
+	UploadID string
+}
+
+func NewGetResumableUploadRequest(r *http.Request) *GetResumableUploadRequest {
+	return &GetResumableUploadRequest{UploadID: chi.URLParam(r, "upload_id")}
+}
+
+func (req *GetResumableUploadRequest) To() *dto.GetResumableUploadRequest {
+	return &dto.GetResumableUploadRequest{UploadID: req.UploadID}
+}
+
+type AppendResumableUploadRequest struct {
+	UploadID     string
+	UploadOffset string
+	ContentType  string
+	Request      *http.Request
+}
+
+func NewAppendResumableUploadRequest(r *http.Request) *AppendResumableUploadRequest {
+	return &AppendResumableUploadRequest{
+		UploadID:     chi.URLParam(r, "upload_id"),
+		UploadOffset: r.Header.Get("Upload-Offset"),
+		ContentType:  r.Header.Get("Content-Type"),
+		Request:      r,
+	}
+}
+
+func (req *AppendResumableUploadRequest) To() (*dto.AppendResumableUploadRequest, error) {
+	if req.ContentType != "application/offset+octet-stream" {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "require Content-Type application/offset+octet-stream")
+	}
+
+	offset, err := strconv.ParseInt(req.UploadOffset, 10, 64)
+	if err != nil || offset < 0 {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid Upload-Offset")
+	}
+
+	return &dto.AppendResumableUploadRequest{
+		UploadID:      req.UploadID,
+		Offset:        offset,
+		Content:       req.Request.Body,
+		ContentLength: req.Request.ContentLength,
+	}, nil
+}
+
+type TerminateResumableUploadRequest struct {
+	UploadID string
+}
+
+func NewTerminateResumableUploadRequest(r *http.Request) *TerminateResumableUploadRequest {
+	return &TerminateResumableUploadRequest{UploadID: chi.URLParam(r, "upload_id")}
+}
+
+func (req *TerminateResumableUploadRequest) To() *dto.TerminateResumableUploadRequest {
+	return &dto.TerminateResumableUploadRequest{UploadID: req.UploadID}
+}
+
+// parseUploadMetadata parses the Upload-Metadata header, which consists of
+// comma-separated key-value pairs. The key and the value are separated by a
+// space, the value is base64 encoded and may be omitted.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if header == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encodedValue, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		value, err := base64.StdEncoding.DecodeString(encodedValue)
+		if err != nil {
+			return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid Upload-Metadata value of %s", key)
+		}
+
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
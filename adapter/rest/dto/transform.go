@@ -0,0 +1,17 @@
+package dto
+
+import "github.com/todennus/file-service/usecase/dto"
+
+type TransformRequest struct {
+	Signature string `param:"signature"`
+	Options   string `param:"options"`
+	FileID    string `param:"file_id"`
+}
+
+func (req *TransformRequest) To() *dto.TransformRequest {
+	return &dto.TransformRequest{
+		Signature: req.Signature,
+		Options:   req.Options,
+		FileID:    req.FileID,
+	}
+}
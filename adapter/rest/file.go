@@ -1,11 +1,13 @@
 package rest
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/todennus/file-service/adapter/abstraction"
 	"github.com/todennus/file-service/adapter/rest/dto"
+	ucdto "github.com/todennus/file-service/usecase/dto"
 	"github.com/todennus/shared/errordef"
 	"github.com/todennus/shared/middleware"
 	"github.com/todennus/shared/response"
@@ -22,7 +24,23 @@ func NewFileAdapter(fileUsecase abstraction.FileUsecase) *FileAdapter {
 
 func (a *FileAdapter) Router(r chi.Router) {
 	r.Get("/token/{ownership_id}", middleware.RequireAuthentication(a.RetrieveFileToken()))
+	r.Post("/token/verify", middleware.RequireAuthentication(a.VerifyFileToken()))
+	r.Post("/token/revoke", middleware.RequireAuthentication(a.RevokeFileToken()))
+	r.Get("/", middleware.RequireAuthentication(a.ListOwnerships()))
+	r.Get("/{ownership_id}", middleware.RequireAuthentication(a.GetFileInfo()))
+	r.Patch("/{ownership_id}", middleware.RequireAuthentication(a.UpdateOwnership()))
+	r.Delete("/{ownership_id}", middleware.RequireAuthentication(a.DeleteOwnership()))
+	r.Get("/{ownership_id}/ownership", middleware.RequireAuthentication(a.GetOwnership()))
+	r.Post("/ownerships/batch", middleware.RequireAuthentication(a.BatchGetOwnerships()))
+	r.Get("/{ownership_id}/content", middleware.RequireAuthentication(a.Download()))
+	r.Head("/{ownership_id}/content", middleware.RequireAuthentication(a.Download()))
+	r.Get("/{ownership_id}/references", middleware.RequireAuthentication(a.ListReferences()))
+	r.Get("/content/{file_token}", a.DownloadByFileToken())
+	r.Head("/content/{file_token}", a.DownloadByFileToken())
 	r.Post("/", a.Upload()) // Has already required authentication in the handler.
+	r.Post("/instant", middleware.RequireAuthentication(a.InstantUpload()))
+	r.Post("/finalize", middleware.RequireAuthentication(a.FinalizeUpload()))
+	r.Route("/tus", a.ResumableUploadRouter)
 }
 
 // @Summary Upload file.
@@ -30,7 +48,7 @@ func (a *FileAdapter) Router(r chi.Router) {
 // @Tags File
 // @Accept multipart/form-data
 // @Produce json
-// @Param upload_token formData string true "upload token"
+// @Param upload_token formData string true "upload token, must precede the file"
 // @Param file formData file true "Upload file"
 // @Success 201 {object} response.SwaggerSuccessResponse[dto.UploadResponse] "Upload successfully"
 // @Failure 400 {object} response.SwaggerBadRequestErrorResponse "Bad request"
@@ -40,7 +58,7 @@ func (a *FileAdapter) Upload() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
-		request, err := xhttp.ParseHTTPRequest[dto.UploadRequest](r)
+		request, err := dto.NewUploadRequest(r)
 		if err != nil {
 			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
 			return
@@ -71,6 +89,84 @@ func (a *FileAdapter) Upload() func(w http.ResponseWriter, r *http.Request) {
 				errordef.ErrFileMismatchedSize,
 			).
 			Map(http.StatusForbidden, errordef.ErrForbidden).
+			Map(http.StatusRequestEntityTooLarge, errordef.ErrRequestTooLarge).
+			WithDefaultCode(http.StatusCreated).
+			WriteHTTPResponse(ctx, w)
+	}
+}
+
+// @Summary Upload file instantly.
+// @Description Declare the SHA-256 and the size of the file. If the file has already been stored, its ownership is given without uploading the content. Otherwise, the API responds 404 and the same `upload_token` must be used to upload the content, which must match the declaration. A stored image must be within the image limits of the token, an image whose limits can't be checked without decoding it (e.g. the frames of a GIF image) can't be uploaded instantly.
+// @Tags File
+// @Accept json
+// @Produce json
+// @Param body body dto.InstantUploadRequest true "Instant upload request"
+// @Success 201 {object} response.SwaggerSuccessResponse[dto.UploadResponse] "Upload successfully"
+// @Failure 400 {object} response.SwaggerBadRequestErrorResponse "Bad request"
+// @Failure 403 {object} response.SwaggerForbiddenErrorResponse "Forbidden"
+// @Failure 404 "The file is not stored, the content must be uploaded"
+// @Router /files/instant [post]
+func (a *FileAdapter) InstantUpload() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req, err := xhttp.ParseHTTPRequest[dto.InstantUploadRequest](r)
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		ucreq, err := req.To()
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		resp, err := a.fileUsecase.InstantUpload(ctx, ucreq)
+		response.NewRESTResponseHandler(ctx, dto.NewUploadResponse(resp), err).
+			Map(http.StatusBadRequest,
+				errordef.ErrRequestInvalid,
+				errordef.ErrFileMismatchedType,
+				errordef.ErrFileMismatchedSize,
+			).
+			Map(http.StatusForbidden, errordef.ErrForbidden).
+			Map(http.StatusNotFound, errordef.ErrNotFound).
+			Map(http.StatusRequestEntityTooLarge, errordef.ErrRequestTooLarge).
+			WithDefaultCode(http.StatusCreated).
+			WriteHTTPResponse(ctx, w)
+	}
+}
+
+// @Summary Finalize a direct upload.
+// @Description Use an `upload_token` registered for direct upload to check and store the file which has been uploaded to the presigned URL. This API also returns a `file_token`.
+// @Tags File
+// @Accept json
+// @Produce json
+// @Param body body dto.FinalizeUploadRequest true "Finalize upload request"
+// @Success 201 {object} response.SwaggerSuccessResponse[dto.UploadResponse] "Upload successfully"
+// @Failure 400 {object} response.SwaggerBadRequestErrorResponse "Bad request"
+// @Failure 403 {object} response.SwaggerForbiddenErrorResponse "Forbidden"
+// @Router /files/finalize [post]
+func (a *FileAdapter) FinalizeUpload() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req, err := xhttp.ParseHTTPRequest[dto.FinalizeUploadRequest](r)
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		resp, err := a.fileUsecase.FinalizeUpload(ctx, req.To())
+		response.NewRESTResponseHandler(ctx, dto.NewUploadResponse(resp), err).
+			Map(http.StatusBadRequest,
+				errordef.ErrRequestInvalid,
+				errordef.ErrFileInvalidContent,
+				errordef.ErrFileMismatchedType,
+				errordef.ErrFileMismatchedSize,
+			).
+			Map(http.StatusForbidden, errordef.ErrForbidden).
+			Map(http.StatusRequestEntityTooLarge, errordef.ErrRequestTooLarge).
 			WithDefaultCode(http.StatusCreated).
 			WriteHTTPResponse(ctx, w)
 	}
@@ -83,6 +179,7 @@ func (a *FileAdapter) Upload() func(w http.ResponseWriter, r *http.Request) {
 // @Param user body dto.RetrieveFileTokenRequest true "Retrieve policy request"
 // @Success 201 {object} response.SwaggerSuccessResponse[dto.RetrieveFileTokenResponse] "Successfully retrieve the file token"
 // @Failure 400 {object} response.SwaggerBadRequestErrorResponse "Bad request"
+// @Failure 403 {object} response.SwaggerForbiddenErrorResponse "Forbidden, or the file is quarantined"
 // @Router /files/token/{ownership_id} [get]
 func (a *FileAdapter) RetrieveFileToken() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -97,6 +194,370 @@ func (a *FileAdapter) RetrieveFileToken() func(w http.ResponseWriter, r *http.Re
 		resp, err := a.fileUsecase.RetrieveFileToken(ctx, req.To())
 		response.NewRESTResponseHandler(ctx, dto.NewRetrieveFileTokenResponse(resp), err).
 			Map(http.StatusBadRequest, errordef.ErrRequestInvalid).
+			Map(http.StatusForbidden, errordef.ErrForbidden).
 			WriteHTTPResponse(ctx, w)
 	}
 }
+
+// @Summary Verify file token.
+// @Description Validate a `file_token` and return its claims. The verification fails if the ownership has been deleted, or if the token doesn't match the optional `expected_user_id`.
+// @Tags File
+// @Accept json
+// @Produce json
+// @Param body body dto.VerifyFileTokenRequest true "Verify file token request"
+// @Success 200 {object} response.SwaggerSuccessResponse[dto.VerifyFileTokenResponse] "The file token is valid"
+// @Failure 400 {object} response.SwaggerBadRequestErrorResponse "Bad request"
+// @Failure 403 {object} response.SwaggerForbiddenErrorResponse "Invalid, expired or mismatched file token"
+// @Router /files/token/verify [post]
+func (a *FileAdapter) VerifyFileToken() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req, err := xhttp.ParseHTTPRequest[dto.VerifyFileTokenRequest](r)
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		ucreq, err := req.To()
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		resp, err := a.fileUsecase.VerifyFileToken(ctx, ucreq)
+		response.NewRESTResponseHandler(ctx, dto.NewVerifyFileTokenResponse(resp), err).
+			Map(http.StatusBadRequest, errordef.ErrRequestInvalid).
+			Map(http.StatusForbidden, errordef.ErrForbidden).
+			WriteHTTPResponse(ctx, w)
+	}
+}
+
+// @Summary Revoke file tokens.
+// @Description Revoke a file token by its `token_id`, or all file tokens issued so far for an `ownership_id` or a `user_id`. Exactly one of them must be provided. Require the admin scope of presigning any file.
+// @Tags File
+// @Accept json
+// @Produce json
+// @Param body body dto.RevokeFileTokenRequest true "Revoke file token request"
+// @Success 200 {object} response.SwaggerSuccessResponse[dto.RevokeFileTokenResponse] "Successfully revoke the file tokens"
+// @Failure 400 {object} response.SwaggerBadRequestErrorResponse "Bad request"
+// @Failure 403 {object} response.SwaggerForbiddenErrorResponse "Forbidden"
+// @Router /files/token/revoke [post]
+func (a *FileAdapter) RevokeFileToken() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req, err := xhttp.ParseHTTPRequest[dto.RevokeFileTokenRequest](r)
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		ucreq, err := req.To()
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		resp, err := a.fileUsecase.RevokeFileToken(ctx, ucreq)
+		response.NewRESTResponseHandler(ctx, dto.NewRevokeFileTokenResponse(resp), err).
+			Map(http.StatusBadRequest, errordef.ErrRequestInvalid).
+			Map(http.StatusForbidden, errordef.ErrForbidden).
+			WriteHTTPResponse(ctx, w)
+	}
+}
+
+// @Summary List file references.
+// @Description List the entities referencing the file of an ownership. Require the admin scope.
+// @Tags File
+// @Produce json
+// @Param ownership_id path string true "ownership id"
+// @Success 200 {object} response.SwaggerSuccessResponse[dto.ListReferencesResponse] "Successfully list the references"
+// @Failure 403 {object} response.SwaggerForbiddenErrorResponse "Forbidden"
+// @Failure 404 "Not found ownership"
+// @Router /files/{ownership_id}/references [get]
+func (a *FileAdapter) ListReferences() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req, err := xhttp.ParseHTTPRequest[dto.ListReferencesRequest](r)
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		resp, err := a.fileUsecase.ListReferences(ctx, req.To())
+		response.NewRESTResponseHandler(ctx, dto.NewListReferencesResponse(resp), err).
+			Map(http.StatusForbidden, errordef.ErrForbidden).
+			Map(http.StatusNotFound, errordef.ErrNotFound).
+			WriteHTTPResponse(ctx, w)
+	}
+}
+
+// @Summary Get file info.
+// @Description Get the metadata of a file owned by the user. The dimensions and format are returned for images.
+// @Tags File
+// @Produce json
+// @Param ownership_id path string true "ownership id"
+// @Success 200 {object} response.SwaggerSuccessResponse[dto.GetFileInfoResponse] "Successfully get the file info"
+// @Failure 403 {object} response.SwaggerForbiddenErrorResponse "Forbidden"
+// @Router /files/{ownership_id} [get]
+func (a *FileAdapter) GetFileInfo() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req, err := xhttp.ParseHTTPRequest[dto.GetFileInfoRequest](r)
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		resp, err := a.fileUsecase.GetFileInfo(ctx, req.To())
+		response.NewRESTResponseHandler(ctx, dto.NewGetFileInfoResponse(resp), err).
+			Map(http.StatusForbidden, errordef.ErrForbidden).
+			WriteHTTPResponse(ctx, w)
+	}
+}
+
+// @Summary Get file ownership.
+// @Description Get an ownership together with the metadata of its file. Only the owner or an admin service can get the ownership.
+// @Tags File
+// @Produce json
+// @Param ownership_id path string true "ownership id"
+// @Success 200 {object} response.SwaggerSuccessResponse[dto.Ownership] "Successfully get the ownership"
+// @Failure 403 {object} response.SwaggerForbiddenErrorResponse "Forbidden"
+// @Router /files/{ownership_id}/ownership [get]
+func (a *FileAdapter) GetOwnership() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req, err := xhttp.ParseHTTPRequest[dto.GetOwnershipRequest](r)
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		resp, err := a.fileUsecase.GetOwnership(ctx, req.To())
+		response.NewRESTResponseHandler(ctx, dto.NewGetOwnershipResponse(resp), err).
+			Map(http.StatusForbidden, errordef.ErrForbidden).
+			WriteHTTPResponse(ctx, w)
+	}
+}
+
+// @Summary List own files.
+// @Description List the ownerships of the user from the newest to the oldest. Use the `next_cursor` of a page as the `cursor` of the next page.
+// @Tags File
+// @Produce json
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "page size, 20 by default, at most 100"
+// @Param type query string false "MIME type or type/* pattern"
+// @Param bucket query string false "bucket"
+// @Param created_after query string false "RFC 3339 time when the file was added to the user's files, inclusive"
+// @Param created_before query string false "RFC 3339 time when the file was added to the user's files, exclusive"
+// @Success 200 {object} response.SwaggerSuccessResponse[dto.ListOwnershipsResponse] "Successfully list the files"
+// @Failure 400 {object} response.SwaggerBadRequestErrorResponse "Bad request"
+// @Router /files [get]
+func (a *FileAdapter) ListOwnerships() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req, err := dto.NewListOwnershipsRequest(r)
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		resp, err := a.fileUsecase.ListOwnerships(ctx, req.To())
+		response.NewRESTResponseHandler(ctx, dto.NewListOwnershipsResponse(resp), err).
+			Map(http.StatusBadRequest, errordef.ErrRequestInvalid).
+			WriteHTTPResponse(ctx, w)
+	}
+}
+
+// @Summary Update own file.
+// @Description Set the display name and the description of an ownership. The fields which are not present are not changed.
+// @Tags File
+// @Accept json
+// @Produce json
+// @Param ownership_id path string true "ownership id"
+// @Param body body dto.UpdateOwnershipRequest true "Update ownership request"
+// @Success 200 {object} response.SwaggerSuccessResponse[dto.Ownership] "Successfully update the ownership"
+// @Failure 400 {object} response.SwaggerBadRequestErrorResponse "Bad request"
+// @Failure 403 {object} response.SwaggerForbiddenErrorResponse "Forbidden"
+// @Router /files/{ownership_id} [patch]
+func (a *FileAdapter) UpdateOwnership() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req, err := xhttp.ParseHTTPRequest[dto.UpdateOwnershipRequest](r)
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		resp, err := a.fileUsecase.UpdateOwnership(ctx, req.To())
+		response.NewRESTResponseHandler(ctx, dto.NewUpdateOwnershipResponse(resp), err).
+			Map(http.StatusBadRequest, errordef.ErrRequestInvalid).
+			Map(http.StatusForbidden, errordef.ErrForbidden).
+			WriteHTTPResponse(ctx, w)
+	}
+}
+
+// @Summary Delete own file.
+// @Description Delete an ownership which is not referenced anymore. The content is removed later if no other ownership holds the file.
+// @Tags File
+// @Produce json
+// @Param ownership_id path string true "ownership id"
+// @Success 200 {object} response.SwaggerSuccessResponse[dto.DeleteOwnershipResponse] "Successfully delete the ownership"
+// @Failure 400 {object} response.SwaggerBadRequestErrorResponse "The file is still referenced"
+// @Failure 403 {object} response.SwaggerForbiddenErrorResponse "Forbidden"
+// @Router /files/{ownership_id} [delete]
+func (a *FileAdapter) DeleteOwnership() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req, err := xhttp.ParseHTTPRequest[dto.DeleteOwnershipRequest](r)
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		resp, err := a.fileUsecase.DeleteOwnership(ctx, req.To())
+		response.NewRESTResponseHandler(ctx, dto.NewDeleteOwnershipResponse(resp), err).
+			Map(http.StatusBadRequest, errordef.ErrRequestInvalid).
+			Map(http.StatusForbidden, errordef.ErrForbidden).
+			WriteHTTPResponse(ctx, w)
+	}
+}
+
+// @Summary Batch get file ownerships.
+// @Description Get up to 100 ownerships in one request, in the requested order. The ownerships which don't exist or are not accessible to the caller are omitted.
+// @Tags File
+// @Accept json
+// @Produce json
+// @Param body body dto.BatchGetOwnershipsRequest true "Ownership ids"
+// @Success 200 {object} response.SwaggerSuccessResponse[dto.BatchGetOwnershipsResponse] "Successfully get the ownerships"
+// @Failure 400 {object} response.SwaggerBadRequestErrorResponse "Bad request"
+// @Router /files/ownerships/batch [post]
+func (a *FileAdapter) BatchGetOwnerships() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req, err := xhttp.ParseHTTPRequest[dto.BatchGetOwnershipsRequest](r)
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		ucreq, err := req.To()
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		resp, err := a.fileUsecase.BatchGetOwnerships(ctx, ucreq)
+		response.NewRESTResponseHandler(ctx, dto.NewBatchGetOwnershipsResponse(resp), err).
+			Map(http.StatusBadRequest, errordef.ErrRequestInvalid).
+			WriteHTTPResponse(ctx, w)
+	}
+}
+
+// @Summary Download file.
+// @Description Download the content of a file owned by the user. Support `Range`, `If-None-Match` and `If-Modified-Since` headers. Only raster images are rendered inline, other files are sent as attachments.
+// @Tags File
+// @Produce octet-stream
+// @Param ownership_id path string true "ownership id"
+// @Param variant query string false "name of an image variant"
+// @Success 200 {file} binary "The file content"
+// @Success 206 {file} binary "The requested range of the file content"
+// @Success 304 "Not modified"
+// @Failure 403 {object} response.SwaggerForbiddenErrorResponse "Forbidden"
+// @Router /files/{ownership_id}/content [get]
+func (a *FileAdapter) Download() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req, err := xhttp.ParseHTTPRequest[dto.DownloadRequest](r)
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		ucreq := req.To()
+		ucreq.Variant = r.URL.Query().Get("variant")
+
+		resp, err := a.fileUsecase.Download(ctx, ucreq)
+		if err != nil {
+			writeDownloadError(w, r, err)
+			return
+		}
+
+		serveFileContent(w, r, resp)
+	}
+}
+
+// @Summary Download file by file token.
+// @Description Download the content of a file using a `file_token`, no authentication is required. Support `Range`, `If-None-Match` and `If-Modified-Since` headers. Only raster images are rendered inline, other files are sent as attachments.
+// @Tags File
+// @Produce octet-stream
+// @Param file_token path string true "file token"
+// @Param variant query string false "name of an image variant"
+// @Success 200 {file} binary "The file content"
+// @Success 206 {file} binary "The requested range of the file content"
+// @Success 304 "Not modified"
+// @Failure 403 {object} response.SwaggerForbiddenErrorResponse "Forbidden"
+// @Router /files/content/{file_token} [get]
+func (a *FileAdapter) DownloadByFileToken() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req, err := xhttp.ParseHTTPRequest[dto.DownloadByFileTokenRequest](r)
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		ucreq := req.To()
+		ucreq.Variant = r.URL.Query().Get("variant")
+
+		resp, err := a.fileUsecase.DownloadByFileToken(ctx, ucreq)
+		if err != nil {
+			writeDownloadError(w, r, err)
+			return
+		}
+
+		serveFileContent(w, r, resp)
+	}
+}
+
+// serveFileContent writes the file content, http.ServeContent takes care of
+// HEAD, Range and conditional requests. The file ID is the hash of the
+// content, so it is used as a strong ETag.
+//
+// The content is uploaded by users and served on the origin of the API, so
+// only the safe raster images are rendered inline. Anything else is downloaded
+// as an attachment in a sandbox, which prevents a stored HTML or SVG file from
+// running scripts on this origin.
+func serveFileContent(w http.ResponseWriter, r *http.Request, resp *ucdto.DownloadResponse) {
+	defer resp.Content.Close()
+
+	w.Header().Set("Content-Type", resp.Type)
+	w.Header().Set("ETag", fmt.Sprintf("%q", resp.FileID))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	if !resp.Inline {
+		w.Header().Set("Content-Disposition", "attachment")
+	}
+
+	http.ServeContent(w, r, "", resp.CreatedAt, resp.Content)
+}
+
+func writeDownloadError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	response.NewRESTResponseHandler(ctx, nil, err).
+		Map(http.StatusBadRequest, errordef.ErrRequestInvalid).
+		Map(http.StatusForbidden, errordef.ErrForbidden).
+		Map(http.StatusNotFound, errordef.ErrNotFound).
+		WriteHTTPResponse(ctx, w)
+}
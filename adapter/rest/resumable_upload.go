@@ -0,0 +1,192 @@
+package rest
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/todennus/file-service/adapter/rest/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/middleware"
+	"github.com/todennus/shared/response"
+)
+
+const tusVersion = "1.0.0"
+
+// ResumableUploadRouter serves the tus 1.0 protocol with the creation,
+// expiration and termination extensions.
+//
+// The upload token must be given in the Upload-Metadata header of the creation
+// request, under the upload_token key. When the last chunk is received, the
+// PATCH request responds 200 with the same body as POST /files.
+func (a *FileAdapter) ResumableUploadRouter(r chi.Router) {
+	r.Options("/", a.ResumableUploadOptions())
+
+	r.Group(func(r chi.Router) {
+		r.Use(requireTusResumable)
+
+		r.Post("/", middleware.RequireAuthentication(a.CreateResumableUpload()))
+		r.Head("/{upload_id}", middleware.RequireAuthentication(a.GetResumableUpload()))
+		r.Patch("/{upload_id}", middleware.RequireAuthentication(a.AppendResumableUpload()))
+		r.Delete("/{upload_id}", middleware.RequireAuthentication(a.TerminateResumableUpload()))
+	})
+}
+
+// @Summary Discover the tus server.
+// @Tags File
+// @Success 204 "The supported tus version and extensions"
+// @Router /files/tus [options]
+func (a *FileAdapter) ResumableUploadOptions() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", tusVersion)
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", "creation,expiration,termination")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// @Summary Create a resumable upload.
+// @Description Create a tus upload bound to the `upload_token` given in the `Upload-Metadata` header.
+// @Tags File
+// @Param Upload-Length header int true "total size of the file"
+// @Param Upload-Metadata header string true "must contain upload_token"
+// @Success 201 "Created, the upload URL is in the Location header"
+// @Failure 400 {object} response.SwaggerBadRequestErrorResponse "Bad request"
+// @Failure 403 {object} response.SwaggerForbiddenErrorResponse "Forbidden"
+// @Router /files/tus [post]
+func (a *FileAdapter) CreateResumableUpload() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		ucreq, err := dto.NewCreateResumableUploadRequest(r).To()
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		resp, err := a.fileUsecase.CreateResumableUpload(ctx, ucreq)
+		if err != nil {
+			writeResumableUploadError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Location", path.Join(r.URL.Path, resp.UploadID))
+		w.Header().Set("Upload-Expires", resp.ExpiresAt.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// @Summary Get the offset of a resumable upload.
+// @Tags File
+// @Param upload_id path string true "upload id"
+// @Success 200 "The offset is in the Upload-Offset header"
+// @Failure 404 "Not found or expired"
+// @Router /files/tus/{upload_id} [head]
+func (a *FileAdapter) GetResumableUpload() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		resp, err := a.fileUsecase.GetResumableUpload(ctx, dto.NewGetResumableUploadRequest(r).To())
+		if err != nil {
+			writeResumableUploadError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Upload-Offset", strconv.FormatInt(resp.Offset, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(resp.Length, 10))
+		w.Header().Set("Upload-Expires", resp.ExpiresAt.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// @Summary Append a chunk to a resumable upload.
+// @Description When the last chunk is received, the file is checked and stored like `POST /files`.
+// @Tags File
+// @Accept application/offset+octet-stream
+// @Produce json
+// @Param upload_id path string true "upload id"
+// @Param Upload-Offset header int true "offset of the chunk"
+// @Success 204 "The chunk is stored, the new offset is in the Upload-Offset header"
+// @Success 200 {object} response.SwaggerSuccessResponse[dto.UploadResponse] "The upload is completed"
+// @Failure 400 {object} response.SwaggerBadRequestErrorResponse "Bad request"
+// @Failure 409 "Mismatched offset"
+// @Router /files/tus/{upload_id} [patch]
+func (a *FileAdapter) AppendResumableUpload() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		ucreq, err := dto.NewAppendResumableUploadRequest(r).To()
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		resp, err := a.fileUsecase.AppendResumableUpload(ctx, ucreq)
+		if err != nil {
+			// Same as Upload, do not read the remaining body of a rejected chunk.
+			w.Header().Set("Connection", "close")
+			writeResumableUploadError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Upload-Offset", strconv.FormatInt(resp.Offset, 10))
+		w.Header().Set("Upload-Expires", resp.ExpiresAt.UTC().Format(http.TimeFormat))
+		if resp.Upload == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		response.NewRESTResponseHandler(ctx, dto.NewUploadResponse(resp.Upload), nil).
+			WithDefaultCode(http.StatusOK).
+			WriteHTTPResponse(ctx, w)
+	}
+}
+
+// @Summary Terminate a resumable upload.
+// @Tags File
+// @Param upload_id path string true "upload id"
+// @Success 204 "Terminated"
+// @Failure 404 "Not found or expired"
+// @Router /files/tus/{upload_id} [delete]
+func (a *FileAdapter) TerminateResumableUpload() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		_, err := a.fileUsecase.TerminateResumableUpload(ctx, dto.NewTerminateResumableUploadRequest(r).To())
+		if err != nil {
+			writeResumableUploadError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// requireTusResumable rejects the requests of an unsupported tus version and
+// marks all responses with the supported version.
+func requireTusResumable(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", tusVersion)
+
+		if r.Header.Get("Tus-Resumable") != tusVersion {
+			w.Header().Set("Tus-Version", tusVersion)
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeResumableUploadError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	response.NewRESTResponseHandler(ctx, nil, err).
+		Map(http.StatusBadRequest, errordef.ErrRequestInvalid, errordef.ErrFileInvalidContent, errordef.ErrFileMismatchedType).
+		Map(http.StatusConflict, errordef.ErrFileMismatchedSize).
+		Map(http.StatusRequestEntityTooLarge, errordef.ErrRequestTooLarge).
+		Map(http.StatusForbidden, errordef.ErrForbidden).
+		Map(http.StatusNotFound, errordef.ErrNotFound).
+		WriteHTTPResponse(ctx, w)
+}
@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/todennus/file-service/adapter/abstraction"
+	"github.com/todennus/file-service/adapter/rest/dto"
+	"github.com/todennus/shared/response"
+	"github.com/todennus/x/xhttp"
+)
+
+type TransformAdapter struct {
+	transformUsecase abstraction.TransformUsecase
+}
+
+func NewTransformAdapter(transformUsecase abstraction.TransformUsecase) *TransformAdapter {
+	return &TransformAdapter{transformUsecase: transformUsecase}
+}
+
+func (a *TransformAdapter) Router(r chi.Router) {
+	r.Get("/{signature}/{options}/{file_id}", a.Transform())
+	r.Head("/{signature}/{options}/{file_id}", a.Transform())
+}
+
+// @Summary Transform image.
+// @Description Download an image transformed with signed options, no authentication is required. The options are comma-separated operations: `crop:x:y:width:height`, `rotate:90|180|270`, `resize:fit|fill:width:height`, `format:jpeg|png|gif` and `quality:1-100`.
+// @Tags File
+// @Produce octet-stream
+// @Param signature path string true "signature of the options and the file id"
+// @Param options path string true "transformation options"
+// @Param file_id path string true "file id"
+// @Success 200 {file} binary "The transformed image"
+// @Success 206 {file} binary "The requested range of the transformed image"
+// @Success 304 "Not modified"
+// @Failure 400 {object} response.SwaggerBadRequestErrorResponse "Bad request"
+// @Failure 403 {object} response.SwaggerForbiddenErrorResponse "Forbidden"
+// @Failure 404 "Not found file"
+// @Router /files/transform/{signature}/{options}/{file_id} [get]
+func (a *TransformAdapter) Transform() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req, err := xhttp.ParseHTTPRequest[dto.TransformRequest](r)
+		if err != nil {
+			response.RESTWriteAndLogInvalidRequestError(ctx, w, err)
+			return
+		}
+
+		resp, err := a.transformUsecase.Transform(ctx, req.To())
+		if err != nil {
+			writeDownloadError(w, r, err)
+			return
+		}
+
+		serveFileContent(w, r, resp)
+	}
+}
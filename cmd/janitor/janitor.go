@@ -0,0 +1,61 @@
+package janitor
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/todennus/file-service/usecase/dto"
+	"github.com/todennus/file-service/wiring"
+)
+
+var Command = &cobra.Command{
+	Use:   "janitor",
+	Short: "Periodically delete unused files and unreferenced ownerships",
+	Run: func(cmd *cobra.Command, args []string) {
+		envPaths, err := cmd.Flags().GetStringArray("env")
+		if err != nil {
+			panic(err)
+		}
+
+		once, err := cmd.Flags().GetBool("once")
+		if err != nil {
+			panic(err)
+		}
+
+		system, err := wiring.InitializeSystem(envPaths...)
+		if err != nil {
+			panic(err)
+		}
+
+		interval := time.Duration(system.ServiceConfig.Janitor.Interval) * time.Second
+
+		slog.Info("Janitor started", "interval", interval, "once", once)
+		for {
+			resp, err := system.Usecases.JanitorUsecase.CleanUp(context.Background(), &dto.CleanUpRequest{})
+			if err != nil {
+				slog.Error("Janitor failed to clean up", "err", err)
+			} else {
+				slog.Info("Janitor cleaned up",
+					"failed_stale_files", resp.FailedStaleFiles,
+					"rescanned_files", resp.RescannedFiles,
+					"deleted_ownerships", resp.DeletedOwnerships,
+					"deleted_files", resp.DeletedFiles,
+					"deleted_temporary_objects", resp.DeletedTemporaryObjects,
+					"deleted_cached_objects", resp.DeletedCachedObjects,
+				)
+			}
+
+			if once {
+				return
+			}
+
+			time.Sleep(interval)
+		}
+	},
+}
+
+func init() {
+	Command.Flags().Bool("once", false, "run a single clean-up pass and exit (e.g. when scheduled by cron)")
+}
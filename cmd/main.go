@@ -3,6 +3,9 @@ package main
 import (
 	"github.com/spf13/cobra"
 	"github.com/todennus/file-service/cmd/grpc"
+	"github.com/todennus/file-service/cmd/janitor"
+	"github.com/todennus/file-service/cmd/relay"
+	"github.com/todennus/file-service/cmd/relocate"
 	"github.com/todennus/file-service/cmd/rest"
 )
 
@@ -15,6 +18,9 @@ func main() {
 	rootCommand.PersistentFlags().StringArray("env", []string{".env"}, "environment file paths")
 	rootCommand.AddCommand(rest.Command)
 	rootCommand.AddCommand(grpc.Command)
+	rootCommand.AddCommand(janitor.Command)
+	rootCommand.AddCommand(relay.Command)
+	rootCommand.AddCommand(relocate.Command)
 
 	if err := rootCommand.Execute(); err != nil {
 		panic(err)
@@ -0,0 +1,41 @@
+package relay
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/todennus/file-service/usecase/dto"
+	"github.com/todennus/file-service/wiring"
+)
+
+var Command = &cobra.Command{
+	Use:   "relay",
+	Short: "Publish the file events of the outbox to the Redis stream",
+	Run: func(cmd *cobra.Command, args []string) {
+		envPaths, err := cmd.Flags().GetStringArray("env")
+		if err != nil {
+			panic(err)
+		}
+
+		system, err := wiring.InitializeSystem(envPaths...)
+		if err != nil {
+			panic(err)
+		}
+
+		interval := time.Duration(system.ServiceConfig.Outbox.Interval) * time.Millisecond
+
+		slog.Info("Relay started", "stream", system.ServiceConfig.Outbox.Stream, "interval", interval)
+		for {
+			resp, err := system.Usecases.RelayUsecase.Relay(context.Background(), &dto.RelayRequest{})
+			if err != nil {
+				slog.Error("Relay failed to publish events", "err", err)
+			} else if resp.Published > 0 {
+				slog.Debug("Relay published events", "published", resp.Published)
+			}
+
+			time.Sleep(interval)
+		}
+	},
+}
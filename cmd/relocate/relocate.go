@@ -0,0 +1,47 @@
+package relocate
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+	"github.com/todennus/file-service/usecase/dto"
+	"github.com/todennus/file-service/wiring"
+)
+
+var Command = &cobra.Command{
+	Use:   "relocate",
+	Short: "Move the stored files to the buckets of the current bucket rules",
+	Run: func(cmd *cobra.Command, args []string) {
+		envPaths, err := cmd.Flags().GetStringArray("env")
+		if err != nil {
+			panic(err)
+		}
+
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			panic(err)
+		}
+
+		system, err := wiring.InitializeSystem(envPaths...)
+		if err != nil {
+			panic(err)
+		}
+
+		slog.Info("Relocation started", "dry_run", dryRun)
+		resp, err := system.Usecases.RelocationUsecase.Relocate(context.Background(), &dto.RelocateRequest{DryRun: dryRun})
+		if err != nil {
+			slog.Error("Relocation failed", "err", err)
+		}
+
+		slog.Info("Relocation finished",
+			"checked_files", resp.Checked,
+			"relocated_files", resp.Relocated,
+			"failed_files", resp.Failed,
+		)
+	},
+}
+
+func init() {
+	Command.Flags().Bool("dry-run", false, "only count the files which would be relocated")
+}
@@ -26,6 +26,10 @@ var Command = &cobra.Command{
 
 		address := fmt.Sprintf("%s:%d", system.Config.Variable.Server.Host, system.Config.Variable.Server.Port)
 		app := rest.App(system.Config, system.Usecases)
+		if system.Infras.LocalStorage != nil {
+			// The presigned URLs of the local storage are served by this server.
+			app.Mount(system.Infras.LocalStorage.MountPath(), system.Infras.LocalStorage)
+		}
 
 		slog.Info("Server started", "address", address)
 		if err := http.ListenAndServe(address, app); err != nil {
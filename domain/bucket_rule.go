@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AnyBucketRuleValue matches any size or purpose in a bucket rule.
+const AnyBucketRuleValue = "*"
+
+var purposeRegex = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// ValidatePurpose checks the purpose tag of an upload policy, which may be
+// empty.
+func ValidatePurpose(purpose string) error {
+	if purpose != "" && !purposeRegex.MatchString(purpose) {
+		return fmt.Errorf("invalid purpose %q", purpose)
+	}
+
+	return nil
+}
+
+// BucketRule routes the files matching all of its conditions to its bucket.
+type BucketRule struct {
+	// Pattern matches the content type, e.g. video/*.
+	Pattern string
+
+	// MinSize and MaxSize bound the size of the file in bytes, inclusively. A
+	// zero MaxSize means no upper bound.
+	MinSize int
+	MaxSize int
+
+	// Purpose is the purpose of the upload policy which the file has been
+	// uploaded with, or empty to match any purpose.
+	Purpose string
+
+	// Bucket may contain a folder path, e.g. media/videos.
+	Bucket string
+}
+
+func (rule BucketRule) Matches(contentType string, size int, purpose string) bool {
+	if rule.Purpose != "" && rule.Purpose != purpose {
+		return false
+	}
+
+	if size < rule.MinSize || (rule.MaxSize != 0 && size > rule.MaxSize) {
+		return false
+	}
+
+	return MatchTypePattern(rule.Pattern, contentType)
+}
+
+// ParseBucketRules parses the rule definitions in the form
+// pattern:size:purpose:bucket, where size is min-max with optional bounds and
+// both size and purpose may be *, e.g. application/zip:104857600-:*:archives.
+func ParseBucketRules(definitions []string) ([]BucketRule, error) {
+	rules := make([]BucketRule, 0, len(definitions))
+
+	for _, definition := range definitions {
+		definition = strings.TrimSpace(definition)
+		if definition == "" {
+			continue
+		}
+
+		parts := strings.Split(definition, ":")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("invalid bucket rule %q, expected pattern:size:purpose:bucket", definition)
+		}
+
+		rule := BucketRule{Pattern: parts[0], Bucket: parts[3]}
+		if err := ValidateTypePattern(rule.Pattern); err != nil {
+			return nil, fmt.Errorf("invalid bucket rule %q: %w", definition, err)
+		}
+
+		if parts[1] != AnyBucketRuleValue {
+			minSize, maxSize, found := strings.Cut(parts[1], "-")
+			if !found {
+				return nil, fmt.Errorf("invalid size of bucket rule %q, expected min-max", definition)
+			}
+
+			var err error
+			if rule.MinSize, err = parseRuleSize(minSize); err != nil {
+				return nil, fmt.Errorf("invalid min size of bucket rule %q", definition)
+			}
+
+			if rule.MaxSize, err = parseRuleSize(maxSize); err != nil || (rule.MaxSize != 0 && rule.MaxSize < rule.MinSize) {
+				return nil, fmt.Errorf("invalid max size of bucket rule %q", definition)
+			}
+		}
+
+		if parts[2] != AnyBucketRuleValue {
+			if !purposeRegex.MatchString(parts[2]) {
+				return nil, fmt.Errorf("invalid purpose of bucket rule %q", definition)
+			}
+
+			rule.Purpose = parts[2]
+		}
+
+		if rule.Bucket == "" || strings.HasPrefix(rule.Bucket, "/") {
+			return nil, fmt.Errorf("invalid bucket of bucket rule %q", definition)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// parseRuleSize parses a bound of a size range, an empty bound is zero.
+func parseRuleSize(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	size, err := strconv.Atoi(s)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	return size, nil
+}
@@ -1,6 +1,10 @@
 package domain
 
 import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/todennus/x/mime"
@@ -12,18 +16,98 @@ type UploadPolicy struct {
 	Token string
 
 	// AllowedTypes specifies the permitted content types for the uploaded file.
-	// It defines which MIME types are acceptable for file uploads.
+	// It defines which MIME types are acceptable for file uploads, as exact
+	// types or patterns like image/*. The type groups are already expanded.
 	AllowedTypes []string
 
+	// DeniedTypes are the types which are rejected even if they match
+	// AllowedTypes.
+	DeniedTypes []string
+
 	// Maxsize defines the maximum size, in bytes, of the uploaded file.
 	MaxSize int64
 
 	// UserID represents who can upload file.
 	UserID snowflake.ID
 
+	// StagingKey is the name of the temporary object which the file is
+	// uploaded to directly, without going through the service. It is empty if
+	// the policy does not allow direct uploads.
+	StagingKey string
+
+	// StripMetadata removes the EXIF, XMP and IPTC metadata of uploaded images
+	// and applies their EXIF orientation before storing them. The original
+	// content is discarded.
+	StripMetadata bool
+
+	// SanitizeSVG removes scripts, event handlers and external references from
+	// uploaded SVG images. SVG images are only allowed with this option.
+	SanitizeSVG bool
+
+	// ImageLimits restricts the dimensions of uploaded images.
+	ImageLimits ImageLimits
+
+	// Purpose tags the uploaded file, so that the bucket rules can route the
+	// files of some purposes (e.g. backups) to their own buckets.
+	Purpose string
+
+	// DeclaredFileID and DeclaredSize are set when the client has declared the
+	// content of the file before uploading it, but the file was not stored
+	// yet. The uploaded file must then match the declaration.
+	DeclaredFileID string
+	DeclaredSize   int64
+
 	ExpiresAt time.Time
 }
 
+// AllowsType returns true if the content type matches the allowed types and
+// none of the denied ones. SVG images are never allowed without sanitization,
+// even by a pattern like image/*.
+func (policy *UploadPolicy) AllowsType(contentType string) bool {
+	if contentType == SVGContentType && !policy.SanitizeSVG {
+		return false
+	}
+
+	return MatchAnyTypePattern(policy.AllowedTypes, contentType) &&
+		!MatchAnyTypePattern(policy.DeniedTypes, contentType)
+}
+
+// SanitizesContent returns true if the uploaded content of the type is
+// rewritten before being stored.
+func (policy *UploadPolicy) SanitizesContent(contentType string) bool {
+	if contentType == SVGContentType {
+		return policy.SanitizeSVG
+	}
+
+	return policy.StripMetadata && mime.IsImage(contentType)
+}
+
+// MatchesDeclaration returns false if the file differs from the content which
+// has been declared by the client.
+func (policy *UploadPolicy) MatchesDeclaration(file *FileInfo) bool {
+	if policy.DeclaredFileID != "" && policy.DeclaredFileID != file.ID {
+		return false
+	}
+
+	if policy.DeclaredSize != 0 && policy.DeclaredSize != int64(file.Metadata.Size) {
+		return false
+	}
+
+	return true
+}
+
+// PresignedUpload contains the ways to upload the file directly to the storage.
+type PresignedUpload struct {
+	// PutURL accepts the file as the body of a PUT request.
+	PutURL string
+
+	// PostURL accepts the file in a multipart POST request, the form must
+	// contain PostFormData. Unlike PutURL, the storage rejects files larger
+	// than the policy max size.
+	PostURL      string
+	PostFormData map[string]string
+}
+
 // FileMetadata contains additional information about a file.
 type FileMetadata struct {
 	// Bucket determines where this file is stored (it usually is the folder name).
@@ -34,12 +118,172 @@ type FileMetadata struct {
 
 	// Size is the size of the file content in bytes.
 	Size int
+
+	// Image is set if the file is an image whose format can be decoded.
+	Image *ImageMetadata
+
+	// Purpose is the purpose of the upload policy which the file has first
+	// been uploaded with.
+	Purpose string
+}
+
+// MaxDirectUploadSize is the largest object which an object storage accepts in
+// a single request, which is how the content of a direct upload is sent.
+const MaxDirectUploadSize = 5 << 30 // 5GiB
+
+// SVGContentType is the type of SVG images, which are XML documents and may
+// carry scripts.
+const SVGContentType = "image/svg+xml"
+
+// inlineContentTypes are the raster image types which browsers render without
+// running any script.
+var inlineContentTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif", "image/bmp"}
+
+// IsInlineContentType returns true if the content can be rendered by browsers
+// on the origin of the service. Any other content may be HTML, SVG or another
+// active content, so it must be served as an attachment.
+func IsInlineContentType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return slices.Contains(inlineContentTypes, strings.ToLower(strings.TrimSpace(mediaType)))
+}
+
+// ImageMetadata is read from the header of an image when it is uploaded.
+type ImageMetadata struct {
+	// Format is the name of the image format, e.g. png, jpeg or gif.
+	Format string
+
+	Width  int
+	Height int
 }
 
+// ImageLimits restricts the images which can be uploaded, so that decoding
+// them doesn't exhaust the memory. A zero value means no limit.
+type ImageLimits struct {
+	MaxWidth      int
+	MaxHeight     int
+	MaxMegapixels float64
+
+	// MaxFrames is the maximum number of frames of animated images.
+	MaxFrames int
+
+	// MaxTotalMegapixels bounds the pixels of all frames together, which are
+	// decoded one after another.
+	MaxTotalMegapixels float64
+}
+
+// IsZero returns true if no limit is set.
+func (limits ImageLimits) IsZero() bool {
+	return limits == ImageLimits{}
+}
+
+// Or returns the limits where the unset ones are taken from fallback.
+func (limits ImageLimits) Or(fallback ImageLimits) ImageLimits {
+	if limits.MaxWidth == 0 {
+		limits.MaxWidth = fallback.MaxWidth
+	}
+
+	if limits.MaxHeight == 0 {
+		limits.MaxHeight = fallback.MaxHeight
+	}
+
+	if limits.MaxMegapixels == 0 {
+		limits.MaxMegapixels = fallback.MaxMegapixels
+	}
+
+	if limits.MaxFrames == 0 {
+		limits.MaxFrames = fallback.MaxFrames
+	}
+
+	if limits.MaxTotalMegapixels == 0 {
+		limits.MaxTotalMegapixels = fallback.MaxTotalMegapixels
+	}
+
+	return limits
+}
+
+// Check returns an error describing the first exceeded limit.
+func (limits ImageLimits) Check(width, height, frames int) error {
+	if limits.MaxWidth > 0 && width > limits.MaxWidth {
+		return fmt.Errorf("image width %d exceeds the limit %d", width, limits.MaxWidth)
+	}
+
+	if limits.MaxHeight > 0 && height > limits.MaxHeight {
+		return fmt.Errorf("image height %d exceeds the limit %d", height, limits.MaxHeight)
+	}
+
+	if limits.MaxMegapixels > 0 && float64(width)*float64(height) > limits.MaxMegapixels*1e6 {
+		return fmt.Errorf("image of %dx%d pixels exceeds the limit of %g megapixels", width, height, limits.MaxMegapixels)
+	}
+
+	if limits.MaxFrames > 0 && frames > limits.MaxFrames {
+		return fmt.Errorf("image of %d frames exceeds the limit %d", frames, limits.MaxFrames)
+	}
+
+	if limits.MaxTotalMegapixels > 0 && float64(width)*float64(height)*float64(frames) > limits.MaxTotalMegapixels*1e6 {
+		return fmt.Errorf("image of %d frames of %dx%d pixels exceeds the limit of %g megapixels in total",
+			frames, width, height, limits.MaxTotalMegapixels)
+	}
+
+	return nil
+}
+
+// FileStatus tells whether the content of a file is in the storage.
+type FileStatus string
+
+const (
+	// FileStatusPending means an uploader is storing the content.
+	FileStatusPending FileStatus = "pending"
+
+	// FileStatusStored means the content is in the storage. Only stored files
+	// can be owned.
+	FileStatusStored FileStatus = "stored"
+
+	// FileStatusFailed means the content could not be stored. The next uploader
+	// of the same content stores it again.
+	FileStatusFailed FileStatus = "failed"
+)
+
+// ScanStatus tells whether the content of a file has been scanned for malware.
+type ScanStatus string
+
+const (
+	// ScanStatusSkipped means the content was stored while no scanner was
+	// configured.
+	ScanStatusSkipped ScanStatus = "skipped"
+
+	// ScanStatusPending means the content is scanned asynchronously and the
+	// scan has not completed yet. The janitor retries the scans which have
+	// been pending for too long.
+	ScanStatusPending ScanStatus = "pending"
+
+	// ScanStatusClean means no threat was detected in the content.
+	ScanStatusClean ScanStatus = "clean"
+
+	// ScanStatusInfected means a threat was detected in the content.
+	ScanStatusInfected ScanStatus = "infected"
+)
+
 type FileInfo struct {
 	ID        string
 	Metadata  *FileMetadata
+	Status    FileStatus
 	CreatedAt time.Time
+
+	// UpdatedAt is the last time the status was changed. A file which has been
+	// pending for too long is considered failed.
+	UpdatedAt time.Time
+
+	// Threat is the name of the malware detected in the content. A file with
+	// a threat is kept in the quarantine bucket and never served.
+	Threat string
+
+	// ScanStatus is pending until the asynchronous scan of the content
+	// completes.
+	ScanStatus ScanStatus
+}
+
+func (file *FileInfo) IsQuarantined() bool {
+	return file.Threat != ""
 }
 
 type FileOwnership struct {
@@ -47,8 +291,36 @@ type FileOwnership struct {
 	FileID   string
 	UserID   snowflake.ID
 	RefCount int
+
+	// DisplayName and Description are set by the owner to recognize the file,
+	// they are empty by default.
+	DisplayName string
+	Description string
+
+	// UpdatedAt is the last time the ownership (including its refcount) was
+	// changed. The janitor uses it to know how long an ownership has been
+	// unreferenced.
+	UpdatedAt time.Time
 }
 
+// FileReference records that an entity of another service uses the file of an
+// ownership. The refcount of the ownership is the number of its references.
+type FileReference struct {
+	OwnershipID snowflake.ID
+	Service     string
+	EntityType  string
+	EntityID    string
+	CreatedAt   time.Time
+}
+
+// The references created by the old ChangeRefcount API, which does not tell
+// what references the file. Each increment is a legacy reference with a unique
+// entity ID, and each decrement removes any of them.
+const (
+	LegacyReferenceService    = "legacy"
+	LegacyReferenceEntityType = "refcount"
+)
+
 type FileToken struct {
 	ID          snowflake.ID
 	OwnershipID snowflake.ID
@@ -60,13 +332,48 @@ type FileToken struct {
 	ExpiresAt   time.Time
 }
 
+// IsRevokedBy returns true if the revocation applies to the token.
+func (token *FileToken) IsRevokedBy(revocation *FileTokenRevocation) bool {
+	switch {
+	case revocation.TokenID != 0:
+		return revocation.TokenID == token.ID
+	case revocation.OwnershipID != 0 && revocation.OwnershipID != token.OwnershipID:
+		return false
+	case revocation.UserID != 0 && revocation.UserID != token.UserID:
+		return false
+	}
+
+	// A token issued before the revocation expires at ExpiresAt at the
+	// latest. The expiration of the token has a second precision, so the
+	// tokens issued in the same second as the revocation may be revoked too.
+	return !token.ExpiresAt.After(revocation.ExpiresAt)
+}
+
+// FileTokenRevocation revokes a single token, or all tokens of an ownership or
+// of a user which were issued before the revocation. Exactly one of TokenID,
+// OwnershipID and UserID is set.
+type FileTokenRevocation struct {
+	TokenID     snowflake.ID
+	OwnershipID snowflake.ID
+	UserID      snowflake.ID
+
+	// ExpiresAt is when all revoked tokens have expired, so the revocation can
+	// be forgotten.
+	ExpiresAt time.Time
+}
+
 type FileDomain struct {
 	snowflake            *snowflake.Node
 	fileTokenExpiration  time.Duration
 	fileUploadExpiration time.Duration
 
-	imageBucketName string
-	otherBucketName string
+	imageBucketName      string
+	otherBucketName      string
+	quarantineBucketName string
+
+	// bucketRules are evaluated in order before falling back to the image and
+	// other buckets.
+	bucketRules []BucketRule
 }
 
 func NewFileDomain(
@@ -75,6 +382,8 @@ func NewFileDomain(
 	fileUploadExpiration time.Duration,
 	imageBucketName string,
 	otherBucketName string,
+	quarantineBucketName string,
+	bucketRules []BucketRule,
 ) *FileDomain {
 	return &FileDomain{
 		snowflake:            snowflake,
@@ -82,44 +391,118 @@ func NewFileDomain(
 		fileUploadExpiration: fileUploadExpiration,
 		imageBucketName:      imageBucketName,
 		otherBucketName:      otherBucketName,
+		quarantineBucketName: quarantineBucketName,
+		bucketRules:          bucketRules,
 	}
 }
 
-func (domain *FileDomain) NewUploadPolicy(userID snowflake.ID, allowedTypes []string, maxSize int64) *UploadPolicy {
-	return &UploadPolicy{
-		Token:        xcrypto.RandToken(),
-		UserID:       userID,
-		AllowedTypes: allowedTypes,
-		MaxSize:      maxSize,
-		ExpiresAt:    time.Now().Add(domain.fileUploadExpiration),
+func (domain *FileDomain) NewUploadPolicy(
+	userID snowflake.ID,
+	allowedTypes []string,
+	deniedTypes []string,
+	maxSize int64,
+	direct bool,
+	stripMetadata bool,
+	sanitizeSVG bool,
+	imageLimits ImageLimits,
+	purpose string,
+) *UploadPolicy {
+	policy := &UploadPolicy{
+		Token:         xcrypto.RandToken(),
+		UserID:        userID,
+		AllowedTypes:  allowedTypes,
+		DeniedTypes:   deniedTypes,
+		MaxSize:       maxSize,
+		StripMetadata: stripMetadata,
+		SanitizeSVG:   sanitizeSVG,
+		ImageLimits:   imageLimits,
+		Purpose:       purpose,
+		ExpiresAt:     time.Now().Add(domain.fileUploadExpiration),
+	}
+
+	if direct {
+		policy.StagingKey = domain.NewStagingKey()
 	}
+
+	return policy
+}
+
+// NewStagingKey returns a unique key where a content is kept until it is
+// checked and promoted to the location of its file.
+func (domain *FileDomain) NewStagingKey() string {
+	return path.Join("staging", domain.snowflake.Generate().String())
 }
 
-func (domain *FileDomain) ClassifyBucket(t string) string {
-	if mime.IsImage(t) {
+// ClassifyBucket returns the bucket of the first matching bucket rule, or the
+// image or other bucket if no rule matches.
+func (domain *FileDomain) ClassifyBucket(contentType string, size int, purpose string) string {
+	for _, rule := range domain.bucketRules {
+		if rule.Matches(contentType, size, purpose) {
+			return rule.Bucket
+		}
+	}
+
+	if mime.IsImage(contentType) {
 		return domain.imageBucketName
 	}
 
 	return domain.otherBucketName
 }
 
+// QuarantineFile marks the file as infected by the threat and moves it to the
+// quarantine bucket.
+func (domain *FileDomain) QuarantineFile(file *FileInfo, threat string) {
+	file.Threat = threat
+	file.ScanStatus = ScanStatusInfected
+	file.Metadata.Bucket = domain.quarantineBucketName
+}
+
 func (domain *FileDomain) NewFileInfo(id string, metadata *FileMetadata) *FileInfo {
+	now := time.Now()
 	return &FileInfo{
-		ID:        id,
-		Metadata:  metadata,
-		CreatedAt: time.Now(),
+		ID:         id,
+		Metadata:   metadata,
+		Status:     FileStatusPending,
+		ScanStatus: ScanStatusSkipped,
+		CreatedAt:  now,
+		UpdatedAt:  now,
 	}
 }
 
 func (domain *FileDomain) NewFileOwnership(fileID string, userID snowflake.ID) *FileOwnership {
 	return &FileOwnership{
-		ID:       domain.snowflake.Generate(),
-		FileID:   fileID,
-		UserID:   userID,
-		RefCount: 0,
+		ID:        domain.snowflake.Generate(),
+		FileID:    fileID,
+		UserID:    userID,
+		RefCount:  0,
+		UpdatedAt: time.Now(),
 	}
 }
 
+func (domain *FileDomain) NewFileReference(
+	ownershipID snowflake.ID,
+	service string,
+	entityType string,
+	entityID string,
+) *FileReference {
+	return &FileReference{
+		OwnershipID: ownershipID,
+		Service:     service,
+		EntityType:  entityType,
+		EntityID:    entityID,
+		CreatedAt:   time.Now(),
+	}
+}
+
+func (domain *FileDomain) NewLegacyFileReference(ownershipID snowflake.ID) *FileReference {
+	return domain.NewFileReference(
+		ownershipID,
+		LegacyReferenceService,
+		LegacyReferenceEntityType,
+		domain.snowflake.Generate().String(),
+	)
+}
+
 func (domain *FileDomain) NewFileToken(info *FileInfo, ownership *FileOwnership) *FileToken {
 	return &FileToken{
 		ID:          domain.snowflake.Generate(),
@@ -132,3 +515,64 @@ func (domain *FileDomain) NewFileToken(info *FileInfo, ownership *FileOwnership)
 		ExpiresAt:   time.Now().Add(domain.fileTokenExpiration),
 	}
 }
+
+func (domain *FileDomain) NewFileTokenRevocation(
+	tokenID snowflake.ID,
+	ownershipID snowflake.ID,
+	userID snowflake.ID,
+) *FileTokenRevocation {
+	return &FileTokenRevocation{
+		TokenID:     tokenID,
+		OwnershipID: ownershipID,
+		UserID:      userID,
+		ExpiresAt:   time.Now().Add(domain.fileTokenExpiration),
+	}
+}
+
+// ResumableUpload is an upload whose content is sent in several requests. The
+// chunks are kept in the storage until the upload is completed.
+type ResumableUpload struct {
+	ID string
+
+	// Policy is the upload policy which the upload is bound to. The upload
+	// expires together with the policy.
+	Policy *UploadPolicy
+
+	// Length is the total size, in bytes, of the uploaded file.
+	Length int64
+
+	// Offset is the number of bytes which have been received.
+	Offset int64
+
+	Chunks []ResumableUploadChunk
+}
+
+type ResumableUploadChunk struct {
+	Name string
+	Size int64
+}
+
+func (upload *ResumableUpload) IsCompleted() bool {
+	return upload.Offset == upload.Length
+}
+
+func (upload *ResumableUpload) Append(chunk ResumableUploadChunk) {
+	upload.Chunks = append(upload.Chunks, chunk)
+	upload.Offset += chunk.Size
+}
+
+func (domain *FileDomain) NewResumableUpload(policy *UploadPolicy, length int64) *ResumableUpload {
+	return &ResumableUpload{
+		ID:     xcrypto.RandToken(),
+		Policy: policy,
+		Length: length,
+		Offset: 0,
+	}
+}
+
+// NewResumableUploadChunkName returns a unique name for the next chunk of the
+// upload. Two requests which append at the same offset never write to the same
+// chunk.
+func (domain *FileDomain) NewResumableUploadChunkName(upload *ResumableUpload) string {
+	return path.Join("chunks", upload.ID, domain.snowflake.Generate().String())
+}
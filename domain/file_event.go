@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/xybor-x/snowflake"
+)
+
+type FileEventType string
+
+const (
+	FileEventFileStored               FileEventType = "file.stored"
+	FileEventFileDeleted              FileEventType = "file.deleted"
+	FileEventFileQuarantined          FileEventType = "file.quarantined"
+	FileEventOwnershipCreated         FileEventType = "ownership.created"
+	FileEventOwnershipRefcountChanged FileEventType = "ownership.refcount_changed"
+	FileEventOwnershipDeleted         FileEventType = "ownership.deleted"
+)
+
+// FileEvent notifies other services about a change of a file or its
+// ownerships. It is written to the outbox in the same transaction as the
+// change, then published at least once. Events of the same file are published
+// in the order they were committed.
+type FileEvent struct {
+	ID        snowflake.ID
+	Type      FileEventType
+	FileID    string
+	Payload   map[string]any
+	CreatedAt time.Time
+}
+
+func (domain *FileDomain) NewFileStoredEvent(file *FileInfo) *FileEvent {
+	payload := map[string]any{
+		"bucket": file.Metadata.Bucket,
+		"type":   file.Metadata.Type,
+		"size":   file.Metadata.Size,
+	}
+
+	if file.Metadata.Image != nil {
+		payload["image_format"] = file.Metadata.Image.Format
+		payload["image_width"] = file.Metadata.Image.Width
+		payload["image_height"] = file.Metadata.Image.Height
+	}
+
+	return domain.newFileEvent(FileEventFileStored, file.ID, payload)
+}
+
+func (domain *FileDomain) NewFileDeletedEvent(file *FileInfo) *FileEvent {
+	return domain.newFileEvent(FileEventFileDeleted, file.ID, map[string]any{})
+}
+
+func (domain *FileDomain) NewFileQuarantinedEvent(file *FileInfo) *FileEvent {
+	return domain.newFileEvent(FileEventFileQuarantined, file.ID, map[string]any{
+		"bucket": file.Metadata.Bucket,
+		"threat": file.Threat,
+	})
+}
+
+func (domain *FileDomain) NewOwnershipCreatedEvent(ownership *FileOwnership) *FileEvent {
+	return domain.newFileEvent(FileEventOwnershipCreated, ownership.FileID, map[string]any{
+		"ownership_id": ownership.ID.String(),
+		"user_id":      ownership.UserID.String(),
+	})
+}
+
+// NewOwnershipRefcountChangedEvent is written when the reference is added
+// (change is 1) or removed (change is -1).
+func (domain *FileDomain) NewOwnershipRefcountChangedEvent(
+	ownership *FileOwnership,
+	reference *FileReference,
+	change int,
+) *FileEvent {
+	return domain.newFileEvent(FileEventOwnershipRefcountChanged, ownership.FileID, map[string]any{
+		"ownership_id": ownership.ID.String(),
+		"user_id":      ownership.UserID.String(),
+		"change":       change,
+		"refcount":     ownership.RefCount,
+		"service":      reference.Service,
+		"entity_type":  reference.EntityType,
+		"entity_id":    reference.EntityID,
+	})
+}
+
+func (domain *FileDomain) NewOwnershipDeletedEvent(ownership *FileOwnership) *FileEvent {
+	return domain.newFileEvent(FileEventOwnershipDeleted, ownership.FileID, map[string]any{
+		"ownership_id": ownership.ID.String(),
+		"user_id":      ownership.UserID.String(),
+	})
+}
+
+func (domain *FileDomain) newFileEvent(eventType FileEventType, fileID string, payload map[string]any) *FileEvent {
+	return &FileEvent{
+		ID:        domain.snowflake.Generate(),
+		Type:      eventType,
+		FileID:    fileID,
+		Payload:   payload,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MaxOwnershipDisplayNameLength = 255
+	MaxOwnershipDescriptionLength = 2000
+)
+
+// ValidateOwnershipDetails checks the display name and the description which
+// the owner sets on an ownership. The lengths are counted in characters.
+func ValidateOwnershipDetails(displayName, description string) error {
+	if !utf8.ValidString(displayName) || !utf8.ValidString(description) {
+		return fmt.Errorf("invalid utf-8 string")
+	}
+
+	if utf8.RuneCountInString(displayName) > MaxOwnershipDisplayNameLength {
+		return fmt.Errorf("display name is too long (limit %d)", MaxOwnershipDisplayNameLength)
+	}
+
+	if utf8.RuneCountInString(description) > MaxOwnershipDescriptionLength {
+		return fmt.Errorf("description is too long (limit %d)", MaxOwnershipDescriptionLength)
+	}
+
+	return nil
+}
+
+// OwnershipFilter selects the ownerships of a user by the metadata of their
+// files and by when they were created. The zero value selects all ownerships.
+type OwnershipFilter struct {
+	// Type is a MIME type or a type/* pattern. A MIME type without parameters
+	// also matches the same type with any parameters.
+	Type string
+
+	Bucket string
+
+	// CreatedAfter and CreatedBefore bound the creation time of the ownership,
+	// not of its file which may have been uploaded by someone else earlier.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TypeGroupPrefix marks the names of type groups in the type patterns of the
+// upload policies, e.g. @images.
+const TypeGroupPrefix = "@"
+
+var (
+	typeGroupNameRegex = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+	// mimeTokenRegex matches the type and subtype of a MIME type (RFC 2045).
+	mimeTokenRegex = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+)
+
+// TypeGroups are named lists of type patterns which the upload policies refer
+// to instead of listing the types themselves.
+type TypeGroups map[string][]string
+
+// ParseTypeGroups parses the group definitions in the form name:pattern...,
+// where the patterns are separated by spaces, e.g. images:image/*.
+func ParseTypeGroups(definitions []string) (TypeGroups, error) {
+	groups := TypeGroups{}
+
+	for _, definition := range definitions {
+		definition = strings.TrimSpace(definition)
+		if definition == "" {
+			continue
+		}
+
+		name, patterns, found := strings.Cut(definition, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid type group %q, expected name:pattern...", definition)
+		}
+
+		if !typeGroupNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid type group name %q", name)
+		}
+
+		if _, ok := groups[name]; ok {
+			return nil, fmt.Errorf("duplicated type group %q", name)
+		}
+
+		groups[name] = strings.Fields(patterns)
+		if len(groups[name]) == 0 {
+			return nil, fmt.Errorf("empty type group %q", name)
+		}
+
+		// Groups cannot refer to other groups.
+		for _, pattern := range groups[name] {
+			if err := ValidateTypePattern(pattern); err != nil {
+				return nil, fmt.Errorf("invalid type group %q: %w", name, err)
+			}
+		}
+	}
+
+	return groups, nil
+}
+
+// Expand replaces the groups by their patterns and validates the patterns.
+func (groups TypeGroups) Expand(patterns []string) ([]string, error) {
+	expanded := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if name, ok := strings.CutPrefix(pattern, TypeGroupPrefix); ok {
+			group, ok := groups[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown type group %q", pattern)
+			}
+
+			expanded = append(expanded, group...)
+			continue
+		}
+
+		if err := ValidateTypePattern(pattern); err != nil {
+			return nil, err
+		}
+
+		expanded = append(expanded, pattern)
+	}
+
+	return expanded, nil
+}
+
+// ValidateTypePattern checks that the pattern is a MIME type, type/* or */*.
+// Only MIME types may have parameters.
+func ValidateTypePattern(pattern string) error {
+	mediaType, params, hasParams := strings.Cut(pattern, ";")
+	mainType, subtype, found := strings.Cut(strings.TrimSpace(mediaType), "/")
+	if !found || !mimeTokenRegex.MatchString(mainType) || !mimeTokenRegex.MatchString(subtype) {
+		return fmt.Errorf("invalid type pattern %q", pattern)
+	}
+
+	if strings.Contains(mainType, "*") && (mainType != "*" || subtype != "*") {
+		return fmt.Errorf("invalid type pattern %q, only */* may have a wildcard type", pattern)
+	}
+
+	if strings.Contains(subtype, "*") && subtype != "*" {
+		return fmt.Errorf("invalid type pattern %q, the wildcard must be the whole subtype", pattern)
+	}
+
+	if hasParams && (subtype == "*" || strings.TrimSpace(params) == "") {
+		return fmt.Errorf("invalid type pattern %q", pattern)
+	}
+
+	return nil
+}
+
+// MatchTypePattern returns true if the content type matches the pattern. The
+// parameters of the content type (e.g. charset) are ignored unless the pattern
+// has the same ones.
+func MatchTypePattern(pattern string, contentType string) bool {
+	if pattern == contentType {
+		return true
+	}
+
+	if strings.Contains(pattern, ";") {
+		return false
+	}
+
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	pattern = strings.ToLower(pattern)
+
+	switch {
+	case pattern == "*/*":
+		return true
+	case strings.HasSuffix(pattern, "/*"):
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))
+	}
+
+	return pattern == mediaType
+}
+
+// MatchAnyTypePattern returns true if the content type matches any pattern.
+func MatchAnyTypePattern(patterns []string, contentType string) bool {
+	for _, pattern := range patterns {
+		if MatchTypePattern(pattern, contentType) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VariantMode tells how an image is resized to the size of a variant.
+type VariantMode string
+
+const (
+	// VariantModeFit scales the image down until it fits in a square of the
+	// variant size, keeping its aspect ratio.
+	VariantModeFit VariantMode = "fit"
+
+	// VariantModeFill scales the image down until it covers a square of the
+	// variant size, then crops the overflow around the center.
+	VariantModeFill VariantMode = "fill"
+)
+
+var variantNameRegex = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// VariantSpec defines a variant which is generated for the stored images.
+// Images are never scaled up, so a variant can be smaller than its size.
+type VariantSpec struct {
+	Name string
+	Size int
+	Mode VariantMode
+}
+
+func (spec VariantSpec) Transform() *ImageTransform {
+	return &ImageTransform{Resize: &ResizeOption{Mode: spec.Mode, Width: spec.Size, Height: spec.Size}}
+}
+
+// ParseVariantSpecs parses the variant definitions in the form name:size:mode,
+// e.g. thumb:64:fill.
+func ParseVariantSpecs(definitions []string) ([]VariantSpec, error) {
+	specs := make([]VariantSpec, 0, len(definitions))
+	names := map[string]bool{}
+
+	for _, definition := range definitions {
+		definition = strings.TrimSpace(definition)
+		if definition == "" {
+			continue
+		}
+
+		parts := strings.Split(definition, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid variant %q, expected name:size:mode", definition)
+		}
+
+		name, mode := parts[0], VariantMode(parts[2])
+		if !variantNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid variant name %q", name)
+		}
+
+		if names[name] {
+			return nil, fmt.Errorf("duplicated variant %q", name)
+		}
+
+		size, err := strconv.Atoi(parts[1])
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid size of variant %q", name)
+		}
+
+		if mode != VariantModeFit && mode != VariantModeFill {
+			return nil, fmt.Errorf("invalid mode of variant %q, expected fit or fill", name)
+		}
+
+		names[name] = true
+		specs = append(specs, VariantSpec{Name: name, Size: size, Mode: mode})
+	}
+
+	return specs, nil
+}
+
+// EncodedImage is the result of an image transformation.
+type EncodedImage struct {
+	Content []byte
+
+	// Type is the MIME type of the content.
+	Type string
+
+	Metadata ImageMetadata
+}
+
+// FileVariant is a resized copy of a stored image. It is stored next to the
+// original file and deleted together with it.
+type FileVariant struct {
+	FileID string
+	Name   string
+
+	// Bucket is the bucket of the original file.
+	Bucket string
+
+	// Type and Size describe the variant content, which may be encoded in
+	// another format than the original file.
+	Type  string
+	Size  int
+	Image ImageMetadata
+
+	CreatedAt time.Time
+}
+
+func (domain *FileDomain) NewFileVariant(file *FileInfo, name string, image *EncodedImage) *FileVariant {
+	return &FileVariant{
+		FileID:    file.ID,
+		Name:      name,
+		Bucket:    file.Metadata.Bucket,
+		Type:      image.Type,
+		Size:      len(image.Content),
+		Image:     image.Metadata,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,186 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxTransformSize is the maximum width and height of a transformed image.
+const MaxTransformSize = 4096
+
+// ImageTransform describes an on-demand transformation of an image. The
+// operations are applied in the order: crop, rotate, resize, then the result is
+// encoded.
+//
+// It is written as comma-separated operations, e.g.
+// crop:0:0:400:300,rotate:90,resize:fill:200:200,format:jpeg,quality:80
+type ImageTransform struct {
+	Crop   *CropOption
+	Resize *ResizeOption
+
+	// Rotate is the clockwise rotation in degrees, one of 0, 90, 180 or 270.
+	Rotate int
+
+	// Format is the output format (jpeg, png or gif). If it is empty, JPEG
+	// images are encoded as JPEG and the others as PNG.
+	Format string
+
+	// Quality is the JPEG quality from 1 to 100, 0 means the default.
+	Quality int
+}
+
+type CropOption struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+type ResizeOption struct {
+	Mode   VariantMode
+	Width  int
+	Height int
+}
+
+// ParseImageTransform parses and validates the written form of a
+// transformation.
+func ParseImageTransform(s string) (*ImageTransform, error) {
+	transform := &ImageTransform{}
+	seen := map[string]bool{}
+
+	for _, operation := range strings.Split(s, ",") {
+		args := strings.Split(operation, ":")
+		name, args := args[0], args[1:]
+		if seen[name] {
+			return nil, fmt.Errorf("duplicated operation %q", name)
+		}
+		seen[name] = true
+
+		values, err := parseIntArgs(name, args)
+		if err != nil {
+			return nil, err
+		}
+
+		switch name {
+		case "crop":
+			if len(values) != 4 || values[0] < 0 || values[1] < 0 || values[2] <= 0 || values[3] <= 0 {
+				return nil, errors.New("crop requires x, y, width and height")
+			}
+
+			transform.Crop = &CropOption{X: values[0], Y: values[1], Width: values[2], Height: values[3]}
+
+		case "resize":
+			if len(args) != 3 {
+				return nil, errors.New("resize requires mode, width and height")
+			}
+
+			mode := VariantMode(args[0])
+			if mode != VariantModeFit && mode != VariantModeFill {
+				return nil, errors.New("resize mode must be fit or fill")
+			}
+
+			width, height := values[1], values[2]
+			if width <= 0 || height <= 0 || width > MaxTransformSize || height > MaxTransformSize {
+				return nil, fmt.Errorf("resize size must be from 1 to %d", MaxTransformSize)
+			}
+
+			transform.Resize = &ResizeOption{Mode: mode, Width: width, Height: height}
+
+		case "rotate":
+			if len(values) != 1 || (values[0] != 90 && values[0] != 180 && values[0] != 270) {
+				return nil, errors.New("rotate must be 90, 180 or 270")
+			}
+
+			transform.Rotate = values[0]
+
+		case "format":
+			if len(args) != 1 || (args[0] != "jpeg" && args[0] != "png" && args[0] != "gif") {
+				return nil, errors.New("format must be jpeg, png or gif")
+			}
+
+			transform.Format = args[0]
+
+		case "quality":
+			if len(values) != 1 || values[0] < 1 || values[0] > 100 {
+				return nil, errors.New("quality must be from 1 to 100")
+			}
+
+			transform.Quality = values[0]
+
+		default:
+			return nil, fmt.Errorf("unknown operation %q", name)
+		}
+	}
+
+	return transform, nil
+}
+
+// parseIntArgs parses the arguments of the operation, except the mode of
+// resize which is returned as 0.
+func parseIntArgs(name string, args []string) ([]int, error) {
+	values := make([]int, len(args))
+	for i := range args {
+		if name == "format" || (name == "resize" && i == 0) {
+			continue
+		}
+
+		value, err := strconv.Atoi(args[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument %q of %s", args[i], name)
+		}
+
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+// String returns the canonical written form, equivalent transformations have
+// the same form.
+func (t *ImageTransform) String() string {
+	operations := []string{}
+	if t.Crop != nil {
+		operations = append(operations, fmt.Sprintf("crop:%d:%d:%d:%d", t.Crop.X, t.Crop.Y, t.Crop.Width, t.Crop.Height))
+	}
+
+	if t.Rotate != 0 {
+		operations = append(operations, fmt.Sprintf("rotate:%d", t.Rotate))
+	}
+
+	if t.Resize != nil {
+		operations = append(operations, fmt.Sprintf("resize:%s:%d:%d", t.Resize.Mode, t.Resize.Width, t.Resize.Height))
+	}
+
+	if t.Format != "" {
+		operations = append(operations, "format:"+t.Format)
+	}
+
+	if t.Quality != 0 {
+		operations = append(operations, fmt.Sprintf("quality:%d", t.Quality))
+	}
+
+	return strings.Join(operations, ",")
+}
+
+// OutputFormat returns the format which the image of the given content type is
+// encoded to.
+func (t *ImageTransform) OutputFormat(contentType string) string {
+	switch {
+	case t.Format != "":
+		return t.Format
+	case contentType == "image/jpeg":
+		return "jpeg"
+	default:
+		return "png"
+	}
+}
+
+// CacheKey identifies the result of the transformation of the file.
+func (t *ImageTransform) CacheKey(fileID string) string {
+	hash := sha256.Sum256([]byte(fileID + "\n" + t.String()))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
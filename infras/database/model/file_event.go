@@ -0,0 +1,55 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/xybor-x/snowflake"
+)
+
+type FileEvent struct {
+	ID        int64  `gorm:"column:id;primaryKey"`
+	Type      string `gorm:"column:type"`
+	FileID    string `gorm:"column:file_id"`
+	Payload   string `gorm:"column:payload"`
+	CreatedAt time.Time
+
+	// Sequence orders the events of the same file, it is assigned by the
+	// outbox when the event is written.
+	Sequence int64 `gorm:"column:sequence"`
+}
+
+func (FileEvent) TableName() string {
+	return "file_outbox"
+}
+
+func NewFileEvent(event *domain.FileEvent) (*FileEvent, error) {
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileEvent{
+		ID:        event.ID.Int64(),
+		Type:      string(event.Type),
+		FileID:    event.FileID,
+		Payload:   string(payload),
+		CreatedAt: event.CreatedAt,
+	}, nil
+}
+
+func (e *FileEvent) To() (*domain.FileEvent, error) {
+	payload := map[string]any{}
+	if err := json.Unmarshal([]byte(e.Payload), &payload); err != nil {
+		return nil, err
+	}
+
+	return &domain.FileEvent{
+		ID:        snowflake.ID(e.ID),
+		Type:      domain.FileEventType(e.Type),
+		FileID:    e.FileID,
+		Payload:   payload,
+		CreatedAt: e.CreatedAt,
+	}, nil
+}
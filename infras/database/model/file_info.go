@@ -7,11 +7,19 @@ import (
 )
 
 type FileInfo struct {
-	ID        string `gorm:"column:id;primaryKey"`
-	Bucket    string `gorm:"column:bucket"`
-	Type      string `gorm:"column:type"`
-	Size      int    `gorm:"column:size"`
-	CreatedAt time.Time
+	ID         string `gorm:"column:id;primaryKey"`
+	Bucket     string `gorm:"column:bucket"`
+	Type       string `gorm:"column:type"`
+	Size       int    `gorm:"column:size"`
+	Format     string `gorm:"column:image_format"`
+	Width      int    `gorm:"column:image_width"`
+	Height     int    `gorm:"column:image_height"`
+	Status     string `gorm:"column:status"`
+	Threat     string `gorm:"column:threat"`
+	ScanStatus string `gorm:"column:scan_status"`
+	Purpose    string `gorm:"column:purpose"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time `gorm:"column:updated_at"`
 }
 
 func (FileInfo) TableName() string {
@@ -19,23 +27,47 @@ func (FileInfo) TableName() string {
 }
 
 func NewFileInfo(f *domain.FileInfo) *FileInfo {
-	return &FileInfo{
-		ID:        f.ID,
-		Bucket:    f.Metadata.Bucket,
-		Type:      f.Metadata.Type,
-		Size:      f.Metadata.Size,
-		CreatedAt: f.CreatedAt,
+	info := &FileInfo{
+		ID:         f.ID,
+		Bucket:     f.Metadata.Bucket,
+		Type:       f.Metadata.Type,
+		Size:       f.Metadata.Size,
+		Status:     string(f.Status),
+		Threat:     f.Threat,
+		ScanStatus: string(f.ScanStatus),
+		Purpose:    f.Metadata.Purpose,
+		CreatedAt:  f.CreatedAt,
+		UpdatedAt:  f.UpdatedAt,
 	}
+
+	if f.Metadata.Image != nil {
+		info.Format = f.Metadata.Image.Format
+		info.Width = f.Metadata.Image.Width
+		info.Height = f.Metadata.Image.Height
+	}
+
+	return info
 }
 
 func (f *FileInfo) To() *domain.FileInfo {
-	return &domain.FileInfo{
+	info := &domain.FileInfo{
 		ID: f.ID,
 		Metadata: &domain.FileMetadata{
-			Bucket: f.Bucket,
-			Type:   f.Type,
-			Size:   f.Size,
+			Bucket:  f.Bucket,
+			Type:    f.Type,
+			Size:    f.Size,
+			Purpose: f.Purpose,
 		},
-		CreatedAt: f.CreatedAt,
+		Status:     domain.FileStatus(f.Status),
+		Threat:     f.Threat,
+		ScanStatus: domain.ScanStatus(f.ScanStatus),
+		CreatedAt:  f.CreatedAt,
+		UpdatedAt:  f.UpdatedAt,
 	}
+
+	if f.Format != "" {
+		info.Metadata.Image = &domain.ImageMetadata{Format: f.Format, Width: f.Width, Height: f.Height}
+	}
+
+	return info
 }
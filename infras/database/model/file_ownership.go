@@ -1,15 +1,20 @@
 package model
 
 import (
+	"time"
+
 	"github.com/todennus/file-service/domain"
 	"github.com/xybor-x/snowflake"
 )
 
 type FileOwnership struct {
-	ID       int64  `gorm:"column:id;primaryKey"`
-	FileID   string `gorm:"column:file_id"`
-	UserID   int64  `gorm:"column:user_id"`
-	RefCount int    `gorm:"column:refcount"`
+	ID          int64     `gorm:"column:id;primaryKey"`
+	FileID      string    `gorm:"column:file_id"`
+	UserID      int64     `gorm:"column:user_id"`
+	RefCount    int       `gorm:"column:refcount"`
+	DisplayName string    `gorm:"column:display_name"`
+	Description string    `gorm:"column:description"`
+	UpdatedAt   time.Time `gorm:"column:updated_at"`
 }
 
 func (FileOwnership) TableName() string {
@@ -18,18 +23,24 @@ func (FileOwnership) TableName() string {
 
 func NewFileOwnership(f *domain.FileOwnership) *FileOwnership {
 	return &FileOwnership{
-		ID:       f.ID.Int64(),
-		FileID:   f.FileID,
-		UserID:   f.UserID.Int64(),
-		RefCount: f.RefCount,
+		ID:          f.ID.Int64(),
+		FileID:      f.FileID,
+		UserID:      f.UserID.Int64(),
+		RefCount:    f.RefCount,
+		DisplayName: f.DisplayName,
+		Description: f.Description,
+		UpdatedAt:   f.UpdatedAt,
 	}
 }
 
 func (f *FileOwnership) To() *domain.FileOwnership {
 	return &domain.FileOwnership{
-		ID:       snowflake.ID(f.ID),
-		FileID:   f.FileID,
-		UserID:   snowflake.ID(f.UserID),
-		RefCount: f.RefCount,
+		ID:          snowflake.ID(f.ID),
+		FileID:      f.FileID,
+		UserID:      snowflake.ID(f.UserID),
+		RefCount:    f.RefCount,
+		DisplayName: f.DisplayName,
+		Description: f.Description,
+		UpdatedAt:   f.UpdatedAt,
 	}
 }
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/xybor-x/snowflake"
+)
+
+type FileReference struct {
+	OwnershipID int64  `gorm:"column:ownership_id;primaryKey"`
+	Service     string `gorm:"column:service;primaryKey"`
+	EntityType  string `gorm:"column:entity_type;primaryKey"`
+	EntityID    string `gorm:"column:entity_id;primaryKey"`
+	CreatedAt   time.Time
+}
+
+func (FileReference) TableName() string {
+	return "file_references"
+}
+
+func NewFileReference(r *domain.FileReference) *FileReference {
+	return &FileReference{
+		OwnershipID: r.OwnershipID.Int64(),
+		Service:     r.Service,
+		EntityType:  r.EntityType,
+		EntityID:    r.EntityID,
+		CreatedAt:   r.CreatedAt,
+	}
+}
+
+func (r *FileReference) To() *domain.FileReference {
+	return &domain.FileReference{
+		OwnershipID: snowflake.ID(r.OwnershipID),
+		Service:     r.Service,
+		EntityType:  r.EntityType,
+		EntityID:    r.EntityID,
+		CreatedAt:   r.CreatedAt,
+	}
+}
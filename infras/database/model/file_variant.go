@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"github.com/todennus/file-service/domain"
+)
+
+type FileVariant struct {
+	FileID    string `gorm:"column:file_id;primaryKey"`
+	Name      string `gorm:"column:name;primaryKey"`
+	Bucket    string `gorm:"column:bucket"`
+	Type      string `gorm:"column:type"`
+	Size      int    `gorm:"column:size"`
+	Format    string `gorm:"column:image_format"`
+	Width     int    `gorm:"column:image_width"`
+	Height    int    `gorm:"column:image_height"`
+	CreatedAt time.Time
+}
+
+func (FileVariant) TableName() string {
+	return "file_variants"
+}
+
+func NewFileVariant(v *domain.FileVariant) *FileVariant {
+	return &FileVariant{
+		FileID:    v.FileID,
+		Name:      v.Name,
+		Bucket:    v.Bucket,
+		Type:      v.Type,
+		Size:      v.Size,
+		Format:    v.Image.Format,
+		Width:     v.Image.Width,
+		Height:    v.Image.Height,
+		CreatedAt: v.CreatedAt,
+	}
+}
+
+func (v *FileVariant) To() *domain.FileVariant {
+	return &domain.FileVariant{
+		FileID: v.FileID,
+		Name:   v.Name,
+		Bucket: v.Bucket,
+		Type:   v.Type,
+		Size:   v.Size,
+		Image: domain.ImageMetadata{
+			Format: v.Format,
+			Width:  v.Width,
+			Height: v.Height,
+		},
+		CreatedAt: v.CreatedAt,
+	}
+}
@@ -8,27 +8,65 @@ import (
 )
 
 type UploadPolicy struct {
-	UserID       int64    `json:"uid"`
-	AllowedTypes []string `json:"ats"`
-	MaxSize      int64    `json:"msz"`
-	ExpiresAt    int64    `json:"exp"`
+	UserID             int64    `json:"uid"`
+	AllowedTypes       []string `json:"ats"`
+	DeniedTypes        []string `json:"dts,omitempty"`
+	MaxSize            int64    `json:"msz"`
+	StagingKey         string   `json:"stk,omitempty"`
+	StripMetadata      bool     `json:"stm,omitempty"`
+	SanitizeSVG        bool     `json:"svg,omitempty"`
+	MaxWidth           int      `json:"mwd,omitempty"`
+	MaxHeight          int      `json:"mht,omitempty"`
+	MaxMegapixels      float64  `json:"mmp,omitempty"`
+	MaxFrames          int      `json:"mfr,omitempty"`
+	MaxTotalMegapixels float64  `json:"mtp,omitempty"`
+	Purpose            string   `json:"pps,omitempty"`
+	DeclaredFileID     string   `json:"did,omitempty"`
+	DeclaredSize       int64    `json:"dsz,omitempty"`
+	ExpiresAt          int64    `json:"exp"`
 }
 
 func NewUploadPolicy(policy *domain.UploadPolicy) *UploadPolicy {
 	return &UploadPolicy{
-		UserID:       policy.UserID.Int64(),
-		AllowedTypes: policy.AllowedTypes,
-		MaxSize:      policy.MaxSize,
-		ExpiresAt:    policy.ExpiresAt.Unix(),
+		UserID:             policy.UserID.Int64(),
+		AllowedTypes:       policy.AllowedTypes,
+		DeniedTypes:        policy.DeniedTypes,
+		MaxSize:            policy.MaxSize,
+		StagingKey:         policy.StagingKey,
+		StripMetadata:      policy.StripMetadata,
+		SanitizeSVG:        policy.SanitizeSVG,
+		MaxWidth:           policy.ImageLimits.MaxWidth,
+		MaxHeight:          policy.ImageLimits.MaxHeight,
+		MaxMegapixels:      policy.ImageLimits.MaxMegapixels,
+		MaxFrames:          policy.ImageLimits.MaxFrames,
+		MaxTotalMegapixels: policy.ImageLimits.MaxTotalMegapixels,
+		Purpose:            policy.Purpose,
+		DeclaredFileID:     policy.DeclaredFileID,
+		DeclaredSize:       policy.DeclaredSize,
+		ExpiresAt:          policy.ExpiresAt.Unix(),
 	}
 }
 
 func (policy *UploadPolicy) To(token string) *domain.UploadPolicy {
 	return &domain.UploadPolicy{
-		Token:        token,
-		UserID:       snowflake.ParseInt64(policy.UserID),
-		AllowedTypes: policy.AllowedTypes,
-		MaxSize:      policy.MaxSize,
-		ExpiresAt:    time.Unix(policy.ExpiresAt, 0),
+		Token:          token,
+		UserID:         snowflake.ParseInt64(policy.UserID),
+		AllowedTypes:   policy.AllowedTypes,
+		DeniedTypes:    policy.DeniedTypes,
+		MaxSize:        policy.MaxSize,
+		StagingKey:     policy.StagingKey,
+		StripMetadata:  policy.StripMetadata,
+		SanitizeSVG:    policy.SanitizeSVG,
+		Purpose:        policy.Purpose,
+		DeclaredFileID: policy.DeclaredFileID,
+		DeclaredSize:   policy.DeclaredSize,
+		ExpiresAt:      time.Unix(policy.ExpiresAt, 0),
+		ImageLimits: domain.ImageLimits{
+			MaxWidth:           policy.MaxWidth,
+			MaxHeight:          policy.MaxHeight,
+			MaxMegapixels:      policy.MaxMegapixels,
+			MaxFrames:          policy.MaxFrames,
+			MaxTotalMegapixels: policy.MaxTotalMegapixels,
+		},
 	}
 }
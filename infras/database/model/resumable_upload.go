@@ -0,0 +1,48 @@
+package model
+
+import (
+	"github.com/todennus/file-service/domain"
+)
+
+type ResumableUploadChunk struct {
+	Name string `json:"n"`
+	Size int64  `json:"s"`
+}
+
+type ResumableUpload struct {
+	PolicyToken string                 `json:"ptk"`
+	Policy      *UploadPolicy          `json:"pol"`
+	Length      int64                  `json:"len"`
+	Offset      int64                  `json:"off"`
+	Chunks      []ResumableUploadChunk `json:"chk"`
+}
+
+func NewResumableUpload(upload *domain.ResumableUpload) *ResumableUpload {
+	chunks := make([]ResumableUploadChunk, 0, len(upload.Chunks))
+	for i := range upload.Chunks {
+		chunks = append(chunks, ResumableUploadChunk{Name: upload.Chunks[i].Name, Size: upload.Chunks[i].Size})
+	}
+
+	return &ResumableUpload{
+		PolicyToken: upload.Policy.Token,
+		Policy:      NewUploadPolicy(upload.Policy),
+		Length:      upload.Length,
+		Offset:      upload.Offset,
+		Chunks:      chunks,
+	}
+}
+
+func (upload *ResumableUpload) To(id string) *domain.ResumableUpload {
+	chunks := make([]domain.ResumableUploadChunk, 0, len(upload.Chunks))
+	for i := range upload.Chunks {
+		chunks = append(chunks, domain.ResumableUploadChunk{Name: upload.Chunks[i].Name, Size: upload.Chunks[i].Size})
+	}
+
+	return &domain.ResumableUpload{
+		ID:     id,
+		Policy: upload.Policy.To(upload.PolicyToken),
+		Length: upload.Length,
+		Offset: upload.Offset,
+		Chunks: chunks,
+	}
+}
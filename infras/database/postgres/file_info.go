@@ -2,14 +2,22 @@ package postgres
 
 import (
 	"context"
+	"time"
 
 	"github.com/todennus/file-service/domain"
 	"github.com/todennus/file-service/infras/database/model"
 	"github.com/todennus/shared/errordef"
 	"github.com/todennus/shared/xcontext"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+const notOwnedCondition = "NOT EXISTS (SELECT 1 FROM file_ownerships WHERE file_ownerships.file_id=files.id)"
+
+// staleCondition matches the files which can be stored again: failed files and
+// files which have been pending for too long.
+const staleCondition = "(status=? OR (status=? AND updated_at<?))"
+
 type FileInfoRepository struct {
 	db *gorm.DB
 }
@@ -32,3 +40,242 @@ func (repo *FileInfoRepository) GetByID(ctx context.Context, fileID string) (*do
 
 	return model.To(), nil
 }
+
+// GetByIDs returns the existing files among the given ids, in no particular
+// order.
+func (repo *FileInfoRepository) GetByIDs(ctx context.Context, ids []string) ([]*domain.FileInfo, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	models := []model.FileInfo{}
+	if err := xcontext.DB(ctx, repo.db).Where("id IN ?", ids).Find(&models).Error; err != nil {
+		return nil, errordef.ConvertGormError(err)
+	}
+
+	result := make([]*domain.FileInfo, 0, len(models))
+	for i := range models {
+		result = append(result, models[i].To())
+	}
+
+	return result, nil
+}
+
+// Claim marks a failed or stale pending file as pending again, so the caller
+// can store its content. It returns ErrNotFound if the file is not claimable,
+// e.g. it has been claimed by another uploader.
+func (repo *FileInfoRepository) Claim(ctx context.Context, id string, staleBefore time.Time) error {
+	result := xcontext.DB(ctx, repo.db).
+		Model(&model.FileInfo{}).
+		Where("id=?", id).
+		Where(staleCondition, domain.FileStatusFailed, domain.FileStatusPending, staleBefore).
+		Updates(map[string]any{
+			"status":     domain.FileStatusPending,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return errordef.ConvertGormError(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return errordef.ErrNotFound
+	}
+
+	return nil
+}
+
+// MarkStored marks the file as stored whatever its current status is. Another
+// uploader may have claimed the file meanwhile, but all uploaders store the
+// same content.
+func (repo *FileInfoRepository) MarkStored(ctx context.Context, id string) error {
+	return errordef.ConvertGormError(
+		xcontext.DB(ctx, repo.db).
+			Model(&model.FileInfo{}).
+			Where("id=?", id).
+			Updates(map[string]any{
+				"status":     domain.FileStatusStored,
+				"updated_at": time.Now(),
+			}).Error,
+	)
+}
+
+// Quarantine saves the threat, the scan status and the quarantine bucket of the
+// file.
+func (repo *FileInfoRepository) Quarantine(ctx context.Context, file *domain.FileInfo) error {
+	return errordef.ConvertGormError(
+		xcontext.DB(ctx, repo.db).
+			Model(&model.FileInfo{}).
+			Where("id=?", file.ID).
+			Updates(map[string]any{
+				"bucket":      file.Metadata.Bucket,
+				"threat":      file.Threat,
+				"scan_status": file.ScanStatus,
+			}).Error,
+	)
+}
+
+// MarkClean marks the pending scan of the file as clean.
+func (repo *FileInfoRepository) MarkClean(ctx context.Context, id string) error {
+	return errordef.ConvertGormError(
+		xcontext.DB(ctx, repo.db).
+			Model(&model.FileInfo{}).
+			Where("id=? AND scan_status=?", id, domain.ScanStatusPending).
+			Update("scan_status", domain.ScanStatusClean).Error,
+	)
+}
+
+// MarkFailed marks the file as failed only if it is still pending, a file
+// stored by another uploader is kept.
+func (repo *FileInfoRepository) MarkFailed(ctx context.Context, id string) error {
+	return errordef.ConvertGormError(
+		xcontext.DB(ctx, repo.db).
+			Model(&model.FileInfo{}).
+			Where("id=? AND status=?", id, domain.FileStatusPending).
+			Updates(map[string]any{
+				"status":     domain.FileStatusFailed,
+				"updated_at": time.Now(),
+			}).Error,
+	)
+}
+
+// FailStale marks all files which have been pending since before the given
+// time as failed and returns the number of them.
+func (repo *FileInfoRepository) FailStale(ctx context.Context, staleBefore time.Time) (int, error) {
+	result := xcontext.DB(ctx, repo.db).
+		Model(&model.FileInfo{}).
+		Where("status=? AND updated_at<?", domain.FileStatusPending, staleBefore).
+		Updates(map[string]any{
+			"status":     domain.FileStatusFailed,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return 0, errordef.ConvertGormError(result.Error)
+	}
+
+	return int(result.RowsAffected), nil
+}
+
+func (repo *FileInfoRepository) GetOrphaned(
+	ctx context.Context,
+	createdBefore time.Time,
+	afterID string,
+	n int,
+) ([]*domain.FileInfo, error) {
+	models := []model.FileInfo{}
+	err := xcontext.DB(ctx, repo.db).
+		Where("created_at<? AND id>? AND status<>?", createdBefore, afterID, domain.FileStatusPending).
+		Where(notOwnedCondition).
+		Order("id").
+		Limit(n).
+		Find(&models).Error
+	if err != nil {
+		return nil, errordef.ConvertGormError(err)
+	}
+
+	result := make([]*domain.FileInfo, 0, len(models))
+	for i := range models {
+		result = append(result, models[i].To())
+	}
+
+	return result, nil
+}
+
+// GetPendingScans returns the stored files whose scan has been pending since
+// before the given time, ordered by ID.
+func (repo *FileInfoRepository) GetPendingScans(
+	ctx context.Context,
+	updatedBefore time.Time,
+	afterID string,
+	n int,
+) ([]*domain.FileInfo, error) {
+	models := []model.FileInfo{}
+	err := xcontext.DB(ctx, repo.db).
+		Where("scan_status=? AND status=? AND updated_at<? AND id>?",
+			domain.ScanStatusPending, domain.FileStatusStored, updatedBefore, afterID).
+		Order("id").
+		Limit(n).
+		Find(&models).Error
+	if err != nil {
+		return nil, errordef.ConvertGormError(err)
+	}
+
+	result := make([]*domain.FileInfo, 0, len(models))
+	for i := range models {
+		result = append(result, models[i].To())
+	}
+
+	return result, nil
+}
+
+// LockOrphaned locks the file row until the current transaction ends. It
+// returns ErrNotFound if the file is not orphaned anymore or it is being locked
+// by another transaction (e.g. another janitor).
+func (repo *FileInfoRepository) LockOrphaned(
+	ctx context.Context,
+	id string,
+	createdBefore time.Time,
+) (*domain.FileInfo, error) {
+	model := model.FileInfo{}
+	err := xcontext.DB(ctx, repo.db).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("id=? AND created_at<? AND status<>?", id, createdBefore, domain.FileStatusPending).
+		Where(notOwnedCondition).
+		Take(&model).Error
+	if err != nil {
+		return nil, errordef.ConvertGormError(err)
+	}
+
+	return model.To(), nil
+}
+
+// GetStored returns the stored files which are not quarantined, ordered by ID.
+func (repo *FileInfoRepository) GetStored(ctx context.Context, afterID string, n int) ([]*domain.FileInfo, error) {
+	models := []model.FileInfo{}
+	err := xcontext.DB(ctx, repo.db).
+		Where("id>? AND status=? AND threat=?", afterID, domain.FileStatusStored, "").
+		Order("id").
+		Limit(n).
+		Find(&models).Error
+	if err != nil {
+		return nil, errordef.ConvertGormError(err)
+	}
+
+	result := make([]*domain.FileInfo, 0, len(models))
+	for i := range models {
+		result = append(result, models[i].To())
+	}
+
+	return result, nil
+}
+
+// LockStored locks the file row until the current transaction ends. It returns
+// ErrNotFound if the file is not stored anymore, it has been quarantined or it
+// is being locked by another transaction.
+func (repo *FileInfoRepository) LockStored(ctx context.Context, id string) (*domain.FileInfo, error) {
+	model := model.FileInfo{}
+	err := xcontext.DB(ctx, repo.db).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("id=? AND status=? AND threat=?", id, domain.FileStatusStored, "").
+		Take(&model).Error
+	if err != nil {
+		return nil, errordef.ConvertGormError(err)
+	}
+
+	return model.To(), nil
+}
+
+// ChangeBucket saves the bucket of the file.
+func (repo *FileInfoRepository) ChangeBucket(ctx context.Context, file *domain.FileInfo) error {
+	return errordef.ConvertGormError(
+		xcontext.DB(ctx, repo.db).
+			Model(&model.FileInfo{}).
+			Where("id=?", file.ID).
+			Update("bucket", file.Metadata.Bucket).Error,
+	)
+}
+
+func (repo *FileInfoRepository) Delete(ctx context.Context, id string) error {
+	return errordef.ConvertGormError(
+		xcontext.DB(ctx, repo.db).Delete(&model.FileInfo{}, "id=?", id).Error,
+	)
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"slices"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/todennus/file-service/infras/database/model"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/xcontext"
+	"github.com/xybor-x/snowflake"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FileOutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewFileOutboxRepository(db *gorm.DB) *FileOutboxRepository {
+	return &FileOutboxRepository{db: db}
+}
+
+// Create writes the events to the outbox. It must be called in the transaction
+// of the change the events are about.
+//
+// The rows of the files are locked until the transaction ends, then every
+// event is numbered after the pending events of its file. Another transaction
+// writing events of the same file waits for the lock, so the events of a file
+// are numbered in the order their transactions commit.
+func (repo *FileOutboxRepository) Create(ctx context.Context, events ...*domain.FileEvent) error {
+	db := xcontext.DB(ctx, repo.db)
+
+	fileIDs := make([]string, 0, len(events))
+	for i := range events {
+		if !slices.Contains(fileIDs, events[i].FileID) {
+			fileIDs = append(fileIDs, events[i].FileID)
+		}
+	}
+
+	// The rows are locked in the order of their IDs, so two transactions
+	// writing events of the same files never deadlock.
+	slices.Sort(fileIDs)
+	err := db.Model(&model.FileInfo{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", fileIDs).
+		Order("id").
+		Pluck("id", &[]string{}).Error
+	if err != nil {
+		return errordef.ConvertGormError(err)
+	}
+
+	lastSequences := []struct {
+		FileID   string
+		Sequence int64
+	}{}
+	err = db.Model(&model.FileEvent{}).
+		Select("file_id, MAX(sequence) AS sequence").
+		Where("file_id IN ?", fileIDs).
+		Group("file_id").
+		Scan(&lastSequences).Error
+	if err != nil {
+		return errordef.ConvertGormError(err)
+	}
+
+	sequences := make(map[string]int64, len(lastSequences))
+	for _, last := range lastSequences {
+		sequences[last.FileID] = last.Sequence
+	}
+
+	models := make([]*model.FileEvent, 0, len(events))
+	for i := range events {
+		model, err := model.NewFileEvent(events[i])
+		if err != nil {
+			return err
+		}
+
+		sequences[model.FileID]++
+		model.Sequence = sequences[model.FileID]
+		models = append(models, model)
+	}
+
+	return errordef.ConvertGormError(db.Create(models).Error)
+}
+
+// LockNext returns the next n events ordered by file and sequence, and locks
+// them until the current transaction ends. A returned event is never preceded
+// by a pending event of the same file which is not returned. Unlike SKIP
+// LOCKED, another relay waits for the locked events, so the events of a file
+// are never published out of order.
+func (repo *FileOutboxRepository) LockNext(ctx context.Context, n int) ([]*domain.FileEvent, error) {
+	models := []model.FileEvent{}
+	err := xcontext.DB(ctx, repo.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Order("file_id, sequence, id").
+		Limit(n).
+		Find(&models).Error
+	if err != nil {
+		return nil, errordef.ConvertGormError(err)
+	}
+
+	result := make([]*domain.FileEvent, 0, len(models))
+	for i := range models {
+		event, err := models[i].To()
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, event)
+	}
+
+	return result, nil
+}
+
+func (repo *FileOutboxRepository) Delete(ctx context.Context, ids []snowflake.ID) error {
+	return errordef.ConvertGormError(
+		xcontext.DB(ctx, repo.db).Delete(&model.FileEvent{}, "id IN ?", ids).Error,
+	)
+}
@@ -2,6 +2,8 @@ package postgres
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/todennus/file-service/domain"
 	"github.com/todennus/file-service/infras/database/model"
@@ -9,6 +11,7 @@ import (
 	"github.com/todennus/shared/xcontext"
 	"github.com/xybor-x/snowflake"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type FileOwnershipRepository struct {
@@ -34,6 +37,109 @@ func (repo *FileOwnershipRepository) GetByID(ctx context.Context, ownershipID sn
 	return model.To(), nil
 }
 
+// GetByIDs returns the existing ownerships among the given ids, in no
+// particular order.
+func (repo *FileOwnershipRepository) GetByIDs(ctx context.Context, ids []snowflake.ID) ([]*domain.FileOwnership, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	models := []model.FileOwnership{}
+	if err := xcontext.DB(ctx, repo.db).Where("id IN ?", ids).Find(&models).Error; err != nil {
+		return nil, errordef.ConvertGormError(err)
+	}
+
+	result := make([]*domain.FileOwnership, 0, len(models))
+	for i := range models {
+		result = append(result, models[i].To())
+	}
+
+	return result, nil
+}
+
+// ListByUser returns at most n ownerships of the user whose files match the
+// filter, from the newest to the oldest. Only the ownerships older than
+// beforeID are returned, unless it is zero.
+func (repo *FileOwnershipRepository) ListByUser(
+	ctx context.Context,
+	userID snowflake.ID,
+	filter domain.OwnershipFilter,
+	beforeID snowflake.ID,
+	n int,
+) ([]*domain.FileOwnership, error) {
+	db := xcontext.DB(ctx, repo.db).
+		Joins("JOIN files ON files.id=file_ownerships.file_id").
+		Where("file_ownerships.user_id=?", userID)
+
+	if beforeID != 0 {
+		db = db.Where("file_ownerships.id<?", beforeID)
+	}
+
+	mainType, subtype, _ := strings.Cut(filter.Type, "/")
+	switch {
+	case filter.Type == "" || mainType == "*":
+	case subtype == "*":
+		db = db.Where("files.type LIKE ?", escapeLike(mainType)+"/%")
+	case strings.Contains(filter.Type, ";"):
+		db = db.Where("files.type=?", filter.Type)
+	default:
+		// The stored type may have parameters, e.g. text/plain; charset=utf-8.
+		db = db.Where("(files.type=? OR files.type LIKE ?)", filter.Type, escapeLike(filter.Type)+";%")
+	}
+
+	if filter.Bucket != "" {
+		db = db.Where("files.bucket=?", filter.Bucket)
+	}
+
+	// The ownership ids are generated when the ownerships are created, so
+	// their creation time is bounded by the ids.
+	if !filter.CreatedAfter.IsZero() {
+		db = db.Where("file_ownerships.id>=?", firstSnowflakeIDAt(filter.CreatedAfter))
+	}
+
+	if !filter.CreatedBefore.IsZero() {
+		db = db.Where("file_ownerships.id<?", firstSnowflakeIDAt(filter.CreatedBefore))
+	}
+
+	models := []model.FileOwnership{}
+	err := db.Select("file_ownerships.*").
+		Order("file_ownerships.id DESC").
+		Limit(n).
+		Find(&models).Error
+	if err != nil {
+		return nil, errordef.ConvertGormError(err)
+	}
+
+	result := make([]*domain.FileOwnership, 0, len(models))
+	for i := range models {
+		result = append(result, models[i].To())
+	}
+
+	return result, nil
+}
+
+// UpdateDetails saves the display name and the description of the ownership.
+// It returns ErrNotFound if the ownership does not exist.
+func (repo *FileOwnershipRepository) UpdateDetails(ctx context.Context, ownership *domain.FileOwnership) error {
+	result := xcontext.DB(ctx, repo.db).
+		Model(&model.FileOwnership{}).
+		Where("id=?", ownership.ID).
+		Updates(map[string]any{
+			"display_name": ownership.DisplayName,
+			"description":  ownership.Description,
+			"updated_at":   time.Now(),
+		})
+	if result.Error != nil {
+		return errordef.ConvertGormError(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return errordef.ErrNotFound
+	}
+
+	return nil
+}
+
 func (repo *FileOwnershipRepository) Get(ctx context.Context, fileID string, userID snowflake.ID) (*domain.FileOwnership, error) {
 	model := model.FileOwnership{}
 	if err := xcontext.DB(ctx, repo.db).Take(&model, "file_id=? AND user_id=?", fileID, userID).Error; err != nil {
@@ -43,11 +149,94 @@ func (repo *FileOwnershipRepository) Get(ctx context.Context, fileID string, use
 	return model.To(), nil
 }
 
-func (repo *FileOwnershipRepository) ChangeRefCount(ctx context.Context, ownershipID snowflake.ID, change int) error {
-	return errordef.ConvertGormError(
-		xcontext.DB(ctx, repo.db).
-			Model(&model.FileOwnership{}).
-			Where("id=?", ownershipID).
-			Update("refcount", gorm.Expr("refcount+?", change)).Error,
-	)
+// ChangeRefCount returns the changed ownership. It returns ErrNotFound if the
+// ownership does not exist.
+func (repo *FileOwnershipRepository) ChangeRefCount(
+	ctx context.Context,
+	ownershipID snowflake.ID,
+	change int,
+) (*domain.FileOwnership, error) {
+	model := model.FileOwnership{}
+	result := xcontext.DB(ctx, repo.db).
+		Model(&model).
+		Clauses(clause.Returning{}).
+		Where("id=?", ownershipID).
+		Updates(map[string]any{
+			"refcount":   gorm.Expr("refcount+?", change),
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return nil, errordef.ConvertGormError(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, errordef.ErrNotFound
+	}
+
+	return model.To(), nil
+}
+
+func (repo *FileOwnershipRepository) GetUnreferenced(
+	ctx context.Context,
+	updatedBefore time.Time,
+	afterID snowflake.ID,
+	n int,
+) ([]*domain.FileOwnership, error) {
+	models := []model.FileOwnership{}
+	err := xcontext.DB(ctx, repo.db).
+		Where("refcount<=0 AND updated_at<? AND id>?", updatedBefore, afterID).
+		Order("id").
+		Limit(n).
+		Find(&models).Error
+	if err != nil {
+		return nil, errordef.ConvertGormError(err)
+	}
+
+	result := make([]*domain.FileOwnership, 0, len(models))
+	for i := range models {
+		result = append(result, models[i].To())
+	}
+
+	return result, nil
+}
+
+// DeleteUnreferenced deletes the ownership only if it is still unreferenced at
+// the time of deletion. It returns ErrNotFound otherwise.
+func (repo *FileOwnershipRepository) DeleteUnreferenced(
+	ctx context.Context,
+	ownershipID snowflake.ID,
+	updatedBefore time.Time,
+) error {
+	result := xcontext.DB(ctx, repo.db).
+		Where("id=? AND refcount<=0 AND updated_at<?", ownershipID, updatedBefore).
+		Delete(&model.FileOwnership{})
+	if result.Error != nil {
+		return errordef.ConvertGormError(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return errordef.ErrNotFound
+	}
+
+	return nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// snowflakeTimeShift is the number of bits below the timestamp of a snowflake
+// ID, it must match the layout of github.com/xybor-x/snowflake.
+const snowflakeTimeShift = 22
+
+// firstSnowflakeIDAt returns the smallest snowflake ID which can be generated
+// in the millisecond of t or later.
+func firstSnowflakeIDAt(t time.Time) snowflake.ID {
+	elapsed := t.UnixMilli() - snowflake.ID(0).Time()
+	if elapsed < 0 {
+		return 0
+	}
+
+	return snowflake.ID(elapsed << snowflakeTimeShift)
 }
@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/todennus/file-service/infras/database/model"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/xcontext"
+	"github.com/xybor-x/snowflake"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FileReferenceRepository struct {
+	db *gorm.DB
+}
+
+func NewFileReferenceRepository(db *gorm.DB) *FileReferenceRepository {
+	return &FileReferenceRepository{db: db}
+}
+
+// Create returns ErrDuplicated if the reference already exists. Unlike a failed
+// insert, it does not abort the current transaction.
+func (repo *FileReferenceRepository) Create(ctx context.Context, reference *domain.FileReference) error {
+	result := xcontext.DB(ctx, repo.db).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(model.NewFileReference(reference))
+	if result.Error != nil {
+		return errordef.ConvertGormError(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return errordef.ErrDuplicated
+	}
+
+	return nil
+}
+
+// Delete returns ErrNotFound if the reference does not exist.
+func (repo *FileReferenceRepository) Delete(ctx context.Context, reference *domain.FileReference) error {
+	result := xcontext.DB(ctx, repo.db).
+		Where("ownership_id=? AND service=? AND entity_type=? AND entity_id=?",
+			reference.OwnershipID, reference.Service, reference.EntityType, reference.EntityID).
+		Delete(&model.FileReference{})
+	if result.Error != nil {
+		return errordef.ConvertGormError(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return errordef.ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteAny deletes one of the references of the ownership from the service
+// and the entity type, and returns it. It returns ErrNotFound if there is no
+// such reference.
+func (repo *FileReferenceRepository) DeleteAny(
+	ctx context.Context,
+	ownershipID snowflake.ID,
+	service string,
+	entityType string,
+) (*domain.FileReference, error) {
+	model := model.FileReference{}
+	err := xcontext.DB(ctx, repo.db).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("ownership_id=? AND service=? AND entity_type=?", ownershipID, service, entityType).
+		Take(&model).Error
+	if err != nil {
+		return nil, errordef.ConvertGormError(err)
+	}
+
+	reference := model.To()
+	if err := repo.Delete(ctx, reference); err != nil {
+		return nil, err
+	}
+
+	return reference, nil
+}
+
+func (repo *FileReferenceRepository) GetByOwnership(
+	ctx context.Context,
+	ownershipID snowflake.ID,
+) ([]*domain.FileReference, error) {
+	models := []model.FileReference{}
+	err := xcontext.DB(ctx, repo.db).
+		Where("ownership_id=?", ownershipID).
+		Order("created_at").
+		Find(&models).Error
+	if err != nil {
+		return nil, errordef.ConvertGormError(err)
+	}
+
+	result := make([]*domain.FileReference, 0, len(models))
+	for i := range models {
+		result = append(result, models[i].To())
+	}
+
+	return result, nil
+}
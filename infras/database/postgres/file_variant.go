@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/todennus/file-service/infras/database/model"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/xcontext"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FileVariantRepository struct {
+	db *gorm.DB
+}
+
+func NewFileVariantRepository(db *gorm.DB) *FileVariantRepository {
+	return &FileVariantRepository{db: db}
+}
+
+// Create returns ErrDuplicated if the variant has already been created.
+func (repo *FileVariantRepository) Create(ctx context.Context, variant *domain.FileVariant) error {
+	result := xcontext.DB(ctx, repo.db).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(model.NewFileVariant(variant))
+	if result.Error != nil {
+		return errordef.ConvertGormError(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return errordef.ErrDuplicated
+	}
+
+	return nil
+}
+
+func (repo *FileVariantRepository) Get(ctx context.Context, fileID string, name string) (*domain.FileVariant, error) {
+	model := model.FileVariant{}
+	if err := xcontext.DB(ctx, repo.db).Where("file_id=? AND name=?", fileID, name).Take(&model).Error; err != nil {
+		return nil, errordef.ConvertGormError(err)
+	}
+
+	return model.To(), nil
+}
+
+func (repo *FileVariantRepository) GetByFile(ctx context.Context, fileID string) ([]*domain.FileVariant, error) {
+	models := []model.FileVariant{}
+	if err := xcontext.DB(ctx, repo.db).Where("file_id=?", fileID).Find(&models).Error; err != nil {
+		return nil, errordef.ConvertGormError(err)
+	}
+
+	result := make([]*domain.FileVariant, 0, len(models))
+	for i := range models {
+		result = append(result, models[i].To())
+	}
+
+	return result, nil
+}
+
+// ChangeBucket moves all variants of the file to the bucket of the file.
+func (repo *FileVariantRepository) ChangeBucket(ctx context.Context, file *domain.FileInfo) error {
+	err := xcontext.DB(ctx, repo.db).
+		Model(&model.FileVariant{}).
+		Where("file_id=?", file.ID).
+		Update("bucket", file.Metadata.Bucket).Error
+	return errordef.ConvertGormError(err)
+}
+
+func (repo *FileVariantRepository) DeleteByFile(ctx context.Context, fileID string) error {
+	err := xcontext.DB(ctx, repo.db).Where("file_id=?", fileID).Delete(&model.FileVariant{}).Error
+	return errordef.ConvertGormError(err)
+}
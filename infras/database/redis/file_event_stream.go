@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/todennus/file-service/domain"
+	"github.com/todennus/shared/errordef"
+)
+
+type FileEventStreamRepository struct {
+	redis *redis.Client
+
+	stream string
+
+	// maxLen is the approximate number of events kept in the stream, older
+	// events are trimmed.
+	maxLen int64
+}
+
+func NewFileEventStreamRepository(redis *redis.Client, stream string, maxLen int64) *FileEventStreamRepository {
+	return &FileEventStreamRepository{redis: redis, stream: stream, maxLen: maxLen}
+}
+
+// Publish appends the events to the stream in order. The events are appended
+// in a MULTI/EXEC transaction, so either all or none of them are appended.
+func (repo *FileEventStreamRepository) Publish(ctx context.Context, events []*domain.FileEvent) error {
+	_, err := repo.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, event := range events {
+			payload, err := json.Marshal(event.Payload)
+			if err != nil {
+				return err
+			}
+
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: repo.stream,
+				MaxLen: repo.maxLen,
+				Approx: true,
+				Values: map[string]any{
+					"id":         event.ID.String(),
+					"type":       string(event.Type),
+					"file_id":    event.FileID,
+					"payload":    string(payload),
+					"created_at": event.CreatedAt.UnixMilli(),
+				},
+			})
+		}
+
+		return nil
+	})
+
+	return errordef.ConvertRedisError(err)
+}
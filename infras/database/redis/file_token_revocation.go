@@ -0,0 +1,104 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/todennus/file-service/domain"
+	"github.com/todennus/shared/errordef"
+	"github.com/xybor-x/snowflake"
+)
+
+func revokedTokenKey(id snowflake.ID) string {
+	return fmt.Sprintf("file:revoked_token:%s", id)
+}
+
+func revokedOwnershipKey(id snowflake.ID) string {
+	return fmt.Sprintf("file:revoked_ownership:%s", id)
+}
+
+func revokedUserKey(id snowflake.ID) string {
+	return fmt.Sprintf("file:revoked_user:%s", id)
+}
+
+// FileTokenRevocationRepository keeps the revocations until all the tokens
+// they revoke have expired. The value of a key is the expiration of the
+// revocation in unix milliseconds.
+type FileTokenRevocationRepository struct {
+	redis *redis.Client
+}
+
+func NewFileTokenRevocationRepository(redis *redis.Client) *FileTokenRevocationRepository {
+	return &FileTokenRevocationRepository{redis: redis}
+}
+
+// Save overwrites the previous revocation of the same ownership or user. The
+// new one expires later, so it revokes at least the same tokens.
+func (repo *FileTokenRevocationRepository) Save(ctx context.Context, revocation *domain.FileTokenRevocation) error {
+	var key string
+	switch {
+	case revocation.TokenID != 0:
+		key = revokedTokenKey(revocation.TokenID)
+	case revocation.OwnershipID != 0:
+		key = revokedOwnershipKey(revocation.OwnershipID)
+	default:
+		key = revokedUserKey(revocation.UserID)
+	}
+
+	return errordef.ConvertRedisError(
+		repo.redis.SetEx(
+			ctx,
+			key,
+			revocation.ExpiresAt.UnixMilli(),
+			time.Until(revocation.ExpiresAt),
+		).Err(),
+	)
+}
+
+// GetByToken returns the revocations of the token, of its ownership and of its
+// user in one round trip.
+func (repo *FileTokenRevocationRepository) GetByToken(
+	ctx context.Context,
+	token *domain.FileToken,
+) ([]*domain.FileTokenRevocation, error) {
+	values, err := repo.redis.MGet(
+		ctx,
+		revokedTokenKey(token.ID),
+		revokedOwnershipKey(token.OwnershipID),
+		revokedUserKey(token.UserID),
+	).Result()
+	if err != nil {
+		return nil, errordef.ConvertRedisError(err)
+	}
+
+	revocations := make([]*domain.FileTokenRevocation, 0, len(values))
+	for i, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			// The key doesn't exist.
+			continue
+		}
+
+		expiresAt, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		revocation := &domain.FileTokenRevocation{ExpiresAt: time.UnixMilli(expiresAt)}
+		switch i {
+		case 0:
+			revocation.TokenID = token.ID
+		case 1:
+			revocation.OwnershipID = token.OwnershipID
+		case 2:
+			revocation.UserID = token.UserID
+		}
+
+		revocations = append(revocations, revocation)
+	}
+
+	return revocations, nil
+}
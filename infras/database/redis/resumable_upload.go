@@ -0,0 +1,122 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/todennus/file-service/domain"
+	"github.com/todennus/file-service/infras/database/model"
+	"github.com/todennus/shared/errordef"
+)
+
+var errOffsetChanged = errors.New("offset changed")
+
+func resumableUploadKey(id string) string {
+	return fmt.Sprintf("file:resumable_upload:%s", id)
+}
+
+type ResumableUploadRepository struct {
+	redis *redis.Client
+}
+
+func NewResumableUploadRepository(redis *redis.Client) *ResumableUploadRepository {
+	return &ResumableUploadRepository{redis: redis}
+}
+
+func (repo *ResumableUploadRepository) Save(ctx context.Context, upload *domain.ResumableUpload) error {
+	recordJSON, expiration, err := marshalResumableUpload(upload)
+	if err != nil {
+		return err
+	}
+
+	return errordef.ConvertRedisError(
+		repo.redis.SetEx(ctx, resumableUploadKey(upload.ID), recordJSON, expiration).Err(),
+	)
+}
+
+// SaveIfOffset saves the upload only if the stored upload is still at the given
+// offset. It returns false if another request has changed the upload in the
+// meantime.
+func (repo *ResumableUploadRepository) SaveIfOffset(
+	ctx context.Context,
+	upload *domain.ResumableUpload,
+	offset int64,
+) (bool, error) {
+	recordJSON, expiration, err := marshalResumableUpload(upload)
+	if err != nil {
+		return false, err
+	}
+
+	key := resumableUploadKey(upload.ID)
+	err = repo.redis.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := getResumableUpload(ctx, tx, upload.ID)
+		if err != nil {
+			return err
+		}
+
+		if current.Offset != offset {
+			return errOffsetChanged
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			return pipe.SetEx(ctx, key, recordJSON, expiration).Err()
+		})
+
+		return err
+	}, key)
+
+	if errors.Is(err, errOffsetChanged) || errors.Is(err, redis.TxFailedErr) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, errordef.ConvertRedisError(err)
+	}
+
+	return true, nil
+}
+
+func (repo *ResumableUploadRepository) Get(ctx context.Context, id string) (*domain.ResumableUpload, error) {
+	upload, err := getResumableUpload(ctx, repo.redis, id)
+	if err != nil {
+		return nil, errordef.ConvertRedisError(err)
+	}
+
+	return upload, nil
+}
+
+func (repo *ResumableUploadRepository) Delete(ctx context.Context, id string) error {
+	return errordef.ConvertRedisError(repo.redis.Del(ctx, resumableUploadKey(id)).Err())
+}
+
+func getResumableUpload(ctx context.Context, client redis.Cmdable, id string) (*domain.ResumableUpload, error) {
+	recordJSON, err := client.Get(ctx, resumableUploadKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	record := model.ResumableUpload{}
+	if err := json.Unmarshal([]byte(recordJSON), &record); err != nil {
+		return nil, err
+	}
+
+	return record.To(id), nil
+}
+
+func marshalResumableUpload(upload *domain.ResumableUpload) ([]byte, time.Duration, error) {
+	expiration := time.Until(upload.Policy.ExpiresAt)
+	if expiration <= 0 {
+		return nil, 0, errordef.ErrNotFound
+	}
+
+	recordJSON, err := json.Marshal(model.NewResumableUpload(upload))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return recordJSON, expiration, nil
+}
@@ -0,0 +1,291 @@
+package detector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+const (
+	// zipMaxCommentSize is the maximum size of the comment which follows the
+	// end of central directory record.
+	zipMaxCommentSize = 65535
+
+	// zipMaxDirectorySize bounds the central directory which is read, larger
+	// archives are only inspected by their first entries.
+	zipMaxDirectorySize = 4 << 20 // 4MiB
+)
+
+func matchZip(header []byte) bool {
+	return bytes.HasPrefix(header, []byte("PK\x03\x04"))
+}
+
+// detectZip inspects the central directory of zip archives to tell apart the
+// formats based on zip: office documents, EPUB, JAR, APK, ...
+func detectZip(header []byte, content io.ReadSeeker, size int64) (string, error) {
+	entries, err := readZipDirectory(content, size)
+	if err != nil || entries == nil {
+		return "application/zip", err
+	}
+
+	names := map[string]bool{}
+	for _, entry := range entries {
+		names[entry.name] = true
+
+		// Open document formats and EPUB declare their type in a stored
+		// mimetype entry.
+		if entry.name == "mimetype" && entry.method == 0 && entry.size > 0 && entry.size <= 128 {
+			declaredType, err := readZipStoredEntry(content, entry)
+			if err != nil {
+				return "", err
+			}
+
+			if strings.Contains(declaredType, "/") {
+				return declaredType, nil
+			}
+		}
+	}
+
+	hasPrefix := func(prefix string) bool {
+		for name := range names {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	switch {
+	case names["[Content_Types].xml"] && hasPrefix("word/"):
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document", nil
+	case names["[Content_Types].xml"] && hasPrefix("xl/"):
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", nil
+	case names["[Content_Types].xml"] && hasPrefix("ppt/"):
+		return "application/vnd.openxmlformats-officedocument.presentationml.presentation", nil
+	case names["AndroidManifest.xml"] && names["classes.dex"]:
+		return "application/vnd.android.package-archive", nil
+	case names["META-INF/MANIFEST.MF"]:
+		return "application/java-archive", nil
+	}
+
+	return "application/zip", nil
+}
+
+type zipEntry struct {
+	name   string
+	method uint16
+	size   uint32
+
+	// offset is the position of the local header of the entry.
+	offset uint32
+}
+
+// readZipDirectory returns the entries of the central directory. It returns
+// nil if the directory cannot be found, e.g. for ZIP64 archives.
+func readZipDirectory(content io.ReadSeeker, size int64) ([]zipEntry, error) {
+	if size < 22 {
+		return nil, nil
+	}
+
+	tailSize := min(size, 22+zipMaxCommentSize)
+	tail, err := readAt(content, size-tailSize, tailSize)
+	if err != nil {
+		return nil, err
+	}
+
+	end := bytes.LastIndex(tail, []byte("PK\x05\x06"))
+	if end < 0 || len(tail)-end < 22 {
+		return nil, nil
+	}
+
+	directorySize := int64(binary.LittleEndian.Uint32(tail[end+12:]))
+	directoryOffset := int64(binary.LittleEndian.Uint32(tail[end+16:]))
+	if directoryOffset+directorySize > size || directoryOffset == 0xFFFFFFFF {
+		return nil, nil
+	}
+
+	directory, err := readAt(content, directoryOffset, min(directorySize, zipMaxDirectorySize))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []zipEntry{}
+	for i := 0; i+46 <= len(directory); {
+		record := directory[i:]
+		if !bytes.HasPrefix(record, []byte("PK\x01\x02")) {
+			break
+		}
+
+		nameLength := int(binary.LittleEndian.Uint16(record[28:]))
+		extraLength := int(binary.LittleEndian.Uint16(record[30:]))
+		commentLength := int(binary.LittleEndian.Uint16(record[32:]))
+		if 46+nameLength > len(record) {
+			break
+		}
+
+		entries = append(entries, zipEntry{
+			name:   string(record[46 : 46+nameLength]),
+			method: binary.LittleEndian.Uint16(record[10:]),
+			size:   binary.LittleEndian.Uint32(record[20:]),
+			offset: binary.LittleEndian.Uint32(record[42:]),
+		})
+
+		i += 46 + nameLength + extraLength + commentLength
+	}
+
+	return entries, nil
+}
+
+// readZipStoredEntry returns the content of an uncompressed entry.
+func readZipStoredEntry(content io.ReadSeeker, entry zipEntry) (string, error) {
+	local, err := readAt(content, int64(entry.offset), 30)
+	if err != nil || !bytes.HasPrefix(local, []byte("PK\x03\x04")) {
+		return "", nil
+	}
+
+	dataOffset := int64(entry.offset) + 30 +
+		int64(binary.LittleEndian.Uint16(local[26:])) + int64(binary.LittleEndian.Uint16(local[28:]))
+
+	data, err := readAt(content, dataOffset, int64(entry.size))
+	if err != nil {
+		return "", nil
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// isoBMFFBrand is the type of the brand of an ftyp box. Generic brands only
+// tell that the content is an ISO-BMFF image or video, a more specific brand
+// is looked for among the compatible ones.
+type isoBMFFBrand struct {
+	contentType string
+	generic     bool
+}
+
+var isoBMFFBrands = map[string]isoBMFFBrand{
+	"avif": {"image/avif", false},
+	"avis": {"image/avif", false},
+	"heic": {"image/heic", false},
+	"heix": {"image/heic", false},
+	"heim": {"image/heic", false},
+	"heis": {"image/heic", false},
+	"hevc": {"image/heic-sequence", false},
+	"hevx": {"image/heic-sequence", false},
+	"mif1": {"image/heif", true},
+	"msf1": {"image/heif-sequence", true},
+	"crx ": {"image/x-canon-cr3", false},
+	"qt  ": {"video/quicktime", false},
+	"M4A ": {"audio/mp4", false},
+	"M4B ": {"audio/mp4", false},
+	"M4V ": {"video/x-m4v", false},
+	"3gp4": {"video/3gpp", false},
+	"3gp5": {"video/3gpp", false},
+	"3gp6": {"video/3gpp", false},
+	"3g2a": {"video/3gpp2", false},
+	"isom": {"video/mp4", true},
+	"iso2": {"video/mp4", true},
+	"iso4": {"video/mp4", true},
+	"iso5": {"video/mp4", true},
+	"iso6": {"video/mp4", true},
+	"mp41": {"video/mp4", true},
+	"mp42": {"video/mp4", true},
+	"avc1": {"video/mp4", true},
+	"dash": {"video/mp4", true},
+}
+
+// detectISOBMFF reads the major and compatible brands of the ftyp box which
+// starts ISO-BMFF files (MP4, QuickTime, HEIF, AVIF, 3GP, ...).
+func detectISOBMFF(header []byte) string {
+	if len(header) < 16 || string(header[4:8]) != "ftyp" {
+		return ""
+	}
+
+	boxSize := min(int(binary.BigEndian.Uint32(header)), len(header))
+	brands := []string{string(header[8:12])}
+	for i := 16; i+4 <= boxSize; i += 4 {
+		brands = append(brands, string(header[i:i+4]))
+	}
+
+	detectedType := ""
+	for _, name := range brands {
+		brand, ok := isoBMFFBrands[name]
+		if !ok {
+			continue
+		}
+
+		if !brand.generic {
+			return brand.contentType
+		}
+
+		if detectedType == "" {
+			detectedType = brand.contentType
+		}
+	}
+
+	return detectedType
+}
+
+// detectRIFF reads the form type of RIFF files. The names of the types which
+// http.DetectContentType recognizes are kept.
+func detectRIFF(header []byte) string {
+	if len(header) < 12 || string(header[:4]) != "RIFF" {
+		return ""
+	}
+
+	switch string(header[8:12]) {
+	case "WAVE":
+		return "audio/wave"
+	case "AVI ":
+		return "video/avi"
+	case "WEBP":
+		return "image/webp"
+	case "RMID":
+		return "audio/midi"
+	case "ACON":
+		return "application/x-navi-animation"
+	}
+
+	return ""
+}
+
+// detectEBML tells apart WebM from other Matroska files by the document type
+// in the EBML header.
+func detectEBML(header []byte) string {
+	if !bytes.HasPrefix(header, []byte("\x1a\x45\xdf\xa3")) {
+		return ""
+	}
+
+	if bytes.Contains(header[:min(len(header), 64)], []byte("matroska")) {
+		return "video/x-matroska"
+	}
+
+	return "video/webm"
+}
+
+// detectOgg reads the codec of the first stream of Ogg files.
+func detectOgg(header []byte) string {
+	if !bytes.HasPrefix(header, []byte("OggS")) || len(header) < 28 {
+		return ""
+	}
+
+	// The first packet follows the page header and its segment table.
+	start := 27 + int(header[26])
+	if start > len(header) {
+		return "application/ogg"
+	}
+
+	packet := header[start:]
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")),
+		bytes.HasPrefix(packet, []byte("OpusHead")),
+		bytes.HasPrefix(packet, []byte("\x7fFLAC")),
+		bytes.HasPrefix(packet, []byte("Speex   ")):
+		return "audio/ogg"
+	case bytes.HasPrefix(packet, []byte("\x80theora")):
+		return "video/ogg"
+	}
+
+	return "application/ogg"
+}
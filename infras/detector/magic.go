@@ -0,0 +1,61 @@
+package detector
+
+import "bytes"
+
+type magicNumber struct {
+	offset      int
+	prefix      string
+	contentType string
+}
+
+// magicNumbers are the binary formats which http.DetectContentType doesn't
+// recognize.
+var magicNumbers = []magicNumber{
+	{0, "II*\x00", "image/tiff"},
+	{0, "MM\x00*", "image/tiff"},
+	{0, "8BPS", "image/vnd.adobe.photoshop"},
+	{0, "\x00\x00\x00\x0cjP  \r\n\x87\n", "image/jp2"},
+	{0, "\xff\x0a", "image/jxl"},
+	{0, "\x00\x00\x00\x0cJXL \r\n\x87\n", "image/jxl"},
+	{0, "fLaC", "audio/flac"},
+	{0, "#!AMR", "audio/amr"},
+	{0, "FLV\x01", "video/x-flv"},
+	{0, "\x00\x00\x01\xba", "video/mpeg"},
+	{0, "\x00\x00\x01\xb3", "video/mpeg"},
+	{0, "BZh", "application/x-bzip2"},
+	{0, "\xfd7zXZ\x00", "application/x-xz"},
+	{0, "7z\xbc\xaf\x27\x1c", "application/x-7z-compressed"},
+	{0, "\x28\xb5\x2f\xfd", "application/zstd"},
+	{0, "SQLite format 3\x00", "application/vnd.sqlite3"},
+	{0, "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1", "application/x-ole-storage"},
+	{257, "ustar", "application/x-tar"},
+}
+
+// detectMagic recognizes the binary formats by their magic numbers. MP3
+// without ID3 tags, AAC and MPEG transport streams are recognized by their
+// frame headers.
+func detectMagic(header []byte) string {
+	for _, magic := range magicNumbers {
+		if len(header) >= magic.offset && bytes.HasPrefix(header[magic.offset:], []byte(magic.prefix)) {
+			return magic.contentType
+		}
+	}
+
+	if len(header) >= 2 && header[0] == 0xff {
+		switch header[1] & 0xf6 {
+		case 0xf2:
+			// MPEG-1 or MPEG-2 audio layer III.
+			return "audio/mpeg"
+		case 0xf0:
+			// ADTS, whose layer is always 0.
+			return "audio/aac"
+		}
+	}
+
+	// Transport stream packets are 188 bytes long and start with a sync byte.
+	if len(header) > 2*188 && header[0] == 0x47 && header[188] == 0x47 && header[2*188] == 0x47 {
+		return "video/mp2t"
+	}
+
+	return ""
+}
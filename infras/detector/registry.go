@@ -0,0 +1,168 @@
+package detector
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// headerSize is the number of bytes read from the beginning of the content and
+// given to every detector.
+const headerSize = 1024
+
+// Detector recognizes some content types. It returns an empty string if the
+// content is not one of them. Most detectors only look at the header, the
+// others may seek anywhere in the content.
+type Detector interface {
+	Detect(header []byte, content io.ReadSeeker, size int64) (string, error)
+}
+
+type DetectorFunc func(header []byte, content io.ReadSeeker, size int64) (string, error)
+
+func (f DetectorFunc) Detect(header []byte, content io.ReadSeeker, size int64) (string, error) {
+	return f(header, content, size)
+}
+
+// HeaderDetector is a detector which only looks at the header.
+type HeaderDetector func(header []byte) string
+
+func (f HeaderDetector) Detect(header []byte, content io.ReadSeeker, size int64) (string, error) {
+	return f(header), nil
+}
+
+// ContentDetector is a detector which needs the content, but only if the
+// header matches.
+type ContentDetector struct {
+	Matches  func(header []byte) bool
+	Detector DetectorFunc
+}
+
+func (d ContentDetector) Detect(header []byte, content io.ReadSeeker, size int64) (string, error) {
+	if !d.Matches(header) {
+		return "", nil
+	}
+
+	return d.Detector(header, content, size)
+}
+
+// Registry asks its detectors in order, the first recognized type wins. If no
+// detector recognizes the content, the type is detected by
+// http.DetectContentType.
+type Registry struct {
+	detectors []Detector
+}
+
+func NewRegistry(detectors ...Detector) *Registry {
+	return &Registry{detectors: detectors}
+}
+
+// NewDefaultRegistry recognizes the containers (zip, ISO-BMFF, RIFF, EBML, Ogg)
+// first, then the binary formats by their magic numbers, then the text
+// formats. The types recognized by http.DetectContentType keep their names.
+func NewDefaultRegistry() *Registry {
+	return NewRegistry(
+		ContentDetector{Matches: matchZip, Detector: detectZip},
+		HeaderDetector(detectISOBMFF),
+		HeaderDetector(detectRIFF),
+		HeaderDetector(detectEBML),
+		HeaderDetector(detectOgg),
+		HeaderDetector(detectMagic),
+		HeaderDetector(detectSVG),
+		ContentDetector{Matches: matchJSON, Detector: detectJSON},
+		HeaderDetector(detectCSV),
+		HeaderDetector(detectMarkdown),
+	)
+}
+
+// Register adds a detector after the existing ones.
+func (r *Registry) Register(detector Detector) {
+	r.detectors = append(r.detectors, detector)
+}
+
+// Detect returns the content type of the content, then rewinds it.
+func (r *Registry) Detect(content io.ReadSeeker) (string, error) {
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := readAt(content, 0, min(size, headerSize))
+	if err != nil {
+		return "", err
+	}
+
+	detectedType := ""
+	for _, detector := range r.detectors {
+		if detectedType, err = detector.Detect(header, content, size); err != nil {
+			return "", err
+		}
+
+		if detectedType != "" {
+			break
+		}
+	}
+
+	if detectedType == "" {
+		detectedType = http.DetectContentType(header)
+	}
+
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return detectedType, nil
+}
+
+// Peek detects the type from the header of the content when it is enough, that
+// is when no detector needing the content matches the header before the type
+// is recognized. Otherwise, it returns an empty type. The returned reader reads
+// the whole content, including the peeked header.
+func (r *Registry) Peek(content io.Reader) (string, io.Reader, error) {
+	reader := bufio.NewReaderSize(content, headerSize)
+	header, err := reader.Peek(headerSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", nil, err
+	}
+
+	return r.detectHeader(header), reader, nil
+}
+
+func (r *Registry) detectHeader(header []byte) string {
+	for _, detector := range r.detectors {
+		switch detector := detector.(type) {
+		case HeaderDetector:
+			if detectedType := detector(header); detectedType != "" {
+				return detectedType
+			}
+
+		case ContentDetector:
+			if detector.Matches(header) {
+				return ""
+			}
+
+		default:
+			return ""
+		}
+	}
+
+	return http.DetectContentType(header)
+}
+
+// readAt reads exactly n bytes at the offset.
+func readAt(content io.ReadSeeker, offset int64, n int64) ([]byte, error) {
+	if _, err := content.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	buffer := make([]byte, n)
+	if _, err := io.ReadFull(content, buffer); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errors.New("the content is shorter than its size")
+		}
+
+		return nil, err
+	}
+
+	return buffer, nil
+}
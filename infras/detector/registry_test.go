@@ -0,0 +1,382 @@
+package detector
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+)
+
+type zipFile struct {
+	name    string
+	content string
+	stored  bool
+}
+
+func newZip(t *testing.T, files ...zipFile) []byte {
+	t.Helper()
+
+	buffer := bytes.Buffer{}
+	writer := zip.NewWriter(&buffer)
+	for _, file := range files {
+		method := zip.Deflate
+		if file.stored {
+			method = zip.Store
+		}
+
+		w, err := writer.CreateHeader(&zip.FileHeader{Name: file.name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := w.Write([]byte(file.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func newImage(t *testing.T, encode func(io.Writer, image.Image) error) []byte {
+	t.Helper()
+
+	buffer := bytes.Buffer{}
+	if err := encode(&buffer, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func newTar(t *testing.T) []byte {
+	t.Helper()
+
+	buffer := bytes.Buffer{}
+	writer := tar.NewWriter(&buffer)
+	if err := writer.WriteHeader(&tar.Header{Name: "a.txt", Mode: 0o644, Size: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := writer.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func newGzip(t *testing.T) []byte {
+	t.Helper()
+
+	buffer := bytes.Buffer{}
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+// newFtyp returns an ftyp box with the major brand followed by the compatible
+// brands.
+func newFtyp(major string, compatible ...string) []byte {
+	box := binary.BigEndian.AppendUint32(nil, uint32(16+4*len(compatible)))
+	box = append(box, "ftyp"+major+"\x00\x00\x02\x00"...)
+	for _, brand := range compatible {
+		box = append(box, brand...)
+	}
+
+	// A following box, so the ftyp box doesn't end the content.
+	return append(box, "\x00\x00\x00\x08free"...)
+}
+
+// newOggPage returns the first page of an Ogg stream holding the packet.
+func newOggPage(packet string) []byte {
+	page := []byte("OggS\x00\x02")
+	page = append(page, make([]byte, 8+4+4+4)...)
+	page = append(page, 1, byte(len(packet)))
+	return append(page, packet...)
+}
+
+func newRIFF(form string, chunk string) []byte {
+	riff := []byte("RIFF")
+	riff = binary.LittleEndian.AppendUint32(riff, uint32(4+len(chunk)))
+	return append(append(riff, form...), chunk...)
+}
+
+func newTransportStream() []byte {
+	stream := make([]byte, 3*188)
+	for i := 0; i < len(stream); i += 188 {
+		stream[i] = 0x47
+	}
+
+	return stream
+}
+
+func repeat(line string, size int) string {
+	return strings.Repeat(line, size/len(line)+1)
+}
+
+const (
+	docxType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	xlsxType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	pptxType = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	odtType  = "application/vnd.oasis.opendocument.text"
+)
+
+func TestRegistryDetect(t *testing.T) {
+	contentTypes := zipFile{name: "[Content_Types].xml", content: "<Types/>"}
+	pngImage := newImage(t, png.Encode)
+	docx := newZip(t, contentTypes, zipFile{name: "word/document.xml", content: "<w:document/>"})
+	zipArchive := newZip(t, zipFile{name: "a.txt", content: "hello"})
+
+	testcases := []struct {
+		name     string
+		content  []byte
+		expected string
+	}{
+		// Magic numbers.
+		{"png", pngImage, "image/png"},
+		{"jpeg", newImage(t, func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, nil) }), "image/jpeg"},
+		{"gif", newImage(t, func(w io.Writer, img image.Image) error { return gif.Encode(w, img, nil) }), "image/gif"},
+		{"bmp", []byte("BM\x46\x00\x00\x00\x00\x00\x00\x00\x36\x00\x00\x00"), "image/bmp"},
+		{"tiff little endian", []byte("II*\x00\x08\x00\x00\x00"), "image/tiff"},
+		{"tiff big endian", []byte("MM\x00*\x00\x00\x00\x08"), "image/tiff"},
+		{"psd", []byte("8BPS\x00\x01\x00\x00\x00\x00\x00\x00"), "image/vnd.adobe.photoshop"},
+		{"jpeg 2000", []byte("\x00\x00\x00\x0cjP  \r\n\x87\n\x00\x00\x00\x14ftypjp2 "), "image/jp2"},
+		{"jpeg xl codestream", []byte("\xff\x0a\xfa\x7f"), "image/jxl"},
+		{"jpeg xl container", []byte("\x00\x00\x00\x0cJXL \r\n\x87\n"), "image/jxl"},
+		{"pdf", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj\n"), "application/pdf"},
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), "audio/flac"},
+		{"amr", []byte("#!AMR\n\x3c"), "audio/amr"},
+		{"mp3 with id3", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), "audio/mpeg"},
+		{"mp3 frame", []byte("\xff\xfb\x90\x64\x00\x00\x00\x00"), "audio/mpeg"},
+		{"aac", []byte("\xff\xf1\x50\x80\x02\x1f\xfc"), "audio/aac"},
+		{"flv", []byte("FLV\x01\x05\x00\x00\x00\x09"), "video/x-flv"},
+		{"mpeg program stream", []byte("\x00\x00\x01\xba\x44\x00\x04"), "video/mpeg"},
+		{"mpeg transport stream", newTransportStream(), "video/mp2t"},
+		{"gzip", newGzip(t), "application/x-gzip"},
+		{"bzip2", []byte("BZh91AY&SY"), "application/x-bzip2"},
+		{"xz", []byte("\xfd7zXZ\x00\x00\x04"), "application/x-xz"},
+		{"7z", []byte("7z\xbc\xaf\x27\x1c\x00\x04"), "application/x-7z-compressed"},
+		{"zstd", []byte("\x28\xb5\x2f\xfd\x24\x05"), "application/zstd"},
+		{"tar", newTar(t), "application/x-tar"},
+		{"sqlite", []byte("SQLite format 3\x00\x10\x00"), "application/vnd.sqlite3"},
+		{"ole", []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1\x00\x00"), "application/x-ole-storage"},
+
+		// Zip based formats.
+		{"zip", zipArchive, "application/zip"},
+		{"docx", docx, docxType},
+		{"xlsx", newZip(t, contentTypes, zipFile{name: "xl/workbook.xml", content: "<workbook/>"}), xlsxType},
+		{"pptx", newZip(t, contentTypes, zipFile{name: "ppt/presentation.xml", content: "<p/>"}), pptxType},
+		{"odt", newZip(t,
+			zipFile{name: "mimetype", content: odtType, stored: true},
+			zipFile{name: "content.xml", content: "<office:document-content/>"},
+		), odtType},
+		{"epub", newZip(t,
+			zipFile{name: "mimetype", content: "application/epub+zip", stored: true},
+			zipFile{name: "META-INF/container.xml", content: "<container/>"},
+		), "application/epub+zip"},
+		{"jar", newZip(t, zipFile{name: "META-INF/MANIFEST.MF", content: "Manifest-Version: 1.0\n"}), "application/java-archive"},
+		{"apk", newZip(t,
+			zipFile{name: "AndroidManifest.xml", content: "\x03\x00\x08\x00"},
+			zipFile{name: "classes.dex", content: "dex\n035\x00"},
+			zipFile{name: "META-INF/MANIFEST.MF", content: "Manifest-Version: 1.0\n"},
+		), "application/vnd.android.package-archive"},
+		{"docx with the directory after the header", newZip(t,
+			contentTypes,
+			zipFile{name: "word/media/image.bin", content: repeat("\x00\x01\x02\x03", 4*headerSize), stored: true},
+			zipFile{name: "word/document.xml", content: "<w:document/>"},
+		), docxType},
+		{"odt with a deflated mimetype", newZip(t,
+			zipFile{name: "mimetype", content: odtType},
+			zipFile{name: "content.xml", content: "<office:document-content/>"},
+		), "application/zip"},
+		{"odt with a mimetype which is not a type", newZip(t,
+			zipFile{name: "mimetype", content: "text", stored: true},
+		), "application/zip"},
+
+		// ISO-BMFF, RIFF, EBML and Ogg containers.
+		{"avif", newFtyp("avif", "mif1", "miaf"), "image/avif"},
+		{"avif as a compatible brand", newFtyp("mif1", "mif1", "avif"), "image/avif"},
+		{"heic", newFtyp("heic", "mif1", "heic"), "image/heic"},
+		{"heif", newFtyp("mif1", "mif1"), "image/heif"},
+		{"mp4", newFtyp("isom", "isom", "iso2", "avc1", "mp41"), "video/mp4"},
+		{"m4a", newFtyp("M4A ", "M4A ", "mp42", "isom"), "audio/mp4"},
+		{"quicktime", newFtyp("qt  ", "qt  "), "video/quicktime"},
+		{"3gp", newFtyp("3gp5", "3gp5", "isom"), "video/3gpp"},
+		{"unknown ftyp brand", newFtyp("xxxx", "yyyy"), "application/octet-stream"},
+		{"webp", newRIFF("WEBP", "VP8 \x00\x00\x00\x00"), "image/webp"},
+		{"wav", newRIFF("WAVE", "fmt \x10\x00\x00\x00"), "audio/wave"},
+		{"avi", newRIFF("AVI ", "LIST\x00\x00\x00\x00"), "video/avi"},
+		{"webm", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm\x42\x87\x81\x04"), "video/webm"},
+		{"matroska", []byte("\x1a\x45\xdf\xa3\xa3\x42\x86\x81\x01\x42\x82\x88matroska\x42\x87\x81\x04"), "video/x-matroska"},
+		{"ogg vorbis", newOggPage("\x01vorbis\x00\x00\x00\x00\x02"), "audio/ogg"},
+		{"ogg opus", newOggPage("OpusHead\x01\x02"), "audio/ogg"},
+		{"ogg theora", newOggPage("\x80theora\x03\x02\x01"), "video/ogg"},
+		{"ogg unknown codec", newOggPage("\x01unknown"), "application/ogg"},
+
+		// Text formats.
+		{"json object", []byte(`{"name": "file", "tags": ["a", "b"], "nested": {"size": 1}}`), "application/json"},
+		{"json array with a bom", []byte("\xef\xbb\xbf \n[1, 2, {\"a\": null}]\n"), "application/json"},
+		{"json larger than the header", []byte(`[` + strings.Repeat(`"value",`, headerSize) + `"value"]`), "application/json"},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="1" height="1"/>`), "image/svg+xml"},
+		{"svg with a prolog", []byte(`<?xml version="1.0"?>
+<!-- generated -->
+<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd">
+<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`), "image/svg+xml"},
+		{"xml", []byte(`<?xml version="1.0"?><feed/>`), "text/xml; charset=utf-8"},
+		{"html", []byte("<!DOCTYPE html><html><body><svg></svg></body></html>"), "text/html; charset=utf-8"},
+		{"csv", []byte("name,size,type\nimage.png,10,image/png\nnote.txt,5,text/plain\n"), "text/csv"},
+		{"csv with semicolons", []byte("name;size\nimage.png;10\n"), "text/csv"},
+		{"tsv", []byte("name\tsize\nimage.png\t10\n"), "text/tab-separated-values"},
+		{"csv larger than the header", []byte(repeat("a,b,c\n", 2*headerSize)), "text/csv"},
+		{"markdown", []byte("# Title\n\nSome **bold** text with a [link](https://example.com).\n\n- item\n"), "text/markdown"},
+		{"markdown with a fence", []byte("```go\nfmt.Println()\n```\n\n1. first\n> quote\n"), "text/markdown"},
+		{"plain text", []byte("Just a sentence, nothing else.\n"), "text/plain; charset=utf-8"},
+		{"text with a single heading", []byte("# Title\nplain text\n"), "text/plain; charset=utf-8"},
+		{"empty", []byte{}, "text/plain; charset=utf-8"},
+
+		// Polyglots are detected by their first format.
+		{"gif with a script", []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;<script>alert(1)</script>"), "image/gif"},
+		{"png followed by a zip", append(append([]byte{}, pngImage...), zipArchive...), "image/png"},
+		{"zip after a shell script", append([]byte("#!/bin/sh\nexit 0\n"), zipArchive...), "application/octet-stream"},
+		{"html in a zip comment", append(docx[:len(docx)-2:len(docx)-2], "\x11\x00<script></script>"...), docxType},
+		{"json followed by html", []byte(`{"a": 1}<script>alert(1)</script>`), "text/plain; charset=utf-8"},
+		{"two json values", []byte(`{"a": 1} {"b": 2}`), "text/plain; charset=utf-8"},
+		{"html pretending to be csv", []byte("<b>a,b</b>\n<i>c,d</i>\n"), "text/html; charset=utf-8"},
+		{"svg with binary content", []byte("<svg>\x00\x01</svg>"), "image/svg+xml"},
+
+		// Truncated contents are detected by what is left.
+		{"truncated png", pngImage[:8], "image/png"},
+		{"truncated zip", zipArchive[:30], "application/zip"},
+		{"truncated docx", docx[:len(docx)-30], "application/zip"},
+		{"zip local header only", []byte("PK\x03\x04"), "application/zip"},
+		{"zip with a directory out of bounds", append(append([]byte{}, zipArchive[:len(zipArchive)-22]...),
+			"PK\x05\x06\x00\x00\x00\x00\x01\x00\x01\x00\xff\xff\x00\x00\xff\xff\x00\x00\x00\x00"...), "application/zip"},
+		{"truncated json", []byte(`{"a": [1, 2`), "text/plain; charset=utf-8"},
+		{"truncated ftyp", []byte("\x00\x00\x00\x18ftypav"), "application/octet-stream"},
+		{"truncated riff", []byte("RIFF\x24\x00\x00\x00WA"), "application/octet-stream"},
+		{"truncated ogg", []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\xc8\xff"), "application/ogg"},
+		{"truncated utf-8 text", []byte("caf\xc3"), "text/plain; charset=utf-8"},
+	}
+
+	registry := NewDefaultRegistry()
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			content := bytes.NewReader(testcase.content)
+			detectedType, err := registry.Detect(content)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if detectedType != testcase.expected {
+				t.Errorf("got %q, expected %q", detectedType, testcase.expected)
+			}
+
+			if offset, _ := content.Seek(0, io.SeekCurrent); offset != 0 {
+				t.Errorf("the content is not rewound (offset %d)", offset)
+			}
+
+			// The type detected from the header alone must be the same, unless
+			// the whole content is needed.
+			peekedType, reader, err := registry.Peek(bytes.NewReader(testcase.content))
+			if err != nil {
+				t.Fatalf("unexpected error when peeking: %v", err)
+			}
+
+			if peekedType != "" && peekedType != detectedType {
+				t.Errorf("peeked %q, detected %q", peekedType, detectedType)
+			}
+
+			peekedContent, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("unexpected error when reading the peeked content: %v", err)
+			}
+
+			if !bytes.Equal(peekedContent, testcase.content) {
+				t.Errorf("the peeked content differs from the content")
+			}
+		})
+	}
+}
+
+func TestRegistryPeekNeedsContent(t *testing.T) {
+	testcases := []struct {
+		name    string
+		content []byte
+	}{
+		{"zip", newZip(t, zipFile{name: "a.txt", content: "hello"})},
+		{"truncated zip", []byte("PK\x03\x04")},
+		{"json", []byte(`{"a": 1}`)},
+		{"truncated json", []byte(`[1, 2`)},
+	}
+
+	registry := NewDefaultRegistry()
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			peekedType, _, err := registry.Peek(bytes.NewReader(testcase.content))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if peekedType != "" {
+				t.Errorf("got %q, expected the whole content to be needed", peekedType)
+			}
+		})
+	}
+}
+
+func TestRegistryRegister(t *testing.T) {
+	registry := NewDefaultRegistry()
+	registry.Register(HeaderDetector(func(header []byte) string {
+		if bytes.HasPrefix(header, []byte("CUSTOM")) {
+			return "application/x-custom"
+		}
+
+		return ""
+	}))
+
+	detectedType, err := registry.Detect(strings.NewReader("CUSTOM\x00\x01"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if detectedType != "application/x-custom" {
+		t.Errorf("got %q, expected the registered detector to recognize the content", detectedType)
+	}
+
+	// A detector which isn't known to only look at the header makes every
+	// content after it need the whole content.
+	registry.Register(DetectorFunc(func(header []byte, content io.ReadSeeker, size int64) (string, error) {
+		return "", nil
+	}))
+
+	peekedType, _, err := registry.Peek(strings.NewReader("unknown\x00\x01"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if peekedType != "" {
+		t.Errorf("got %q, expected the whole content to be needed", peekedType)
+	}
+}
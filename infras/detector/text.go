@@ -0,0 +1,204 @@
+package detector
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"unicode/utf8"
+)
+
+// isText returns true if the header is UTF-8 text without control characters
+// other than whitespaces. The last rune may be truncated.
+func isText(header []byte) bool {
+	header = bytes.TrimPrefix(header, []byte("\xef\xbb\xbf"))
+	if len(header) == 0 {
+		return false
+	}
+
+	for i := 0; i < len(header); {
+		r, size := utf8.DecodeRune(header[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return len(header)-i < utf8.UTFMax && !utf8.FullRune(header[i:])
+		}
+
+		if (r < 0x20 && r != '\t' && r != '\n' && r != '\r' && r != '\f') || r == 0x7f {
+			return false
+		}
+
+		i += size
+	}
+
+	return true
+}
+
+// detectSVG returns image/svg+xml if the root element of the XML document is
+// svg. The XML declaration, comments and the doctype may precede it.
+func detectSVG(header []byte) string {
+	content := bytes.TrimPrefix(header, []byte("\xef\xbb\xbf"))
+	for {
+		content = bytes.TrimLeft(content, " \t\r\n")
+
+		var end []byte
+		switch {
+		case bytes.HasPrefix(content, []byte("<?")):
+			end = []byte("?>")
+		case bytes.HasPrefix(content, []byte("<!--")):
+			end = []byte("-->")
+		case bytes.HasPrefix(content, []byte("<!")):
+			end = []byte(">")
+		default:
+			rest, found := bytes.CutPrefix(content, []byte("<svg"))
+			if found && len(rest) > 0 && bytes.ContainsRune([]byte(" \t\r\n>/"), rune(rest[0])) {
+				return "image/svg+xml"
+			}
+
+			return ""
+		}
+
+		_, rest, found := bytes.Cut(content, end)
+		if !found {
+			return ""
+		}
+
+		content = rest
+	}
+}
+
+func matchJSON(header []byte) bool {
+	trimmed := trimJSONPrefix(header)
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && isText(header)
+}
+
+func trimJSONPrefix(header []byte) []byte {
+	return bytes.TrimLeft(bytes.TrimPrefix(header, []byte("\xef\xbb\xbf")), " \t\r\n")
+}
+
+// detectJSON returns application/json if the whole content is a JSON object or
+// array. The content is decoded token by token, so it is never held in memory.
+func detectJSON(header []byte, content io.ReadSeeker, size int64) (string, error) {
+	trimmed := trimJSONPrefix(header)
+	if _, err := content.Seek(int64(len(header)-len(trimmed)), io.SeekStart); err != nil {
+		return "", err
+	}
+
+	decoder := json.NewDecoder(content)
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) && depth == 0 {
+				return "application/json", nil
+			}
+
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return "", nil
+			}
+
+			return "", err
+		}
+
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+
+		// Only a single value is allowed.
+		if depth == 0 && decoder.More() {
+			return "", nil
+		}
+	}
+}
+
+// csvDelimiters are the delimiters of the tabular text formats, with the type
+// of the content using them.
+var csvDelimiters = []struct {
+	delimiter   rune
+	contentType string
+}{
+	{',', "text/csv"},
+	{';', "text/csv"},
+	{'\t', "text/tab-separated-values"},
+}
+
+// isMarkup returns true if the text starts with a tag, HTML and XML are left to
+// http.DetectContentType.
+func isMarkup(header []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(bytes.TrimPrefix(header, []byte("\xef\xbb\xbf")), " \t\r\n"), []byte("<"))
+}
+
+// detectCSV returns text/csv or text/tab-separated-values if the complete lines
+// of the header are at least two records with the same number of fields.
+func detectCSV(header []byte) string {
+	if !isText(header) || isMarkup(header) {
+		return ""
+	}
+
+	// The last line may be truncated.
+	lines := header
+	if i := bytes.LastIndexByte(header, '\n'); i >= 0 && len(header) == headerSize {
+		lines = header[:i+1]
+	}
+
+	for _, candidate := range csvDelimiters {
+		if !bytes.ContainsRune(lines, candidate.delimiter) {
+			continue
+		}
+
+		reader := csv.NewReader(bytes.NewReader(lines))
+		reader.Comma = candidate.delimiter
+		records, err := reader.ReadAll()
+		if err != nil || len(records) < 2 || len(records[0]) < 2 {
+			continue
+		}
+
+		return candidate.contentType
+	}
+
+	return ""
+}
+
+var (
+	markdownHeadingPattern = regexp.MustCompile(`(?m)^#{1,6} \S`)
+	markdownFencePattern   = regexp.MustCompile("(?m)^(```|~~~)")
+
+	markdownSignalPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^\s*[-*+] \S`),            // bullet list
+		regexp.MustCompile(`(?m)^\s*\d+\. \S`),            // ordered list
+		regexp.MustCompile(`(?m)^> `),                     // quote
+		regexp.MustCompile(`\[[^\]\n]+\]\([^)\s]+\)`),     // link
+		regexp.MustCompile(`\*\*[^*\n]+\*\*|__[^_\n]+__`), // emphasis
+		regexp.MustCompile("`[^`\n]+`"),                   // inline code
+		regexp.MustCompile(`(?m)^\|.*\|\s*$`),             // table
+	}
+)
+
+// detectMarkdown returns text/markdown if the text has a heading or a fenced
+// code block, and at least two other kinds of markdown syntax.
+func detectMarkdown(header []byte) string {
+	if !isText(header) || isMarkup(header) {
+		return ""
+	}
+
+	if !markdownHeadingPattern.Match(header) && !markdownFencePattern.Match(header) {
+		return ""
+	}
+
+	signals := 0
+	for _, pattern := range markdownSignalPatterns {
+		if pattern.Match(header) {
+			signals++
+		}
+	}
+
+	if signals < 2 {
+		return ""
+	}
+
+	return "text/markdown"
+}
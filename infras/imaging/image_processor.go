@@ -0,0 +1,270 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/todennus/file-service/domain"
+)
+
+// jpegQuality is the default quality of the JPEG images encoded by the
+// processor.
+const jpegQuality = 85
+
+type codec struct {
+	format       string
+	decode       func(io.Reader) (image.Image, error)
+	decodeConfig func(io.Reader) (image.Config, error)
+}
+
+// codecs are the image formats which can be decoded, by content type.
+var codecs = map[string]codec{
+	"image/png":  {"png", png.Decode, png.DecodeConfig},
+	"image/jpeg": {"jpeg", jpeg.Decode, jpeg.DecodeConfig},
+	"image/gif":  {"gif", gif.Decode, gif.DecodeConfig},
+}
+
+// ImageProcessor reads and transforms images with the codecs of the standard
+// library.
+type ImageProcessor struct{}
+
+func NewImageProcessor() *ImageProcessor {
+	return &ImageProcessor{}
+}
+
+func (p *ImageProcessor) DecodeMetadata(content io.Reader, contentType string) (*domain.ImageMetadata, error) {
+	codec, ok := codecs[contentType]
+	if !ok {
+		return nil, nil
+	}
+
+	config, err := codec.decodeConfig(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, errors.New("invalid image dimensions")
+	}
+
+	return &domain.ImageMetadata{
+		Format: codec.format,
+		Width:  config.Width,
+		Height: config.Height,
+	}, nil
+}
+
+// Transform only keeps the first frame of animated images. Images are never
+// scaled up.
+func (p *ImageProcessor) Transform(
+	content io.Reader,
+	contentType string,
+	transform *domain.ImageTransform,
+) (*domain.EncodedImage, error) {
+	codec, ok := codecs[contentType]
+	if !ok {
+		return nil, nil
+	}
+
+	src, err := codec.decode(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return transformImage(toRGBA(src, src.Bounds()), contentType, transform)
+}
+
+// TransformAll decodes the image once and applies every transformation to it.
+func (p *ImageProcessor) TransformAll(
+	content io.Reader,
+	contentType string,
+	transforms []*domain.ImageTransform,
+) ([]*domain.EncodedImage, error) {
+	codec, ok := codecs[contentType]
+	if !ok {
+		return nil, nil
+	}
+
+	src, err := codec.decode(content)
+	if err != nil {
+		return nil, err
+	}
+
+	img := toRGBA(src, src.Bounds())
+	images := make([]*domain.EncodedImage, 0, len(transforms))
+	for _, transform := range transforms {
+		image, err := transformImage(img, contentType, transform)
+		if err != nil {
+			return nil, err
+		}
+
+		images = append(images, image)
+	}
+
+	return images, nil
+}
+
+// transformImage never modifies the source image, every step copies it.
+func transformImage(img *image.RGBA, contentType string, transform *domain.ImageTransform) (*domain.EncodedImage, error) {
+	if transform.Crop != nil {
+		area := image.Rect(0, 0, transform.Crop.Width, transform.Crop.Height).
+			Add(image.Pt(transform.Crop.X, transform.Crop.Y)).
+			Intersect(img.Bounds())
+		if area.Empty() {
+			return nil, errors.New("the crop area is outside of the image")
+		}
+
+		img = toRGBA(img, area)
+	}
+
+	if transform.Rotate != 0 {
+		img = rotate(img, transform.Rotate)
+	}
+
+	if transform.Resize != nil {
+		area, width, height := resizeGeometry(img.Bounds(), transform.Resize)
+		img = resample(img, area, width, height)
+	}
+
+	return encode(img, transform.OutputFormat(contentType), transform.Quality)
+}
+
+// resizeGeometry returns the area of the source which is kept and the size it
+// is scaled to.
+func resizeGeometry(bounds image.Rectangle, resize *domain.ResizeOption) (image.Rectangle, int, int) {
+	width, height := float64(bounds.Dx()), float64(bounds.Dy())
+
+	if resize.Mode == domain.VariantModeFill {
+		// Keep the largest area of the center which has the aspect ratio of
+		// the target, then scale it down to the target.
+		aspect := float64(resize.Width) / float64(resize.Height)
+		cropWidth := min(bounds.Dx(), max(1, int(math.Round(height*aspect))))
+		cropHeight := min(bounds.Dy(), max(1, int(math.Round(float64(cropWidth)/aspect))))
+		area := image.Rect(0, 0, cropWidth, cropHeight).
+			Add(bounds.Min).
+			Add(image.Pt((bounds.Dx()-cropWidth)/2, (bounds.Dy()-cropHeight)/2))
+
+		if cropWidth <= resize.Width {
+			return area, cropWidth, cropHeight
+		}
+
+		return area, resize.Width, resize.Height
+	}
+
+	scale := min(float64(resize.Width)/width, float64(resize.Height)/height, 1)
+	return bounds, max(1, int(math.Round(width*scale))), max(1, int(math.Round(height*scale)))
+}
+
+// toRGBA copies the area of the image to a new RGBA image whose bounds start at
+// (0, 0). Pixels of RGBA images can be read directly, which is much faster than
+// calling At.
+func toRGBA(src image.Image, area image.Rectangle) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, area.Dx(), area.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, area.Min, draw.Src)
+	return dst
+}
+
+// rotate rotates the image clockwise by 90, 180 or 270 degrees.
+func rotate(src *image.RGBA, degrees int) *image.RGBA {
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, height, width))
+	if degrees == 180 {
+		dst = image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch degrees {
+			case 90:
+				dx, dy = height-1-y, x
+			case 180:
+				dx, dy = width-1-x, height-1-y
+			case 270:
+				dx, dy = y, width-1-x
+			}
+
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+
+	return dst
+}
+
+// resample scales the area of the source to the given size. Every destination
+// pixel is the average of the source pixels it covers, which is accurate when
+// scaling down.
+func resample(src *image.RGBA, area image.Rectangle, width int, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for dy := 0; dy < height; dy++ {
+		y0 := area.Min.Y + dy*area.Dy()/height
+		y1 := max(area.Min.Y+(dy+1)*area.Dy()/height, y0+1)
+
+		for dx := 0; dx < width; dx++ {
+			x0 := area.Min.X + dx*area.Dx()/width
+			x1 := max(area.Min.X+(dx+1)*area.Dx()/width, x0+1)
+
+			var r, g, b, a, n int
+			for y := y0; y < y1; y++ {
+				offset := src.PixOffset(x0, y)
+				for x := x0; x < x1; x++ {
+					r += int(src.Pix[offset])
+					g += int(src.Pix[offset+1])
+					b += int(src.Pix[offset+2])
+					a += int(src.Pix[offset+3])
+					offset += 4
+					n++
+				}
+			}
+
+			offset := dst.PixOffset(dx, dy)
+			dst.Pix[offset] = uint8(r / n)
+			dst.Pix[offset+1] = uint8(g / n)
+			dst.Pix[offset+2] = uint8(b / n)
+			dst.Pix[offset+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+func encode(img image.Image, format string, quality int) (*domain.EncodedImage, error) {
+	buf := bytes.Buffer{}
+
+	var err error
+	switch format {
+	case "jpeg":
+		if quality == 0 {
+			quality = jpegQuality
+		}
+
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		format = "png"
+		err = png.Encode(&buf, img)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.EncodedImage{
+		Content: buf.Bytes(),
+		Type:    "image/" + format,
+		Metadata: domain.ImageMetadata{
+			Format: format,
+			Width:  img.Bounds().Dx(),
+			Height: img.Bounds().Dy(),
+		},
+	}, nil
+}
@@ -0,0 +1,218 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image/gif"
+	"io"
+
+	"github.com/todennus/file-service/domain"
+)
+
+// maxImageHeaderSize bounds the prefix of the content where the header of an
+// image must be found. The JPEG header may follow large metadata segments.
+const maxImageHeaderSize = 1 << 20 // 1MiB
+
+var errUncheckedFormat = errors.New("the format can't be checked against the image limits")
+
+// CheckLimits checks the header, read from a bounded prefix of the content,
+// before decoding the pixels, and returns the metadata read from it. The frames
+// of a GIF image are decoded and discarded one by one, and the total number of
+// decoded pixels is checked before decoding each frame.
+//
+// The images without codec are rejected if there are limits, they could only
+// be checked by the decoders of the clients. SVG images are not rasterized,
+// they are sanitized instead.
+func (p *ImageProcessor) CheckLimits(content io.Reader, contentType string, limits domain.ImageLimits) (*domain.ImageMetadata, error) {
+	codec, ok := codecs[contentType]
+	if !ok {
+		if limits.IsZero() || contentType == domain.SVGContentType {
+			return nil, nil
+		}
+
+		return nil, errUncheckedFormat
+	}
+
+	reader := bufio.NewReaderSize(content, maxImageHeaderSize)
+	header, err := reader.Peek(maxImageHeaderSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	config, err := codec.decodeConfig(bytes.NewReader(header))
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) && len(header) == maxImageHeaderSize {
+			return nil, fmt.Errorf("not found the image header in the first %d bytes", maxImageHeaderSize)
+		}
+
+		return nil, err
+	}
+
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, errors.New("invalid image dimensions")
+	}
+
+	if err := limits.Check(config.Width, config.Height, 1); err != nil {
+		return nil, err
+	}
+
+	metadata := &domain.ImageMetadata{
+		Format: codec.format,
+		Width:  config.Width,
+		Height: config.Height,
+	}
+
+	// The declared dimensions are acceptable, so decoding the pixels is safe.
+	// The decoders reject frames outside of the declared dimensions.
+	if codec.format == "gif" {
+		if err := decodeGIFFrames(reader, config.Width, config.Height, limits); err != nil {
+			return nil, err
+		}
+
+		return metadata, nil
+	}
+
+	img, err := codec.decode(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if img.Bounds().Dx() != config.Width || img.Bounds().Dy() != config.Height {
+		return nil, errors.New("the image dimensions differ from its header")
+	}
+
+	return metadata, nil
+}
+
+// decodeGIFFrames decodes the frames of a GIF image one by one, so only a
+// single frame is held in memory. Every frame is counted with the size of the
+// logical screen, which bounds it, and the limits are checked before decoding
+// it.
+func decodeGIFFrames(reader *bufio.Reader, width, height int, limits domain.ImageLimits) error {
+	screen, err := readGIFScreen(reader)
+	if err != nil {
+		return err
+	}
+
+	frames := 0
+	frame := bytes.Buffer{}
+	for {
+		introducer, err := reader.ReadByte()
+		if err != nil {
+			return errTruncatedImage
+		}
+
+		switch introducer {
+		case 0x3B:
+			if frames == 0 {
+				return errors.New("the gif image has no frame")
+			}
+
+			return nil
+
+		case 0x21:
+			// The extensions don't change the decoded pixels, they are skipped.
+			if _, err := reader.ReadByte(); err != nil {
+				return errTruncatedImage
+			}
+
+			if err := copyGIFSubBlocks(io.Discard, reader); err != nil {
+				return err
+			}
+
+		case 0x2C:
+			frames++
+			if err := limits.Check(width, height, frames); err != nil {
+				return err
+			}
+
+			frame.Reset()
+			frame.Write(screen)
+			if err := readGIFFrame(&frame, reader); err != nil {
+				return err
+			}
+			frame.WriteByte(0x3B)
+
+			if _, err := gif.Decode(&frame); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("invalid gif block introducer 0x%02x", introducer)
+		}
+	}
+}
+
+// readGIFScreen returns the header, the logical screen descriptor and the
+// global color table, which prefix every frame decoded alone.
+func readGIFScreen(reader *bufio.Reader) ([]byte, error) {
+	screen := make([]byte, 13)
+	if _, err := io.ReadFull(reader, screen); err != nil {
+		return nil, errTruncatedImage
+	}
+
+	if !bytes.HasPrefix(screen, []byte("GIF8")) {
+		return nil, errors.New("invalid gif signature")
+	}
+
+	if screen[10]&0x80 != 0 {
+		colorTable := make([]byte, 3<<(screen[10]&0x07+1))
+		if _, err := io.ReadFull(reader, colorTable); err != nil {
+			return nil, errTruncatedImage
+		}
+
+		screen = append(screen, colorTable...)
+	}
+
+	return screen, nil
+}
+
+// readGIFFrame copies the image descriptor following its introducer, the
+// local color table and the image data.
+func readGIFFrame(w *bytes.Buffer, reader *bufio.Reader) error {
+	descriptor := make([]byte, 9)
+	if _, err := io.ReadFull(reader, descriptor); err != nil {
+		return errTruncatedImage
+	}
+
+	w.WriteByte(0x2C)
+	w.Write(descriptor)
+
+	// The image descriptor is followed by the optional local color table and
+	// the minimum code size of the image data.
+	size := 1
+	if descriptor[8]&0x80 != 0 {
+		size += 3 << (descriptor[8]&0x07 + 1)
+	}
+
+	if _, err := io.CopyN(w, reader, int64(size)); err != nil {
+		return errTruncatedImage
+	}
+
+	return copyGIFSubBlocks(w, reader)
+}
+
+// copyGIFSubBlocks copies the data sub-blocks until the block terminator,
+// which is copied too.
+func copyGIFSubBlocks(w io.Writer, reader *bufio.Reader) error {
+	for {
+		size, err := reader.ReadByte()
+		if err != nil {
+			return errTruncatedImage
+		}
+
+		if _, err := w.Write([]byte{size}); err != nil {
+			return err
+		}
+
+		if size == 0 {
+			return nil
+		}
+
+		if _, err := io.CopyN(w, reader, int64(size)); err != nil {
+			return errTruncatedImage
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+)
+
+var errTruncatedImage = errors.New("truncated image")
+
+// StripMetadata removes the metadata segments of the image without decoding
+// it, so the pixels are kept as they are. The image is only re-encoded if its
+// EXIF orientation is not the default one.
+func (p *ImageProcessor) StripMetadata(content io.Reader, contentType string) ([]byte, error) {
+	codec, ok := codecs[contentType]
+	if !ok {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var stripped []byte
+	orientation := 1
+	switch codec.format {
+	case "jpeg":
+		stripped, orientation, err = stripJPEG(data)
+	case "png":
+		stripped, orientation, err = stripPNG(data)
+	case "gif":
+		stripped, err = stripGIF(data)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if orientation == 1 {
+		return stripped, nil
+	}
+
+	src, err := codec.decode(bytes.NewReader(stripped))
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := encode(orient(toRGBA(src, src.Bounds()), orientation), codec.format, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return encoded.Content, nil
+}
+
+// stripJPEG keeps the segments which are needed to render the image: JFIF,
+// ICC profile, Adobe color transform and the coding segments. EXIF and XMP
+// (APP1), IPTC (APP13), comments and other application segments are removed.
+func stripJPEG(data []byte) ([]byte, int, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 0, errors.New("invalid jpeg signature")
+	}
+
+	out := bytes.Buffer{}
+	out.Write(data[:2])
+
+	orientation := 1
+	for i := 2; ; {
+		if i+1 >= len(data) || data[i] != 0xFF {
+			return nil, 0, errTruncatedImage
+		}
+
+		// Markers may be preceded by any number of fill bytes.
+		marker := data[i+1]
+		if marker == 0xFF {
+			i++
+			continue
+		}
+
+		switch {
+		case marker == 0xD9:
+			out.Write(data[i : i+2])
+			return out.Bytes(), orientation, nil
+		case marker == 0xDA:
+			// The entropy-coded data follows the start of scan, the rest of
+			// the image has no metadata.
+			out.Write(data[i:])
+			return out.Bytes(), orientation, nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			out.Write(data[i : i+2])
+			i += 2
+			continue
+		}
+
+		if i+4 > len(data) {
+			return nil, 0, errTruncatedImage
+		}
+
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) || end < i+4 {
+			return nil, 0, errTruncatedImage
+		}
+
+		segment, payload := data[i:end], data[i+4:end]
+		i = end
+
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")):
+			orientation = exifOrientation(payload[6:])
+			continue
+		case marker == 0xE0 && !bytes.HasPrefix(payload, []byte("JFIF\x00")),
+			marker == 0xE2 && !bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00")),
+			marker == 0xEE && !bytes.HasPrefix(payload, []byte("Adobe")),
+			marker == 0xE1, marker >= 0xE3 && marker <= 0xED, marker == 0xEF,
+			marker == 0xFE:
+			continue
+		}
+
+		out.Write(segment)
+	}
+}
+
+// pngMetadataChunks are the ancillary chunks which only carry metadata.
+var pngMetadataChunks = map[string]bool{
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true, // XMP is stored in an international text chunk.
+	"eXIf": true,
+	"tIME": true,
+}
+
+func stripPNG(data []byte) ([]byte, int, error) {
+	signature := []byte("\x89PNG\r\n\x1a\n")
+	if !bytes.HasPrefix(data, signature) {
+		return nil, 0, errors.New("invalid png signature")
+	}
+
+	out := bytes.Buffer{}
+	out.Write(signature)
+
+	orientation := 1
+	for i := len(signature); ; {
+		if i+8 > len(data) {
+			return nil, 0, errTruncatedImage
+		}
+
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		chunkType := string(data[i+4 : i+8])
+
+		// A chunk is its length, type, data and CRC.
+		end := i + 12 + length
+		if length < 0 || end > len(data) || end < i {
+			return nil, 0, errTruncatedImage
+		}
+
+		if chunkType == "eXIf" {
+			orientation = exifOrientation(data[i+8 : i+8+length])
+		}
+
+		if !pngMetadataChunks[chunkType] {
+			out.Write(data[i:end])
+		}
+
+		i = end
+		if chunkType == "IEND" {
+			return out.Bytes(), orientation, nil
+		}
+	}
+}
+
+// stripGIF removes comments and application extensions, except the NETSCAPE
+// one which makes the animation loop. XMP is stored in an application
+// extension.
+func stripGIF(data []byte) ([]byte, error) {
+	blocks, err := readGIFBlocks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	out := bytes.Buffer{}
+	for _, block := range blocks {
+		content := data[block.start:block.end]
+		if block.label == 0xFE {
+			continue
+		}
+
+		if block.label == 0xFF && !bytes.HasPrefix(content[3:], []byte("NETSCAPE2.0")) {
+			continue
+		}
+
+		out.Write(content)
+	}
+
+	return out.Bytes(), nil
+}
+
+// gifBlock is the position of a block in a GIF content. The label is only set
+// for extensions.
+type gifBlock struct {
+	introducer byte
+	label      byte
+	start      int
+	end        int
+}
+
+// readGIFBlocks splits the GIF content into blocks without decoding the image
+// data. The header, the logical screen descriptor and the global color table
+// form the first block.
+func readGIFBlocks(data []byte) ([]gifBlock, error) {
+	if len(data) < 13 || !bytes.HasPrefix(data, []byte("GIF8")) {
+		return nil, errors.New("invalid gif signature")
+	}
+
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+
+	if i > len(data) {
+		return nil, errTruncatedImage
+	}
+
+	blocks := []gifBlock{{start: 0, end: i}}
+	for i < len(data) {
+		block := gifBlock{introducer: data[i], start: i}
+
+		switch block.introducer {
+		case 0x3B:
+			block.end = i + 1
+			return append(blocks, block), nil
+		case 0x21:
+			// The application identifier is the first sub-block of an
+			// application extension, so it is at least 3 bytes after the
+			// introducer.
+			if i+3 > len(data) {
+				return nil, errTruncatedImage
+			}
+
+			block.label = data[i+1]
+			i += 2
+		case 0x2C:
+			if i+10 > len(data) {
+				return nil, errTruncatedImage
+			}
+
+			// The image descriptor is followed by the optional local color
+			// table and the minimum code size of the image data.
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			i++
+		default:
+			return nil, errors.New("invalid gif block")
+		}
+
+		end, err := skipGIFSubBlocks(data, i)
+		if err != nil {
+			return nil, err
+		}
+
+		block.end, i = end, end
+		blocks = append(blocks, block)
+	}
+
+	return nil, errTruncatedImage
+}
+
+// skipGIFSubBlocks returns the position after the data sub-blocks starting at
+// i, including the block terminator.
+func skipGIFSubBlocks(data []byte, i int) (int, error) {
+	for {
+		if i >= len(data) {
+			return 0, errTruncatedImage
+		}
+
+		size := int(data[i])
+		i += size + 1
+		if size == 0 {
+			return i, nil
+		}
+	}
+}
+
+// exifOrientation reads the orientation tag of the first IFD of the EXIF TIFF
+// structure. It returns 1, the default orientation, if the tag is missing or
+// invalid.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+
+		// The orientation is a single SHORT which is stored in the value
+		// field of the entry.
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+
+			return orientation
+		}
+	}
+
+	return 1
+}
+
+// orient transforms the image so that it is displayed correctly without its
+// EXIF orientation.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	switch orientation {
+	case 2:
+		return flip(img)
+	case 3:
+		return rotate(img, 180)
+	case 4:
+		return flip(rotate(img, 180))
+	case 5:
+		return flip(rotate(img, 90))
+	case 6:
+		return rotate(img, 90)
+	case 7:
+		return flip(rotate(img, 270))
+	case 8:
+		return rotate(img, 270)
+	}
+
+	return img
+}
+
+// flip mirrors the image horizontally.
+func flip(src *image.RGBA) *image.RGBA {
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			copy(dst.Pix[dst.PixOffset(width-1-x, y):dst.PixOffset(width-1-x, y)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+
+	return dst
+}
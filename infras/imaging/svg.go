@@ -0,0 +1,206 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// svgUnsafeElements are removed with their whole content, because they run
+// scripts or embed documents.
+var svgUnsafeElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"object":        true,
+	"embed":         true,
+	"handler":       true,
+	"listener":      true,
+}
+
+// svgAnimationElements can change the value of another attribute, they are
+// removed if they target an attribute which would be removed.
+var svgAnimationElements = map[string]bool{
+	"set":              true,
+	"animate":          true,
+	"animatecolor":     true,
+	"animatemotion":    true,
+	"animatetransform": true,
+}
+
+// svgEscaper escapes the text and the attribute values. Unlike xml.EscapeText,
+// it keeps the line breaks.
+var svgEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// cssURLPattern matches the references of CSS, in style elements and in
+// attributes like fill or filter.
+var cssURLPattern = regexp.MustCompile(`(?i)url\s*\(\s*['"]?\s*([^'")\s]*)`)
+
+// embeddedImagePattern matches the data URLs of raster images, which cannot
+// carry scripts.
+var embeddedImagePattern = regexp.MustCompile(`^data:image/(png|jpeg|gif|webp);base64,`)
+
+// SanitizeSVG parses the SVG document and writes it again without scripts,
+// event handlers, foreign objects, processing instructions and external
+// references. Documents declaring entities are rejected.
+func (p *ImageProcessor) SanitizeSVG(content io.Reader) ([]byte, error) {
+	decoder := xml.NewDecoder(content)
+	decoder.Strict = true
+
+	out := bytes.Buffer{}
+	out.WriteString(xml.Header)
+
+	// The names of the open elements, to check that end elements match them.
+	// RawToken keeps the namespace prefixes but doesn't check it.
+	stack := []xml.Name{}
+
+	// skipDepth is the depth of the removed element whose content is being
+	// skipped, or zero.
+	skipDepth := 0
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 && (out.Len() > len(xml.Header) || strings.ToLower(token.Name.Local) != "svg") {
+				return nil, errors.New("the root element is not svg")
+			}
+
+			stack = append(stack, token.Name)
+			if skipDepth > 0 || !isSafeSVGElement(token) {
+				if skipDepth == 0 {
+					skipDepth = len(stack)
+				}
+
+				continue
+			}
+
+			writeSVGStartElement(&out, token)
+
+		case xml.EndElement:
+			if len(stack) == 0 || stack[len(stack)-1] != token.Name {
+				return nil, fmt.Errorf("unexpected end element %s", token.Name.Local)
+			}
+
+			if skipDepth == 0 {
+				out.WriteString("</" + qualifiedName(token.Name) + ">")
+			}
+
+			if skipDepth == len(stack) {
+				skipDepth = 0
+			}
+
+			stack = stack[:len(stack)-1]
+
+		case xml.CharData:
+			if skipDepth > 0 || len(stack) == 0 {
+				continue
+			}
+
+			// Style elements are the only ones whose text is interpreted.
+			if strings.ToLower(stack[len(stack)-1].Local) == "style" && hasExternalReference(string(token)) {
+				continue
+			}
+
+			out.WriteString(svgEscaper.Replace(string(token)))
+
+		case xml.Directive:
+			if bytes.Contains(bytes.ToUpper(token), []byte("<!ENTITY")) || bytes.Contains(token, []byte("[")) {
+				return nil, errors.New("entity declarations are not allowed")
+			}
+
+		case xml.Comment, xml.ProcInst:
+			// Comments are useless, and processing instructions may load
+			// external stylesheets.
+		}
+	}
+
+	if len(stack) > 0 || out.Len() == len(xml.Header) {
+		return nil, errors.New("incomplete svg document")
+	}
+
+	return out.Bytes(), nil
+}
+
+func isSafeSVGElement(element xml.StartElement) bool {
+	name := strings.ToLower(element.Name.Local)
+	if svgUnsafeElements[name] {
+		return false
+	}
+
+	if svgAnimationElements[name] {
+		for _, attr := range element.Attr {
+			if strings.ToLower(attr.Name.Local) == "attributename" {
+				target := strings.ToLower(attr.Value)
+				if strings.HasPrefix(target, "on") || strings.HasSuffix(target, "href") {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+func writeSVGStartElement(out *bytes.Buffer, element xml.StartElement) {
+	out.WriteString("<" + qualifiedName(element.Name))
+	for _, attr := range element.Attr {
+		if !isSafeSVGAttr(attr) {
+			continue
+		}
+
+		out.WriteString(" " + qualifiedName(attr.Name) + `="` + svgEscaper.Replace(attr.Value) + `"`)
+	}
+	out.WriteString(">")
+}
+
+func isSafeSVGAttr(attr xml.Attr) bool {
+	name := strings.ToLower(attr.Name.Local)
+	if attr.Name.Space != "xmlns" && strings.HasPrefix(name, "on") {
+		return false
+	}
+
+	// Only references to the elements of the document and embedded raster
+	// images are kept.
+	if name == "href" || name == "src" {
+		value := strings.TrimSpace(attr.Value)
+		return strings.HasPrefix(value, "#") || embeddedImagePattern.MatchString(value)
+	}
+
+	return !hasExternalReference(attr.Value)
+}
+
+// hasExternalReference returns true if the CSS imports a stylesheet or refers
+// to something outside of the document.
+func hasExternalReference(css string) bool {
+	if strings.Contains(strings.ToLower(css), "@import") {
+		return true
+	}
+
+	for _, match := range cssURLPattern.FindAllStringSubmatch(css, -1) {
+		if !strings.HasPrefix(match[1], "#") && !embeddedImagePattern.MatchString(match[1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}
@@ -0,0 +1,104 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamavChunkSize is the size of the chunks streamed to clamd. It must be lower
+// than the StreamMaxLength of clamd.
+const clamavChunkSize = 64 << 10 // 64KiB
+
+// ClamAVScanner sends the content to a clamd daemon with the INSTREAM command.
+type ClamAVScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamAVScanner connects to clamd at address, which is either host:port or
+// unix:/path/to/clamd.sock. The timeout covers a whole scan.
+func NewClamAVScanner(address string, timeout time.Duration) *ClamAVScanner {
+	network := "tcp"
+	if socket, found := strings.CutPrefix(address, "unix:"); found {
+		network, address = "unix", socket
+	}
+
+	return &ClamAVScanner{network: network, address: address, timeout: timeout}
+}
+
+func (s *ClamAVScanner) Scan(ctx context.Context, content io.Reader) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return "", err
+	}
+
+	// Every chunk is prefixed by its length, a zero length ends the stream.
+	buffer := make([]byte, 4+clamavChunkSize)
+	for {
+		n, err := io.ReadFull(content, buffer[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buffer, uint32(n))
+			if _, err := conn.Write(buffer[:4+n]); err != nil {
+				return "", err
+			}
+		}
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return "", err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	return parseClamAVReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamAVReply reads "stream: OK", "stream: <threat> FOUND" or
+// "<reason> ERROR".
+func parseClamAVReply(reply string) (string, error) {
+	result := strings.TrimPrefix(reply, "stream: ")
+
+	if result == "OK" {
+		return "", nil
+	}
+
+	if threat, found := strings.CutSuffix(result, " FOUND"); found {
+		return threat, nil
+	}
+
+	return "", fmt.Errorf("unexpected clamd reply %q", reply)
+}
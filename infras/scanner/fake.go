@@ -0,0 +1,32 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// eicarSignature is the standard antivirus test file, which every scanner
+// detects as a threat.
+const eicarSignature = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!H+H*`
+
+// FakeScanner detects the EICAR test file in the content. It is meant for
+// development and testing, where no clamd daemon is running.
+type FakeScanner struct{}
+
+func NewFakeScanner() *FakeScanner {
+	return &FakeScanner{}
+}
+
+func (s *FakeScanner) Scan(ctx context.Context, content io.Reader) (string, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+
+	if bytes.Contains(data, []byte(eicarSignature)) {
+		return "Eicar-Test-Signature", nil
+	}
+
+	return "", nil
+}
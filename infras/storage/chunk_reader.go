@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+var _ io.ReadSeekCloser = (*chunkReader)(nil)
+
+// chunkReader reads several objects as if they were a single one.
+type chunkReader struct {
+	objects []io.ReadSeekCloser
+	sizes   []int64
+
+	// current is the index of the object being read.
+	current int
+	offset  int64
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for r.current < len(r.objects) {
+		n, err := r.objects[r.current].Read(p)
+		r.offset += int64(n)
+
+		if errors.Is(err, io.EOF) {
+			r.current++
+			if n == 0 {
+				continue
+			}
+
+			err = nil
+		}
+
+		return n, err
+	}
+
+	return 0, io.EOF
+}
+
+func (r *chunkReader) Seek(offset int64, whence int) (int64, error) {
+	var total int64
+	for i := range r.sizes {
+		total += r.sizes[i]
+	}
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += total
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	// Position the object containing the offset, and rewind all objects behind
+	// it so they are read from the beginning later.
+	r.current = len(r.objects)
+	start := int64(0)
+	for i := range r.objects {
+		position := int64(0)
+		if r.current == len(r.objects) && offset < start+r.sizes[i] {
+			r.current = i
+			position = offset - start
+		}
+
+		if i >= r.current {
+			if _, err := r.objects[i].Seek(position, io.SeekStart); err != nil {
+				return 0, err
+			}
+		}
+
+		start += r.sizes[i]
+	}
+
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *chunkReader) Close() error {
+	var errs []error
+	for i := range r.objects {
+		errs = append(errs, r.objects[i].Close())
+	}
+
+	return errors.Join(errs...)
+}
@@ -10,17 +10,34 @@ import (
 
 	"github.com/minio/minio-go/v7"
 	"github.com/todennus/file-service/domain"
+	"github.com/todennus/shared/errordef"
 )
 
+// streamPartSize is the size of the parts when a content of unknown size is
+// uploaded to MinIO.
+const streamPartSize = 16 << 20 // 16MiB
+
 type FileStorageRepository struct {
 	minioClient *minio.Client
+
+	// temporaryBucket is where the uploaded content is kept until it is checked.
+	// Like the bucket of file metadata, it may contain a folder path.
+	temporaryBucket string
+
+	// cacheBucket is where the results of image transformations are kept. It
+	// may contain a folder path too.
+	cacheBucket string
 }
 
 func NewFileStorageRepository(
 	minioClient *minio.Client,
+	temporaryBucket string,
+	cacheBucket string,
 ) *FileStorageRepository {
 	return &FileStorageRepository{
-		minioClient: minioClient,
+		minioClient:     minioClient,
+		temporaryBucket: temporaryBucket,
+		cacheBucket:     cacheBucket,
 	}
 }
 
@@ -29,12 +46,64 @@ func (repo *FileStorageRepository) Presign(
 	file *domain.FileInfo,
 	expiration time.Duration,
 ) (string, error) {
-	bucket, filepath, found := strings.Cut(file.Metadata.Bucket, "/")
-	filename := file.ID
-	if found {
-		filename = path.Join(filepath, filename)
+	bucket, filename := objectLocation(file)
+
+	url, err := repo.minioClient.PresignedGetObject(ctx, bucket, filename, expiration, url.Values{})
+	if err != nil {
+		return "", err
+	}
+
+	return url.String(), nil
+}
+
+// Open returns the file content. The content is not fetched until it is read
+// or seeked.
+func (repo *FileStorageRepository) Open(ctx context.Context, file *domain.FileInfo) (io.ReadSeekCloser, error) {
+	bucket, filename := objectLocation(file)
+
+	object, err := repo.minioClient.GetObject(ctx, bucket, filename, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, convertMinioError(err)
+	}
+
+	return object, nil
+}
+
+// Delete removes the file content from the storage. Deleting a non-existing
+// object is not an error.
+func (repo *FileStorageRepository) Delete(ctx context.Context, file *domain.FileInfo) error {
+	bucket, filename := objectLocation(file)
+	return repo.minioClient.RemoveObject(ctx, bucket, filename, minio.RemoveObjectOptions{})
+}
+
+// Move moves the content of a file to the location of another version of its
+// info, e.g. after the bucket of the file has been changed.
+func (repo *FileStorageRepository) Move(ctx context.Context, from *domain.FileInfo, to *domain.FileInfo) error {
+	srcBucket, srcFilename := objectLocation(from)
+	dstBucket, dstFilename := objectLocation(to)
+
+	if err := repo.copyObject(ctx, srcBucket, srcFilename, dstBucket, dstFilename, to.Metadata.Type); err != nil {
+		return err
 	}
 
+	return repo.minioClient.RemoveObject(ctx, srcBucket, srcFilename, minio.RemoveObjectOptions{})
+}
+
+// Copy copies the content of a file to the location of another version of its
+// info, the original content is kept.
+func (repo *FileStorageRepository) Copy(ctx context.Context, from *domain.FileInfo, to *domain.FileInfo) error {
+	srcBucket, srcFilename := objectLocation(from)
+	dstBucket, dstFilename := objectLocation(to)
+	return repo.copyObject(ctx, srcBucket, srcFilename, dstBucket, dstFilename, to.Metadata.Type)
+}
+
+func (repo *FileStorageRepository) PresignVariant(
+	ctx context.Context,
+	variant *domain.FileVariant,
+	expiration time.Duration,
+) (string, error) {
+	bucket, filename := variantLocation(variant)
+
 	url, err := repo.minioClient.PresignedGetObject(ctx, bucket, filename, expiration, url.Values{})
 	if err != nil {
 		return "", err
@@ -43,23 +112,347 @@ func (repo *FileStorageRepository) Presign(
 	return url.String(), nil
 }
 
-func (repo *FileStorageRepository) Store(
+func (repo *FileStorageRepository) StoreVariant(
 	ctx context.Context,
-	file *domain.FileInfo,
+	variant *domain.FileVariant,
 	content io.Reader,
 ) error {
-	bucket, filepath, found := strings.Cut(file.Metadata.Bucket, "/")
-	filename := file.ID
-	if found {
-		filename = path.Join(filepath, filename)
+	bucket, filename := variantLocation(variant)
+
+	options := minio.PutObjectOptions{ContentType: variant.Type}
+	if _, err := repo.minioClient.PutObject(ctx, bucket, filename, content, int64(variant.Size), options); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *FileStorageRepository) OpenVariant(
+	ctx context.Context,
+	variant *domain.FileVariant,
+) (io.ReadSeekCloser, error) {
+	bucket, filename := variantLocation(variant)
+
+	object, err := repo.minioClient.GetObject(ctx, bucket, filename, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, convertMinioError(err)
+	}
+
+	return object, nil
+}
+
+func (repo *FileStorageRepository) DeleteVariant(ctx context.Context, variant *domain.FileVariant) error {
+	bucket, filename := variantLocation(variant)
+	return repo.minioClient.RemoveObject(ctx, bucket, filename, minio.RemoveObjectOptions{})
+}
+
+// CopyVariant copies the content of a variant to the location of another
+// version of its info, the original content is kept.
+func (repo *FileStorageRepository) CopyVariant(ctx context.Context, from *domain.FileVariant, to *domain.FileVariant) error {
+	srcBucket, srcFilename := variantLocation(from)
+	dstBucket, dstFilename := variantLocation(to)
+	return repo.copyObject(ctx, srcBucket, srcFilename, dstBucket, dstFilename, to.Type)
+}
+
+// StoreChunk stores a chunk of a resumable upload and returns its size. If the
+// size is unknown, pass -1.
+func (repo *FileStorageRepository) StoreChunk(
+	ctx context.Context,
+	name string,
+	content io.Reader,
+	size int64,
+) (int64, error) {
+	return repo.Stage(ctx, name, content, size)
+}
+
+// OpenChunks returns the concatenated content of the chunks.
+func (repo *FileStorageRepository) OpenChunks(
+	ctx context.Context,
+	chunks []domain.ResumableUploadChunk,
+) (io.ReadSeekCloser, error) {
+	reader := &chunkReader{
+		objects: make([]io.ReadSeekCloser, 0, len(chunks)),
+		sizes:   make([]int64, 0, len(chunks)),
+	}
+
+	for i := range chunks {
+		bucket, filename := repo.temporaryLocation(chunks[i].Name)
+		object, err := repo.minioClient.GetObject(ctx, bucket, filename, minio.GetObjectOptions{})
+		if err != nil {
+			reader.Close()
+			return nil, err
+		}
+
+		reader.objects = append(reader.objects, object)
+		reader.sizes = append(reader.sizes, chunks[i].Size)
+	}
+
+	return reader, nil
+}
+
+func (repo *FileStorageRepository) DeleteChunks(ctx context.Context, chunks []domain.ResumableUploadChunk) error {
+	for i := range chunks {
+		bucket, filename := repo.temporaryLocation(chunks[i].Name)
+		if err := repo.minioClient.RemoveObject(ctx, bucket, filename, minio.RemoveObjectOptions{}); err != nil {
+			return err
+		}
 	}
 
-	size := int64(file.Metadata.Size)
-	options := minio.PutObjectOptions{ContentType: file.Metadata.Type}
+	return nil
+}
+
+// DeleteExpiredTemporaryObjects deletes all temporary objects which were stored
+// before the given time and returns the number of deleted objects.
+func (repo *FileStorageRepository) DeleteExpiredTemporaryObjects(ctx context.Context, before time.Time) (int, error) {
+	bucket, prefix := repo.temporaryLocation("")
+	return repo.deleteObjectsBefore(ctx, bucket, prefix, before)
+}
+
+func (repo *FileStorageRepository) deleteObjectsBefore(
+	ctx context.Context,
+	bucket string,
+	prefix string,
+	before time.Time,
+) (int, error) {
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	// Stop listing objects when returning early.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	deleted := 0
+	options := minio.ListObjectsOptions{Prefix: prefix, Recursive: true}
+	for object := range repo.minioClient.ListObjects(ctx, bucket, options) {
+		if object.Err != nil {
+			return deleted, object.Err
+		}
 
+		if !object.LastModified.Before(before) {
+			continue
+		}
+
+		if err := repo.minioClient.RemoveObject(ctx, bucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			return deleted, err
+		}
+
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// Stage stores the content at a staging key and returns its size. If the size
+// is unknown, pass -1, the content is then uploaded in parts without being
+// buffered entirely.
+func (repo *FileStorageRepository) Stage(
+	ctx context.Context,
+	key string,
+	content io.Reader,
+	size int64,
+) (int64, error) {
+	bucket, filename := repo.temporaryLocation(key)
+
+	options := minio.PutObjectOptions{}
+	if size < 0 {
+		// Without a part size, MinIO buffers parts large enough for the maximum
+		// object size (5TiB), that is about 512MiB for each upload.
+		options.PartSize = streamPartSize
+	}
+
+	info, err := repo.minioClient.PutObject(ctx, bucket, filename, content, size, options)
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size, nil
+}
+
+// PresignStagingUpload allows the client to upload the file of the policy to its
+// staging key until the policy expires.
+func (repo *FileStorageRepository) PresignStagingUpload(
+	ctx context.Context,
+	policy *domain.UploadPolicy,
+) (*domain.PresignedUpload, error) {
+	bucket, filename := repo.temporaryLocation(policy.StagingKey)
+	expiration := time.Until(policy.ExpiresAt)
+
+	putURL, err := repo.minioClient.PresignedPutObject(ctx, bucket, filename, expiration)
+	if err != nil {
+		return nil, err
+	}
+
+	postPolicy := minio.NewPostPolicy()
+	if err := postPolicy.SetBucket(bucket); err != nil {
+		return nil, err
+	}
+
+	if err := postPolicy.SetKey(filename); err != nil {
+		return nil, err
+	}
+
+	if err := postPolicy.SetExpires(policy.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	if err := postPolicy.SetContentLengthRange(1, policy.MaxSize); err != nil {
+		return nil, err
+	}
+
+	postURL, formData, err := repo.minioClient.PresignedPostPolicy(ctx, postPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.PresignedUpload{
+		PutURL:       putURL.String(),
+		PostURL:      postURL.String(),
+		PostFormData: formData,
+	}, nil
+}
+
+// OpenStaged returns the content and the size of a staged object. It returns
+// ErrNotFound if nothing has been uploaded to the key.
+func (repo *FileStorageRepository) OpenStaged(ctx context.Context, key string) (io.ReadSeekCloser, int64, error) {
+	bucket, filename := repo.temporaryLocation(key)
+
+	object, err := repo.minioClient.GetObject(ctx, bucket, filename, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, 0, convertMinioError(err)
+	}
+
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, 0, convertMinioError(err)
+	}
+
+	return object, info.Size, nil
+}
+
+// Promote moves a staged object to the location of the file.
+func (repo *FileStorageRepository) Promote(ctx context.Context, key string, file *domain.FileInfo) error {
+	srcBucket, srcFilename := repo.temporaryLocation(key)
+	dstBucket, dstFilename := objectLocation(file)
+
+	if err := repo.copyObject(ctx, srcBucket, srcFilename, dstBucket, dstFilename, file.Metadata.Type); err != nil {
+		return err
+	}
+
+	return repo.DeleteStaged(ctx, key)
+}
+
+func (repo *FileStorageRepository) DeleteStaged(ctx context.Context, key string) error {
+	bucket, filename := repo.temporaryLocation(key)
+	return repo.minioClient.RemoveObject(ctx, bucket, filename, minio.RemoveObjectOptions{})
+}
+
+// StoreCached keeps the content in the cache under the key.
+func (repo *FileStorageRepository) StoreCached(
+	ctx context.Context,
+	key string,
+	content io.Reader,
+	size int64,
+	contentType string,
+) error {
+	bucket, filename := repo.cacheLocation(key)
+
+	options := minio.PutObjectOptions{ContentType: contentType}
 	if _, err := repo.minioClient.PutObject(ctx, bucket, filename, content, size, options); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// OpenCached returns the cached content and its size. It returns ErrNotFound if
+// nothing is cached under the key.
+func (repo *FileStorageRepository) OpenCached(ctx context.Context, key string) (io.ReadSeekCloser, int64, error) {
+	bucket, filename := repo.cacheLocation(key)
+
+	object, err := repo.minioClient.GetObject(ctx, bucket, filename, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, 0, convertMinioError(err)
+	}
+
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, 0, convertMinioError(err)
+	}
+
+	return object, info.Size, nil
+}
+
+// DeleteExpiredCachedObjects deletes all cached objects which were stored
+// before the given time and returns the number of deleted objects.
+func (repo *FileStorageRepository) DeleteExpiredCachedObjects(ctx context.Context, before time.Time) (int, error) {
+	bucket, prefix := repo.cacheLocation("")
+	return repo.deleteObjectsBefore(ctx, bucket, prefix, before)
+}
+
+// copyObject copies an object on the server side. A single copy is limited to
+// 5GiB, ComposeObject copies larger objects by parts. The parts don't keep the
+// metadata of the source, so the content type is set again.
+func (repo *FileStorageRepository) copyObject(
+	ctx context.Context,
+	srcBucket, srcFilename string,
+	dstBucket, dstFilename string,
+	contentType string,
+) error {
+	src := minio.CopySrcOptions{Bucket: srcBucket, Object: srcFilename}
+	dst := minio.CopyDestOptions{
+		Bucket:          dstBucket,
+		Object:          dstFilename,
+		UserMetadata:    map[string]string{"Content-Type": contentType},
+		ReplaceMetadata: true,
+	}
+
+	if _, err := repo.minioClient.ComposeObject(ctx, dst, src); err != nil {
+		return convertMinioError(err)
+	}
+
+	return nil
+}
+
+func (repo *FileStorageRepository) temporaryLocation(name string) (string, string) {
+	bucket, folder, _ := strings.Cut(repo.temporaryBucket, "/")
+	return bucket, path.Join(folder, name)
+}
+
+func (repo *FileStorageRepository) cacheLocation(name string) (string, string) {
+	bucket, folder, _ := strings.Cut(repo.cacheBucket, "/")
+	return bucket, path.Join(folder, name)
+}
+
+// objectLocation returns the bucket and the object name of the file. The bucket
+// of file metadata may contain a folder path (e.g. images/avatar), in this
+// case, the folder is prepended to the object name.
+func objectLocation(file *domain.FileInfo) (string, string) {
+	return location(file.Metadata.Bucket, file.ID)
+}
+
+// variantLocation derives the location of the variant from the one of its
+// original file, so both are stored next to each other.
+func variantLocation(variant *domain.FileVariant) (string, string) {
+	return location(variant.Bucket, variant.FileID+"."+variant.Name)
+}
+
+func location(bucketPath string, name string) (string, string) {
+	bucket, filepath, found := strings.Cut(bucketPath, "/")
+	filename := name
+	if found {
+		filename = path.Join(filepath, filename)
+	}
+
+	return bucket, filename
+}
+
+func convertMinioError(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return errordef.ErrNotFound
+	}
+
+	return err
+}
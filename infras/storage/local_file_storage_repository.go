@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/todennus/shared/errordef"
+)
+
+// LocalFileStorageRepository is the FileStorageRepository of the local storage.
+type LocalFileStorageRepository struct {
+	localStorage *LocalStorage
+
+	// temporaryBucket is where the uploaded content is kept until it is checked.
+	// Like the bucket of file metadata, it may contain a folder path.
+	temporaryBucket string
+
+	// cacheBucket is where the results of image transformations are kept.
+	cacheBucket string
+}
+
+func NewLocalFileStorageRepository(
+	localStorage *LocalStorage,
+	temporaryBucket string,
+	cacheBucket string,
+) *LocalFileStorageRepository {
+	return &LocalFileStorageRepository{
+		localStorage:    localStorage,
+		temporaryBucket: temporaryBucket,
+		cacheBucket:     cacheBucket,
+	}
+}
+
+func (repo *LocalFileStorageRepository) Presign(
+	ctx context.Context,
+	file *domain.FileInfo,
+	expiration time.Duration,
+) (string, error) {
+	query := url.Values{}
+	query.Set("type", file.Metadata.Type)
+
+	return repo.localStorage.Presign("GET", localObject(file), time.Now().Add(expiration), query), nil
+}
+
+func (repo *LocalFileStorageRepository) Open(ctx context.Context, file *domain.FileInfo) (io.ReadSeekCloser, error) {
+	f, err := repo.localStorage.Open(localObject(file))
+	if err != nil {
+		return nil, convertLocalError(err)
+	}
+
+	return f, nil
+}
+
+func (repo *LocalFileStorageRepository) Delete(ctx context.Context, file *domain.FileInfo) error {
+	return repo.localStorage.Remove(localObject(file))
+}
+
+func (repo *LocalFileStorageRepository) Move(ctx context.Context, from *domain.FileInfo, to *domain.FileInfo) error {
+	return convertLocalError(repo.localStorage.Rename(localObject(from), localObject(to)))
+}
+
+func (repo *LocalFileStorageRepository) Copy(ctx context.Context, from *domain.FileInfo, to *domain.FileInfo) error {
+	return repo.copyObject(localObject(from), localObject(to))
+}
+
+func (repo *LocalFileStorageRepository) PresignVariant(
+	ctx context.Context,
+	variant *domain.FileVariant,
+	expiration time.Duration,
+) (string, error) {
+	query := url.Values{}
+	query.Set("type", variant.Type)
+
+	return repo.localStorage.Presign("GET", localVariantObject(variant), time.Now().Add(expiration), query), nil
+}
+
+func (repo *LocalFileStorageRepository) StoreVariant(
+	ctx context.Context,
+	variant *domain.FileVariant,
+	content io.Reader,
+) error {
+	_, err := repo.localStorage.Write(localVariantObject(variant), content)
+	return err
+}
+
+func (repo *LocalFileStorageRepository) OpenVariant(
+	ctx context.Context,
+	variant *domain.FileVariant,
+) (io.ReadSeekCloser, error) {
+	f, err := repo.localStorage.Open(localVariantObject(variant))
+	if err != nil {
+		return nil, convertLocalError(err)
+	}
+
+	return f, nil
+}
+
+func (repo *LocalFileStorageRepository) DeleteVariant(ctx context.Context, variant *domain.FileVariant) error {
+	return repo.localStorage.Remove(localVariantObject(variant))
+}
+
+func (repo *LocalFileStorageRepository) CopyVariant(
+	ctx context.Context,
+	from *domain.FileVariant,
+	to *domain.FileVariant,
+) error {
+	return repo.copyObject(localVariantObject(from), localVariantObject(to))
+}
+
+func (repo *LocalFileStorageRepository) StoreChunk(
+	ctx context.Context,
+	name string,
+	content io.Reader,
+	size int64,
+) (int64, error) {
+	return repo.Stage(ctx, name, content, size)
+}
+
+func (repo *LocalFileStorageRepository) OpenChunks(
+	ctx context.Context,
+	chunks []domain.ResumableUploadChunk,
+) (io.ReadSeekCloser, error) {
+	reader := &chunkReader{
+		objects: make([]io.ReadSeekCloser, 0, len(chunks)),
+		sizes:   make([]int64, 0, len(chunks)),
+	}
+
+	for i := range chunks {
+		f, err := repo.localStorage.Open(repo.temporaryObject(chunks[i].Name))
+		if err != nil {
+			reader.Close()
+			return nil, convertLocalError(err)
+		}
+
+		reader.objects = append(reader.objects, f)
+		reader.sizes = append(reader.sizes, chunks[i].Size)
+	}
+
+	return reader, nil
+}
+
+func (repo *LocalFileStorageRepository) DeleteChunks(ctx context.Context, chunks []domain.ResumableUploadChunk) error {
+	for i := range chunks {
+		if err := repo.localStorage.Remove(repo.temporaryObject(chunks[i].Name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (repo *LocalFileStorageRepository) DeleteExpiredTemporaryObjects(
+	ctx context.Context,
+	before time.Time,
+) (int, error) {
+	return repo.localStorage.RemoveOlderThan(repo.temporaryObject(""), before)
+}
+
+func (repo *LocalFileStorageRepository) Stage(
+	ctx context.Context,
+	key string,
+	content io.Reader,
+	size int64,
+) (int64, error) {
+	return repo.localStorage.Write(repo.temporaryObject(key), content)
+}
+
+// PresignStagingUpload only supports the PUT URL, the local storage does not
+// implement the browser form upload.
+func (repo *LocalFileStorageRepository) PresignStagingUpload(
+	ctx context.Context,
+	policy *domain.UploadPolicy,
+) (*domain.PresignedUpload, error) {
+	query := url.Values{}
+	query.Set("max_size", strconv.FormatInt(policy.MaxSize, 10))
+
+	object := repo.temporaryObject(policy.StagingKey)
+	return &domain.PresignedUpload{
+		PutURL: repo.localStorage.Presign("PUT", object, policy.ExpiresAt, query),
+	}, nil
+}
+
+func (repo *LocalFileStorageRepository) OpenStaged(
+	ctx context.Context,
+	key string,
+) (io.ReadSeekCloser, int64, error) {
+	f, err := repo.localStorage.Open(repo.temporaryObject(key))
+	if err != nil {
+		return nil, 0, convertLocalError(err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, info.Size(), nil
+}
+
+func (repo *LocalFileStorageRepository) Promote(ctx context.Context, key string, file *domain.FileInfo) error {
+	return convertLocalError(repo.localStorage.Rename(repo.temporaryObject(key), localObject(file)))
+}
+
+func (repo *LocalFileStorageRepository) DeleteStaged(ctx context.Context, key string) error {
+	return repo.localStorage.Remove(repo.temporaryObject(key))
+}
+
+func (repo *LocalFileStorageRepository) StoreCached(
+	ctx context.Context,
+	key string,
+	content io.Reader,
+	size int64,
+	contentType string,
+) error {
+	_, err := repo.localStorage.Write(repo.cacheObject(key), content)
+	return err
+}
+
+func (repo *LocalFileStorageRepository) OpenCached(ctx context.Context, key string) (io.ReadSeekCloser, int64, error) {
+	f, err := repo.localStorage.Open(repo.cacheObject(key))
+	if err != nil {
+		return nil, 0, convertLocalError(err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, info.Size(), nil
+}
+
+func (repo *LocalFileStorageRepository) DeleteExpiredCachedObjects(ctx context.Context, before time.Time) (int, error) {
+	return repo.localStorage.RemoveOlderThan(repo.cacheObject(""), before)
+}
+
+func (repo *LocalFileStorageRepository) cacheObject(name string) string {
+	return path.Join(repo.cacheBucket, name)
+}
+
+func (repo *LocalFileStorageRepository) temporaryObject(name string) string {
+	return path.Join(repo.temporaryBucket, name)
+}
+
+func (repo *LocalFileStorageRepository) copyObject(src string, dst string) error {
+	f, err := repo.localStorage.Open(src)
+	if err != nil {
+		return convertLocalError(err)
+	}
+	defer f.Close()
+
+	_, err = repo.localStorage.Write(dst, f)
+	return err
+}
+
+// localObject returns the object of the file, at the same location as in MinIO.
+func localObject(file *domain.FileInfo) string {
+	bucket, filename := objectLocation(file)
+	return path.Join(bucket, filename)
+}
+
+func localVariantObject(variant *domain.FileVariant) string {
+	bucket, filename := variantLocation(variant)
+	return path.Join(bucket, filename)
+}
+
+func convertLocalError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return errordef.ErrNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/todennus/file-service/domain"
+)
+
+var _ http.Handler = (*LocalStorage)(nil)
+
+// LocalStorage keeps the objects as files under a root directory, with the same
+// bucket/object layout as MinIO. It is meant for development and CI, where a
+// MinIO server is not worth running.
+//
+// The presigned URLs point to LocalStorage itself, so it must be mounted by the
+// REST server at the path of its base URL.
+type LocalStorage struct {
+	root    string
+	baseURL *url.URL
+	secret  []byte
+}
+
+func NewLocalStorage(root string, baseURL string, secret string) (*LocalStorage, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	if secret == "" {
+		return nil, errors.New("require a secret to sign the urls of the local storage")
+	}
+
+	return &LocalStorage{root: root, baseURL: u, secret: []byte(secret)}, nil
+}
+
+// MountPath is the path where the REST server must serve the presigned URLs.
+func (s *LocalStorage) MountPath() string {
+	if s.baseURL.Path == "" {
+		return "/"
+	}
+
+	return s.baseURL.Path
+}
+
+// Presign returns an URL allowing the given method on the object until
+// expiresAt. All query values are covered by the signature.
+func (s *LocalStorage) Presign(method string, object string, expiresAt time.Time, query url.Values) string {
+	object = cleanObject(object)
+
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", s.sign(method, object, query))
+
+	u := *s.baseURL
+	u.Path = path.Join(u.Path, object)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// Write atomically replaces the object with the content and returns the number
+// of written bytes.
+func (s *LocalStorage) Write(object string, content io.Reader) (int64, error) {
+	name := s.filePath(object)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, content)
+	if err != nil {
+		tmp.Close()
+		return 0, err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+func (s *LocalStorage) Open(object string) (*os.File, error) {
+	return os.Open(s.filePath(object))
+}
+
+func (s *LocalStorage) Stat(object string) (fs.FileInfo, error) {
+	return os.Stat(s.filePath(object))
+}
+
+func (s *LocalStorage) Rename(src string, dst string) error {
+	name := s.filePath(dst)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	return os.Rename(s.filePath(src), name)
+}
+
+// Remove deletes the object. Removing a non-existing object is not an error.
+func (s *LocalStorage) Remove(object string) error {
+	if err := os.Remove(s.filePath(object)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveOlderThan deletes all objects under the prefix which were modified
+// before the given time and returns the number of deleted objects.
+func (s *LocalStorage) RemoveOlderThan(prefix string, before time.Time) (int, error) {
+	deleted := 0
+	err := filepath.WalkDir(s.filePath(prefix), func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		if !info.ModTime().Before(before) {
+			return nil
+		}
+
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		deleted++
+		return nil
+	})
+
+	return deleted, err
+}
+
+// ServeHTTP serves the presigned URLs. GET and HEAD read the object, PUT
+// replaces it.
+func (s *LocalStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+
+	if method != http.MethodGet && method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	object := cleanObject(strings.TrimPrefix(r.URL.Path, s.baseURL.Path))
+	if !s.verify(method, object, r.URL.Query()) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if method == http.MethodGet {
+		s.serveObject(w, r, object)
+	} else {
+		s.receiveObject(w, r, object)
+	}
+}
+
+func (s *LocalStorage) serveObject(w http.ResponseWriter, r *http.Request, object string) {
+	file, err := s.Open(object)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	contentType := r.URL.Query().Get("type")
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	// The objects are served on the origin of the REST server, so only the
+	// safe raster images are rendered inline, like the downloads of the API.
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	if !domain.IsInlineContentType(contentType) {
+		w.Header().Set("Content-Disposition", "attachment")
+	}
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
+
+func (s *LocalStorage) receiveObject(w http.ResponseWriter, r *http.Request, object string) {
+	maxSize, err := strconv.ParseInt(r.URL.Query().Get("max_size"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if r.ContentLength > maxSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Read one more byte than allowed to know if the content is too large.
+	n, err := s.Write(object, io.LimitReader(r.Body, maxSize+1))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if n == 0 || n > maxSize {
+		if err := s.Remove(object); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if n == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *LocalStorage) verify(method string, object string, query url.Values) bool {
+	signature := query.Get("signature")
+	query.Del("signature")
+
+	expected := s.sign(method, object, query)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return false
+	}
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return time.Now().Before(time.Unix(expires, 0))
+}
+
+func (s *LocalStorage) sign(method string, object string, query url.Values) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(method + "\n" + object + "\n" + query.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// filePath returns the path of the object on disk. The object cannot escape the
+// root directory.
+func (s *LocalStorage) filePath(object string) string {
+	return filepath.Join(s.root, filepath.FromSlash(cleanObject(object)))
+}
+
+func cleanObject(object string) string {
+	return strings.TrimPrefix(path.Clean("/"+object), "/")
+}
@@ -0,0 +1 @@
+ALTER TABLE file_ownerships DROP COLUMN updated_at;
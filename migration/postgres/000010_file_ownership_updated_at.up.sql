@@ -0,0 +1,2 @@
+-- The existing ownerships start their grace period at the migration.
+ALTER TABLE file_ownerships ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT now();
@@ -0,0 +1,3 @@
+DROP INDEX files_status_updated_at_idx;
+ALTER TABLE files DROP COLUMN updated_at;
+ALTER TABLE files DROP COLUMN status;
@@ -0,0 +1,9 @@
+-- The existing files have all been stored before the statuses existed.
+ALTER TABLE files ADD COLUMN status VARCHAR NOT NULL DEFAULT 'stored';
+ALTER TABLE files ALTER COLUMN status DROP DEFAULT;
+
+ALTER TABLE files ADD COLUMN updated_at TIMESTAMP;
+UPDATE files SET updated_at = created_at;
+ALTER TABLE files ALTER COLUMN updated_at SET NOT NULL;
+
+CREATE INDEX files_status_updated_at_idx ON files (status, updated_at);
@@ -0,0 +1,7 @@
+CREATE TABLE file_outbox (
+    id BIGINT PRIMARY KEY,
+    type VARCHAR,
+    file_id VARCHAR,
+    payload VARCHAR,
+    created_at TIMESTAMP
+);
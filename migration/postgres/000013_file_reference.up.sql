@@ -0,0 +1,15 @@
+CREATE TABLE file_references (
+    ownership_id BIGINT REFERENCES file_ownerships(id),
+    service VARCHAR,
+    entity_type VARCHAR,
+    entity_id VARCHAR,
+    created_at TIMESTAMP,
+    PRIMARY KEY (ownership_id, service, entity_type, entity_id)
+);
+
+-- The existing refcounts were changed by ChangeRefcount, so each of them is
+-- backfilled as legacy references. Otherwise they could never be decreased.
+INSERT INTO file_references (ownership_id, service, entity_type, entity_id, created_at)
+SELECT file_ownerships.id, 'legacy', 'refcount', 'backfill-' || n, now()
+FROM file_ownerships, generate_series(1, file_ownerships.refcount) AS n
+WHERE file_ownerships.refcount > 0;
@@ -0,0 +1,3 @@
+ALTER TABLE files DROP COLUMN image_height;
+ALTER TABLE files DROP COLUMN image_width;
+ALTER TABLE files DROP COLUMN image_format;
@@ -0,0 +1,4 @@
+-- The files without image metadata have an empty format.
+ALTER TABLE files ADD COLUMN image_format VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE files ADD COLUMN image_width INT NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN image_height INT NOT NULL DEFAULT 0;
@@ -0,0 +1,12 @@
+CREATE TABLE file_variants (
+    file_id VARCHAR REFERENCES files(id),
+    name VARCHAR,
+    bucket VARCHAR,
+    type VARCHAR,
+    size INT,
+    image_format VARCHAR,
+    image_width INT,
+    image_height INT,
+    created_at TIMESTAMP,
+    PRIMARY KEY (file_id, name)
+);
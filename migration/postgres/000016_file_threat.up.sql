@@ -0,0 +1,2 @@
+-- A clean file has an empty threat.
+ALTER TABLE files ADD COLUMN threat VARCHAR NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE files ADD COLUMN purpose VARCHAR NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+DROP INDEX file_ownerships_user_id_id_idx;
+ALTER TABLE file_ownerships DROP COLUMN description;
+ALTER TABLE file_ownerships DROP COLUMN display_name;
@@ -0,0 +1,5 @@
+ALTER TABLE file_ownerships ADD COLUMN display_name VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE file_ownerships ADD COLUMN description VARCHAR(2000) NOT NULL DEFAULT '';
+
+-- Users list their ownerships from the newest to the oldest.
+CREATE INDEX file_ownerships_user_id_id_idx ON file_ownerships (user_id, id DESC);
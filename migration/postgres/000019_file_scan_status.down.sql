@@ -0,0 +1,2 @@
+DROP INDEX files_pending_scan_idx;
+ALTER TABLE files DROP COLUMN scan_status;
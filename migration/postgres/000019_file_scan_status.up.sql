@@ -0,0 +1,5 @@
+-- The files stored before this migration are not known to be scanned.
+ALTER TABLE files ADD COLUMN scan_status VARCHAR(16) NOT NULL DEFAULT 'skipped';
+
+-- The janitor looks for the files whose asynchronous scan is still pending.
+CREATE INDEX files_pending_scan_idx ON files (id) WHERE scan_status = 'pending';
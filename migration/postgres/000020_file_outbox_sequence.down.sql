@@ -0,0 +1,2 @@
+DROP INDEX file_outbox_file_id_sequence_idx;
+ALTER TABLE file_outbox DROP COLUMN sequence;
@@ -0,0 +1,5 @@
+-- The events written before this migration keep sequence 0 and are ordered by ID.
+ALTER TABLE file_outbox ADD COLUMN sequence BIGINT NOT NULL DEFAULT 0;
+
+-- The relay publishes the events ordered by file and sequence.
+CREATE INDEX file_outbox_file_id_sequence_idx ON file_outbox (file_id, sequence, id);
@@ -0,0 +1,13 @@
+package abstraction
+
+import "io"
+
+type ContentDetector interface {
+	// Detect returns the content type of the content, then rewinds it.
+	Detect(content io.ReadSeeker) (string, error)
+
+	// Peek detects the content type from the beginning of the content. It
+	// returns an empty type if the whole content is needed, and a reader of
+	// the whole content.
+	Peek(content io.Reader) (string, io.Reader, error)
+}
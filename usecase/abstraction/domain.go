@@ -6,9 +6,26 @@ import (
 )
 
 type FileDomain interface {
-	ClassifyBucket(t string) string
-	NewUploadPolicy(userID snowflake.ID, allowedTypes []string, maxSize int64) *domain.UploadPolicy
+	ClassifyBucket(contentType string, size int, purpose string) string
+	NewUploadPolicy(userID snowflake.ID, allowedTypes []string, deniedTypes []string, maxSize int64,
+		direct bool, stripMetadata bool, sanitizeSVG bool, imageLimits domain.ImageLimits,
+		purpose string) *domain.UploadPolicy
+	NewStagingKey() string
 	NewFileInfo(id string, metadata *domain.FileMetadata) *domain.FileInfo
+	QuarantineFile(file *domain.FileInfo, threat string)
 	NewFileOwnership(fileID string, userID snowflake.ID) *domain.FileOwnership
+	NewFileReference(ownershipID snowflake.ID, service, entityType, entityID string) *domain.FileReference
+	NewLegacyFileReference(ownershipID snowflake.ID) *domain.FileReference
 	NewFileToken(file *domain.FileInfo, ownership *domain.FileOwnership) *domain.FileToken
+	NewFileTokenRevocation(tokenID, ownershipID, userID snowflake.ID) *domain.FileTokenRevocation
+	NewFileVariant(file *domain.FileInfo, name string, image *domain.EncodedImage) *domain.FileVariant
+	NewFileStoredEvent(file *domain.FileInfo) *domain.FileEvent
+	NewFileDeletedEvent(file *domain.FileInfo) *domain.FileEvent
+	NewFileQuarantinedEvent(file *domain.FileInfo) *domain.FileEvent
+	NewOwnershipCreatedEvent(ownership *domain.FileOwnership) *domain.FileEvent
+	NewOwnershipRefcountChangedEvent(
+		ownership *domain.FileOwnership, reference *domain.FileReference, change int) *domain.FileEvent
+	NewOwnershipDeletedEvent(ownership *domain.FileOwnership) *domain.FileEvent
+	NewResumableUpload(policy *domain.UploadPolicy, length int64) *domain.ResumableUpload
+	NewResumableUploadChunkName(upload *domain.ResumableUpload) string
 }
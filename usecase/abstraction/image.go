@@ -0,0 +1,40 @@
+package abstraction
+
+import (
+	"io"
+
+	"github.com/todennus/file-service/domain"
+)
+
+type ImageProcessor interface {
+	// DecodeMetadata reads the header of the image content. It returns nil if
+	// the format of contentType is not supported.
+	DecodeMetadata(content io.Reader, contentType string) (*domain.ImageMetadata, error)
+
+	// Transform applies the transformation to the image. It returns nil if the
+	// format of contentType is not supported.
+	Transform(content io.Reader, contentType string, transform *domain.ImageTransform) (*domain.EncodedImage, error)
+
+	// TransformAll decodes the image once and applies every transformation to
+	// it, the results are in the same order. It returns nil if the format of
+	// contentType is not supported.
+	TransformAll(content io.Reader, contentType string, transforms []*domain.ImageTransform) ([]*domain.EncodedImage, error)
+
+	// StripMetadata removes the EXIF, XMP and IPTC metadata of the image and
+	// applies its EXIF orientation. It returns nil if the format of
+	// contentType is not supported.
+	StripMetadata(content io.Reader, contentType string) ([]byte, error)
+
+	// SanitizeSVG removes scripts, event handlers, foreign objects and
+	// external references from the SVG image. Documents declaring entities
+	// are rejected.
+	SanitizeSVG(content io.Reader) ([]byte, error)
+
+	// CheckLimits returns the metadata read from the header of the image, or
+	// an error if the image cannot be fully decoded or exceeds the limits. The
+	// dimensions declared in the header are checked before decoding the
+	// pixels. It returns nil if the format of contentType is not supported
+	// and there is no limit, or if the image is SVG. Otherwise, an image of an
+	// unsupported format is rejected.
+	CheckLimits(content io.Reader, contentType string, limits domain.ImageLimits) (*domain.ImageMetadata, error)
+}
@@ -14,19 +14,100 @@ type FileUploadPolicyRepository interface {
 	LoadAndDelete(ctx context.Context, token string) (*domain.UploadPolicy, error)
 }
 
+type FileTokenRevocationRepository interface {
+	Save(ctx context.Context, revocation *domain.FileTokenRevocation) error
+	GetByToken(ctx context.Context, token *domain.FileToken) ([]*domain.FileTokenRevocation, error)
+}
+
 type FileInfoRepository interface {
 	Create(ctx context.Context, file *domain.FileInfo) error
 	GetByID(ctx context.Context, id string) (*domain.FileInfo, error)
+	GetByIDs(ctx context.Context, ids []string) ([]*domain.FileInfo, error)
+	Claim(ctx context.Context, id string, staleBefore time.Time) error
+	MarkStored(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id string) error
+	Quarantine(ctx context.Context, file *domain.FileInfo) error
+	MarkClean(ctx context.Context, id string) error
+	FailStale(ctx context.Context, staleBefore time.Time) (int, error)
+	GetOrphaned(ctx context.Context, createdBefore time.Time, afterID string, n int) ([]*domain.FileInfo, error)
+	LockOrphaned(ctx context.Context, id string, createdBefore time.Time) (*domain.FileInfo, error)
+	GetPendingScans(ctx context.Context, updatedBefore time.Time, afterID string, n int) ([]*domain.FileInfo, error)
+	GetStored(ctx context.Context, afterID string, n int) ([]*domain.FileInfo, error)
+	LockStored(ctx context.Context, id string) (*domain.FileInfo, error)
+	ChangeBucket(ctx context.Context, file *domain.FileInfo) error
+	Delete(ctx context.Context, id string) error
 }
 
 type FileOwnershipRepository interface {
 	Create(ctx context.Context, fileowner *domain.FileOwnership) error
 	Get(ctx context.Context, fileID string, userID snowflake.ID) (*domain.FileOwnership, error)
 	GetByID(ctx context.Context, id snowflake.ID) (*domain.FileOwnership, error)
-	ChangeRefCount(ctx context.Context, id snowflake.ID, change int) error
+	GetByIDs(ctx context.Context, ids []snowflake.ID) ([]*domain.FileOwnership, error)
+	ListByUser(ctx context.Context, userID snowflake.ID, filter domain.OwnershipFilter,
+		beforeID snowflake.ID, n int) ([]*domain.FileOwnership, error)
+	UpdateDetails(ctx context.Context, ownership *domain.FileOwnership) error
+	ChangeRefCount(ctx context.Context, id snowflake.ID, change int) (*domain.FileOwnership, error)
+	GetUnreferenced(ctx context.Context, updatedBefore time.Time, afterID snowflake.ID, n int) ([]*domain.FileOwnership, error)
+	DeleteUnreferenced(ctx context.Context, id snowflake.ID, updatedBefore time.Time) error
+}
+
+type FileReferenceRepository interface {
+	Create(ctx context.Context, reference *domain.FileReference) error
+	Delete(ctx context.Context, reference *domain.FileReference) error
+	DeleteAny(ctx context.Context, ownershipID snowflake.ID, service, entityType string) (*domain.FileReference, error)
+	GetByOwnership(ctx context.Context, ownershipID snowflake.ID) ([]*domain.FileReference, error)
+}
+
+type FileVariantRepository interface {
+	Create(ctx context.Context, variant *domain.FileVariant) error
+	Get(ctx context.Context, fileID string, name string) (*domain.FileVariant, error)
+	GetByFile(ctx context.Context, fileID string) ([]*domain.FileVariant, error)
+	ChangeBucket(ctx context.Context, file *domain.FileInfo) error
+	DeleteByFile(ctx context.Context, fileID string) error
+}
+
+type FileOutboxRepository interface {
+	Create(ctx context.Context, events ...*domain.FileEvent) error
+	LockNext(ctx context.Context, n int) ([]*domain.FileEvent, error)
+	Delete(ctx context.Context, ids []snowflake.ID) error
+}
+
+type FileEventStreamRepository interface {
+	Publish(ctx context.Context, events []*domain.FileEvent) error
 }
 
 type FileStorageRepository interface {
 	Presign(ctx context.Context, file *domain.FileInfo, expiration time.Duration) (string, error)
-	Store(ctx context.Context, file *domain.FileInfo, content io.Reader) error
+	Open(ctx context.Context, file *domain.FileInfo) (io.ReadSeekCloser, error)
+	Delete(ctx context.Context, file *domain.FileInfo) error
+	Move(ctx context.Context, from *domain.FileInfo, to *domain.FileInfo) error
+	Copy(ctx context.Context, from *domain.FileInfo, to *domain.FileInfo) error
+
+	PresignVariant(ctx context.Context, variant *domain.FileVariant, expiration time.Duration) (string, error)
+	StoreVariant(ctx context.Context, variant *domain.FileVariant, content io.Reader) error
+	OpenVariant(ctx context.Context, variant *domain.FileVariant) (io.ReadSeekCloser, error)
+	DeleteVariant(ctx context.Context, variant *domain.FileVariant) error
+	CopyVariant(ctx context.Context, from *domain.FileVariant, to *domain.FileVariant) error
+
+	StoreChunk(ctx context.Context, name string, content io.Reader, size int64) (int64, error)
+	OpenChunks(ctx context.Context, chunks []domain.ResumableUploadChunk) (io.ReadSeekCloser, error)
+	DeleteChunks(ctx context.Context, chunks []domain.ResumableUploadChunk) error
+	DeleteExpiredTemporaryObjects(ctx context.Context, before time.Time) (int, error)
+
+	Stage(ctx context.Context, key string, content io.Reader, size int64) (int64, error)
+	PresignStagingUpload(ctx context.Context, policy *domain.UploadPolicy) (*domain.PresignedUpload, error)
+	OpenStaged(ctx context.Context, key string) (io.ReadSeekCloser, int64, error)
+	Promote(ctx context.Context, key string, file *domain.FileInfo) error
+	DeleteStaged(ctx context.Context, key string) error
+
+	StoreCached(ctx context.Context, key string, content io.Reader, size int64, contentType string) error
+	OpenCached(ctx context.Context, key string) (io.ReadSeekCloser, int64, error)
+	DeleteExpiredCachedObjects(ctx context.Context, before time.Time) (int, error)
+}
+
+type ResumableUploadRepository interface {
+	Save(ctx context.Context, upload *domain.ResumableUpload) error
+	SaveIfOffset(ctx context.Context, upload *domain.ResumableUpload, offset int64) (bool, error)
+	Get(ctx context.Context, id string) (*domain.ResumableUpload, error)
+	Delete(ctx context.Context, id string) error
 }
@@ -0,0 +1,12 @@
+package abstraction
+
+import (
+	"context"
+	"io"
+)
+
+type ContentScanner interface {
+	// Scan returns the name of the threat detected in the content, or an
+	// empty string if the content is clean.
+	Scan(ctx context.Context, content io.Reader) (string, error)
+}
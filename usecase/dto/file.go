@@ -1,9 +1,10 @@
 package dto
 
 import (
+	"io"
 	"time"
 
-	"github.com/todennus/x/xhttp"
+	"github.com/todennus/file-service/domain"
 	"github.com/xybor-x/snowflake"
 )
 
@@ -11,19 +12,65 @@ type RegisterUploadRequest struct {
 	UserID       snowflake.ID
 	AllowedTypes []string
 	MaxSize      int64
+
+	// DeniedTypes are rejected even if they match AllowedTypes. Both may
+	// contain patterns like image/* and type groups like @images.
+	DeniedTypes []string
+
+	// Direct allows the client to upload the file directly to the storage,
+	// then call FinalizeUpload.
+	Direct bool
+
+	// StripMetadata removes the metadata of uploaded images.
+	StripMetadata bool
+
+	// SanitizeSVG removes the active content of uploaded SVG images. It is
+	// required to allow SVG images.
+	SanitizeSVG bool
+
+	// The limits of uploaded images, the zero ones are replaced by the default
+	// limits of the service.
+	MaxWidth      int
+	MaxHeight     int
+	MaxMegapixels float64
+	MaxFrames     int
+
+	// Purpose tags the uploaded file for the bucket rules.
+	Purpose string
 }
 
 type RegisterUploadResponse struct {
 	UploadToken string
+
+	// The presigned fields are only set for direct uploads.
+	PresignedPutURL       string
+	PresignedPostURL      string
+	PresignedPostFormData map[string]string
 }
 
-func NewRegisterUploadResponse(uploadToken string) *RegisterUploadResponse {
-	return &RegisterUploadResponse{UploadToken: uploadToken}
+func NewRegisterUploadResponse(uploadToken string, presignedUpload *domain.PresignedUpload) *RegisterUploadResponse {
+	resp := &RegisterUploadResponse{UploadToken: uploadToken}
+	if presignedUpload != nil {
+		resp.PresignedPutURL = presignedUpload.PutURL
+		resp.PresignedPostURL = presignedUpload.PostURL
+		resp.PresignedPostFormData = presignedUpload.PostFormData
+	}
+
+	return resp
 }
 
 type UploadRequest struct {
 	UploadToken string
-	File        *xhttp.File
+
+	// File is read only once, while it is streamed to the storage.
+	File io.ReadCloser
+}
+
+// InstantUploadRequest declares the content of a file before uploading it.
+type InstantUploadRequest struct {
+	UploadToken string
+	SHA256      []byte
+	Size        int64
 }
 
 type UploadResponse struct {
@@ -31,10 +78,117 @@ type UploadResponse struct {
 	Bucket      string
 	OwnershipID snowflake.ID
 	FileToken   string
+	Image       *ImageMetadata
 }
 
-func NewUploadResponse(fileID, bucket string, ownershipID snowflake.ID, fileToken string) *UploadResponse {
-	return &UploadResponse{FileID: fileID, Bucket: bucket, OwnershipID: ownershipID, FileToken: fileToken}
+func NewUploadResponse(file *domain.FileInfo, ownershipID snowflake.ID, fileToken string) *UploadResponse {
+	return &UploadResponse{
+		FileID:      file.ID,
+		Bucket:      file.Metadata.Bucket,
+		OwnershipID: ownershipID,
+		FileToken:   fileToken,
+		Image:       NewImageMetadata(file.Metadata.Image),
+	}
+}
+
+type ImageMetadata struct {
+	Format string
+	Width  int
+	Height int
+}
+
+func NewImageMetadata(image *domain.ImageMetadata) *ImageMetadata {
+	if image == nil {
+		return nil
+	}
+
+	return &ImageMetadata{Format: image.Format, Width: image.Width, Height: image.Height}
+}
+
+type FinalizeUploadRequest struct {
+	UploadToken string
+}
+
+type GetFileInfoRequest struct {
+	OwnershipID snowflake.ID
+}
+
+type GetFileInfoResponse struct {
+	FileID    string
+	Bucket    string
+	Type      string
+	Size      int
+	Image     *ImageMetadata
+	CreatedAt time.Time
+	OwnerID   snowflake.ID
+	RefCount  int
+}
+
+func NewGetFileInfoResponse(ownership *domain.FileOwnership, file *domain.FileInfo) *GetFileInfoResponse {
+	return &GetFileInfoResponse{
+		FileID:    file.ID,
+		Bucket:    file.Metadata.Bucket,
+		Type:      file.Metadata.Type,
+		Size:      file.Metadata.Size,
+		Image:     NewImageMetadata(file.Metadata.Image),
+		CreatedAt: file.CreatedAt,
+		OwnerID:   ownership.UserID,
+		RefCount:  ownership.RefCount,
+	}
+}
+
+// OwnershipInfo is an ownership together with the metadata of its file.
+type OwnershipInfo struct {
+	OwnershipID snowflake.ID
+	OwnerID     snowflake.ID
+	RefCount    int
+	DisplayName string
+	Description string
+	UpdatedAt   time.Time
+
+	FileID    string
+	Bucket    string
+	Type      string
+	Size      int
+	Image     *ImageMetadata
+	CreatedAt time.Time
+}
+
+func NewOwnershipInfo(ownership *domain.FileOwnership, file *domain.FileInfo) *OwnershipInfo {
+	return &OwnershipInfo{
+		OwnershipID: ownership.ID,
+		OwnerID:     ownership.UserID,
+		RefCount:    ownership.RefCount,
+		DisplayName: ownership.DisplayName,
+		Description: ownership.Description,
+		UpdatedAt:   ownership.UpdatedAt,
+		FileID:      file.ID,
+		Bucket:      file.Metadata.Bucket,
+		Type:        file.Metadata.Type,
+		Size:        file.Metadata.Size,
+		Image:       NewImageMetadata(file.Metadata.Image),
+		CreatedAt:   file.CreatedAt,
+	}
+}
+
+type GetOwnershipRequest struct {
+	OwnershipID snowflake.ID
+}
+
+type GetOwnershipResponse struct {
+	Ownership *OwnershipInfo
+}
+
+func NewGetOwnershipResponse(ownership *domain.FileOwnership, file *domain.FileInfo) *GetOwnershipResponse {
+	return &GetOwnershipResponse{Ownership: NewOwnershipInfo(ownership, file)}
+}
+
+type BatchGetOwnershipsRequest struct {
+	OwnershipIDs []snowflake.ID
+}
+
+type BatchGetOwnershipsResponse struct {
+	Ownerships []*OwnershipInfo
 }
 
 type RetrieveFileTokenRequest struct {
@@ -51,9 +205,58 @@ func NewRetrieveFileTokenResponse(fileToken string) *RetrieveFileTokenResponse {
 	}
 }
 
+type DownloadRequest struct {
+	OwnershipID snowflake.ID
+
+	// Variant is the name of an image variant. The original file is
+	// downloaded if it is empty.
+	Variant string
+}
+
+type DownloadByFileTokenRequest struct {
+	FileToken string
+	Variant   string
+}
+
+type DownloadResponse struct {
+	// Content must be closed by the caller.
+	Content   io.ReadSeekCloser
+	FileID    string
+	Type      string
+	Size      int
+	CreatedAt time.Time
+
+	// Inline is true if browsers can render the content without running any
+	// script, otherwise it must be served as an attachment.
+	Inline bool
+}
+
+func NewDownloadResponse(file *domain.FileInfo, content io.ReadSeekCloser) *DownloadResponse {
+	return &DownloadResponse{
+		Content:   content,
+		FileID:    file.ID,
+		Type:      file.Metadata.Type,
+		Size:      file.Metadata.Size,
+		CreatedAt: file.CreatedAt,
+		Inline:    domain.IsInlineContentType(file.Metadata.Type),
+	}
+}
+
+func NewVariantDownloadResponse(variant *domain.FileVariant, content io.ReadSeekCloser) *DownloadResponse {
+	return &DownloadResponse{
+		Content:   content,
+		FileID:    variant.FileID + "." + variant.Name,
+		Type:      variant.Type,
+		Size:      variant.Size,
+		CreatedAt: variant.CreatedAt,
+		Inline:    domain.IsInlineContentType(variant.Type),
+	}
+}
+
 type CreatePresignedURLRequest struct {
 	OwnershipID snowflake.ID
 	FileID      string
+	Variant     string
 	Expiration  time.Duration
 }
 
@@ -0,0 +1,25 @@
+package dto
+
+type CleanUpRequest struct{}
+
+type CleanUpResponse struct {
+	FailedStaleFiles        int
+	RescannedFiles          int
+	DeletedOwnerships       int
+	DeletedFiles            int
+	DeletedTemporaryObjects int
+	DeletedCachedObjects    int
+}
+
+func NewCleanUpResponse(
+	failedStaleFiles, rescannedFiles, deletedOwnerships, deletedFiles, deletedTemporaryObjects, deletedCachedObjects int,
+) *CleanUpResponse {
+	return &CleanUpResponse{
+		FailedStaleFiles:        failedStaleFiles,
+		RescannedFiles:          rescannedFiles,
+		DeletedOwnerships:       deletedOwnerships,
+		DeletedFiles:            deletedFiles,
+		DeletedTemporaryObjects: deletedTemporaryObjects,
+		DeletedCachedObjects:    deletedCachedObjects,
+	}
+}
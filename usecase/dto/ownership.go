@@ -0,0 +1,44 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/xybor-x/snowflake"
+)
+
+type ListOwnershipsRequest struct {
+	// Cursor is the id of the last ownership of the previous page. The first
+	// page is returned if it is zero.
+	Cursor snowflake.ID
+	Limit  int
+
+	Type          string
+	Bucket        string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+type ListOwnershipsResponse struct {
+	Ownerships []*OwnershipInfo
+
+	// NextCursor is zero if there is no more page.
+	NextCursor snowflake.ID
+}
+
+type UpdateOwnershipRequest struct {
+	OwnershipID snowflake.ID
+
+	// DisplayName and Description are not changed if they are nil.
+	DisplayName *string
+	Description *string
+}
+
+type UpdateOwnershipResponse struct {
+	Ownership *OwnershipInfo
+}
+
+type DeleteOwnershipRequest struct {
+	OwnershipID snowflake.ID
+}
+
+type DeleteOwnershipResponse struct{}
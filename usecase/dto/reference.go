@@ -0,0 +1,69 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/xybor-x/snowflake"
+)
+
+type AddReferenceRequest struct {
+	OwnershipID snowflake.ID
+	Service     string
+	EntityType  string
+	EntityID    string
+}
+
+type AddReferenceResponse struct {
+	RefCount int
+}
+
+func NewAddReferenceResponse(refCount int) *AddReferenceResponse {
+	return &AddReferenceResponse{RefCount: refCount}
+}
+
+type RemoveReferenceRequest struct {
+	OwnershipID snowflake.ID
+	Service     string
+	EntityType  string
+	EntityID    string
+}
+
+type RemoveReferenceResponse struct {
+	RefCount int
+}
+
+func NewRemoveReferenceResponse(refCount int) *RemoveReferenceResponse {
+	return &RemoveReferenceResponse{RefCount: refCount}
+}
+
+type ListReferencesRequest struct {
+	OwnershipID snowflake.ID
+}
+
+type Reference struct {
+	OwnershipID snowflake.ID
+	Service     string
+	EntityType  string
+	EntityID    string
+	CreatedAt   time.Time
+}
+
+type ListReferencesResponse struct {
+	References []Reference
+}
+
+func NewListReferencesResponse(references []*domain.FileReference) *ListReferencesResponse {
+	resp := &ListReferencesResponse{References: make([]Reference, 0, len(references))}
+	for i := range references {
+		resp.References = append(resp.References, Reference{
+			OwnershipID: references[i].OwnershipID,
+			Service:     references[i].Service,
+			EntityType:  references[i].EntityType,
+			EntityID:    references[i].EntityID,
+			CreatedAt:   references[i].CreatedAt,
+		})
+	}
+
+	return resp
+}
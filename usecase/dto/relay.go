@@ -0,0 +1,11 @@
+package dto
+
+type RelayRequest struct{}
+
+type RelayResponse struct {
+	Published int
+}
+
+func NewRelayResponse(published int) *RelayResponse {
+	return &RelayResponse{Published: published}
+}
@@ -0,0 +1,12 @@
+package dto
+
+type RelocateRequest struct {
+	// DryRun only counts the files which would be relocated.
+	DryRun bool
+}
+
+type RelocateResponse struct {
+	Checked   int
+	Relocated int
+	Failed    int
+}
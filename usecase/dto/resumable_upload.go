@@ -0,0 +1,69 @@
+package dto
+
+import (
+	"io"
+	"time"
+)
+
+type CreateResumableUploadRequest struct {
+	UploadToken string
+	Length      int64
+}
+
+type CreateResumableUploadResponse struct {
+	UploadID  string
+	ExpiresAt time.Time
+}
+
+func NewCreateResumableUploadResponse(uploadID string, expiresAt time.Time) *CreateResumableUploadResponse {
+	return &CreateResumableUploadResponse{UploadID: uploadID, ExpiresAt: expiresAt}
+}
+
+type GetResumableUploadRequest struct {
+	UploadID string
+}
+
+type GetResumableUploadResponse struct {
+	Offset    int64
+	Length    int64
+	ExpiresAt time.Time
+}
+
+func NewGetResumableUploadResponse(offset, length int64, expiresAt time.Time) *GetResumableUploadResponse {
+	return &GetResumableUploadResponse{Offset: offset, Length: length, ExpiresAt: expiresAt}
+}
+
+type AppendResumableUploadRequest struct {
+	UploadID string
+	Offset   int64
+	Content  io.Reader
+
+	// ContentLength is the size of the content, it is -1 if unknown.
+	ContentLength int64
+}
+
+type AppendResumableUploadResponse struct {
+	Offset    int64
+	ExpiresAt time.Time
+
+	// Upload is only set when the last chunk has been received.
+	Upload *UploadResponse
+}
+
+func NewAppendResumableUploadResponse(
+	offset int64,
+	expiresAt time.Time,
+	upload *UploadResponse,
+) *AppendResumableUploadResponse {
+	return &AppendResumableUploadResponse{Offset: offset, ExpiresAt: expiresAt, Upload: upload}
+}
+
+type TerminateResumableUploadRequest struct {
+	UploadID string
+}
+
+type TerminateResumableUploadResponse struct{}
+
+func NewTerminateResumableUploadResponse() *TerminateResumableUploadResponse {
+	return &TerminateResumableUploadResponse{}
+}
@@ -5,8 +5,58 @@ import (
 
 	"github.com/todennus/file-service/domain"
 	"github.com/todennus/shared/tokendef"
+	"github.com/xybor-x/snowflake"
 )
 
+type VerifyFileTokenRequest struct {
+	FileToken string
+
+	// ExpectedUserID binds the verification to a user. It is not checked if
+	// it is empty.
+	ExpectedUserID snowflake.ID
+}
+
+type VerifyFileTokenResponse struct {
+	TokenID     snowflake.ID
+	OwnershipID snowflake.ID
+	FileID      string
+	UserID      snowflake.ID
+	Type        string
+	Size        int
+	Image       *ImageMetadata
+	ExpiresAt   time.Time
+}
+
+// NewVerifyFileTokenResponse reads the image metadata from the file info, the
+// token only carries the type and the size.
+func NewVerifyFileTokenResponse(t *domain.FileToken, file *domain.FileInfo) *VerifyFileTokenResponse {
+	return &VerifyFileTokenResponse{
+		TokenID:     t.ID,
+		OwnershipID: t.OwnershipID,
+		FileID:      t.FileID,
+		UserID:      t.UserID,
+		Type:        t.Type,
+		Size:        t.Size,
+		Image:       NewImageMetadata(file.Metadata.Image),
+		ExpiresAt:   t.ExpiresAt,
+	}
+}
+
+type RevokeFileTokenRequest struct {
+	TokenID     snowflake.ID
+	OwnershipID snowflake.ID
+	UserID      snowflake.ID
+}
+
+type RevokeFileTokenResponse struct {
+	// ExpiresAt is when all the revoked tokens have expired.
+	ExpiresAt time.Time
+}
+
+func NewRevokeFileTokenResponse(revocation *domain.FileTokenRevocation) *RevokeFileTokenResponse {
+	return &RevokeFileTokenResponse{ExpiresAt: revocation.ExpiresAt}
+}
+
 func FileTokenFromDomain(t *domain.FileToken) *tokendef.FileToken {
 	return &tokendef.FileToken{
 		ID:          t.ID.String(),
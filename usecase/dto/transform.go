@@ -0,0 +1,48 @@
+package dto
+
+import (
+	"io"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/xybor-x/snowflake"
+)
+
+type CreateTransformURLRequest struct {
+	OwnershipID snowflake.ID
+	FileID      string
+	Options     string
+}
+
+type CreateTransformURLResponse struct {
+	// Path is relative to the REST server.
+	Path string
+}
+
+func NewCreateTransformURLResponse(path string) *CreateTransformURLResponse {
+	return &CreateTransformURLResponse{Path: path}
+}
+
+type TransformRequest struct {
+	Signature string
+	Options   string
+	FileID    string
+}
+
+// NewTransformResponse returns the transformed image of the file. The cache key
+// identifies the content, so it is used as the FileID.
+func NewTransformResponse(
+	file *domain.FileInfo,
+	cacheKey string,
+	contentType string,
+	size int,
+	content io.ReadSeekCloser,
+) *DownloadResponse {
+	return &DownloadResponse{
+		Content:   content,
+		FileID:    cacheKey,
+		Type:      contentType,
+		Size:      size,
+		CreatedAt: file.CreatedAt,
+		Inline:    domain.IsInlineContentType(contentType),
+	}
+}
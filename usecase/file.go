@@ -1,11 +1,14 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"io"
 	"slices"
+	"time"
 
 	"github.com/todennus/file-service/domain"
 	"github.com/todennus/file-service/usecase/abstraction"
@@ -13,46 +16,121 @@ import (
 	"github.com/todennus/shared/errordef"
 	"github.com/todennus/shared/middleware"
 	"github.com/todennus/shared/scopedef"
+	"github.com/todennus/shared/tokendef"
 	"github.com/todennus/shared/xcontext"
 	"github.com/todennus/x/mime"
 	"github.com/todennus/x/token"
 	"github.com/todennus/x/xcrypto"
 	"github.com/todennus/x/xerror"
 	"github.com/todennus/x/xhttp"
+	"github.com/xybor-x/snowflake"
 )
 
+// The delay between two checks of a file which is being stored by another
+// uploader.
+const (
+	pendingPollMinDelay = 100 * time.Millisecond
+	pendingPollMaxDelay = 2 * time.Second
+)
+
+// maxBatchOwnerships is the maximum number of ownerships which can be queried
+// in one BatchGetOwnerships request.
+const maxBatchOwnerships = 100
+
 type FileUsecase struct {
-	maxInMemory int64
+	// pendingTimeout is how long a file can be pending before another uploader
+	// is allowed to store it again.
+	pendingTimeout time.Duration
+
+	// variants are generated for every stored image.
+	variants []domain.VariantSpec
+
+	// imageLimits are applied to the upload policies which don't set their own
+	// limits.
+	imageLimits domain.ImageLimits
+
+	// typeGroups are the groups which the upload policies may refer to.
+	typeGroups domain.TypeGroups
+
+	// asyncScan scans the content after it is stored instead of before, so
+	// the uploader doesn't wait for the scanner.
+	asyncScan bool
 
 	tokenEngine token.Engine
 
 	fileDomain abstraction.FileDomain
 
-	fileUploadPolicyRepo abstraction.FileUploadPolicyRepository
-	fileInfoRepo         abstraction.FileInfoRepository
-	fileOwnershipRepo    abstraction.FileOwnershipRepository
-	fileStorageRepo      abstraction.FileStorageRepository
+	fileUploadPolicyRepo    abstraction.FileUploadPolicyRepository
+	fileInfoRepo            abstraction.FileInfoRepository
+	fileOwnershipRepo       abstraction.FileOwnershipRepository
+	fileReferenceRepo       abstraction.FileReferenceRepository
+	fileVariantRepo         abstraction.FileVariantRepository
+	fileOutboxRepo          abstraction.FileOutboxRepository
+	fileStorageRepo         abstraction.FileStorageRepository
+	resumableUploadRepo     abstraction.ResumableUploadRepository
+	fileTokenRevocationRepo abstraction.FileTokenRevocationRepository
+
+	imageProcessor  abstraction.ImageProcessor
+	contentDetector abstraction.ContentDetector
+
+	// contentScanner is nil if the content is not scanned.
+	contentScanner    abstraction.ContentScanner
+	storedFileScanner *storedFileScanner
 }
 
 func NewFileUsecase(
-	maxInMemory int64,
+	pendingTimeout time.Duration,
+	variants []domain.VariantSpec,
+	imageLimits domain.ImageLimits,
+	typeGroups domain.TypeGroups,
+	asyncScan bool,
 	tokenEngine token.Engine,
 	fileDomain abstraction.FileDomain,
 	fileUploadPolicyRepo abstraction.FileUploadPolicyRepository,
 	fileRepo abstraction.FileInfoRepository,
 	fileOwnerRepo abstraction.FileOwnershipRepository,
+	fileReferenceRepo abstraction.FileReferenceRepository,
+	fileVariantRepo abstraction.FileVariantRepository,
+	fileOutboxRepo abstraction.FileOutboxRepository,
 	fileStorageRepo abstraction.FileStorageRepository,
+	resumableUploadRepo abstraction.ResumableUploadRepository,
+	fileTokenRevocationRepo abstraction.FileTokenRevocationRepository,
+	imageProcessor abstraction.ImageProcessor,
+	contentDetector abstraction.ContentDetector,
+	contentScanner abstraction.ContentScanner,
 ) *FileUsecase {
 	return &FileUsecase{
-		maxInMemory: maxInMemory,
-		tokenEngine: tokenEngine,
+		pendingTimeout: pendingTimeout,
+		variants:       variants,
+		imageLimits:    imageLimits,
+		typeGroups:     typeGroups,
+		asyncScan:      asyncScan,
+		tokenEngine:    tokenEngine,
 
 		fileDomain: fileDomain,
 
-		fileUploadPolicyRepo: fileUploadPolicyRepo,
-		fileInfoRepo:         fileRepo,
-		fileOwnershipRepo:    fileOwnerRepo,
-		fileStorageRepo:      fileStorageRepo,
+		fileUploadPolicyRepo:    fileUploadPolicyRepo,
+		fileInfoRepo:            fileRepo,
+		fileOwnershipRepo:       fileOwnerRepo,
+		fileReferenceRepo:       fileReferenceRepo,
+		fileVariantRepo:         fileVariantRepo,
+		fileOutboxRepo:          fileOutboxRepo,
+		fileStorageRepo:         fileStorageRepo,
+		resumableUploadRepo:     resumableUploadRepo,
+		fileTokenRevocationRepo: fileTokenRevocationRepo,
+
+		imageProcessor:  imageProcessor,
+		contentDetector: contentDetector,
+		contentScanner:  contentScanner,
+
+		storedFileScanner: &storedFileScanner{
+			fileDomain:      fileDomain,
+			fileInfoRepo:    fileRepo,
+			fileVariantRepo: fileVariantRepo,
+			fileOutboxRepo:  fileOutboxRepo,
+			fileStorageRepo: fileStorageRepo,
+			contentScanner:  contentScanner,
+		},
 	}
 }
 
@@ -64,12 +142,54 @@ func (usecase *FileUsecase) RegisterUpload(
 		return nil, xerror.Enrich(errordef.ErrForbidden, "insufficient scope")
 	}
 
-	policy := usecase.fileDomain.NewUploadPolicy(req.UserID, req.AllowedTypes, req.MaxSize)
+	allowedTypes, err := usecase.typeGroups.Expand(req.AllowedTypes)
+	if err != nil {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid allowed types: %s", err)
+	}
+
+	deniedTypes, err := usecase.typeGroups.Expand(req.DeniedTypes)
+	if err != nil {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid denied types: %s", err)
+	}
+
+	if err := domain.ValidatePurpose(req.Purpose); err != nil {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "%s", err)
+	}
+
+	if req.Direct && req.MaxSize > domain.MaxDirectUploadSize {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid,
+			"the max size of a direct upload can't exceed %d", domain.MaxDirectUploadSize)
+	}
+
+	// Patterns like image/* don't match SVG images without sanitization, but
+	// listing the type explicitly is a mistake.
+	if slices.Contains(allowedTypes, domain.SVGContentType) && !req.SanitizeSVG {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "%s is only allowed with svg sanitization", domain.SVGContentType)
+	}
+
+	imageLimits := domain.ImageLimits{
+		MaxWidth:      req.MaxWidth,
+		MaxHeight:     req.MaxHeight,
+		MaxMegapixels: req.MaxMegapixels,
+		MaxFrames:     req.MaxFrames,
+	}
+
+	policy := usecase.fileDomain.NewUploadPolicy(req.UserID, allowedTypes, deniedTypes, req.MaxSize, req.Direct,
+		req.StripMetadata, req.SanitizeSVG, imageLimits.Or(usecase.imageLimits), req.Purpose)
 	if err := usecase.fileUploadPolicyRepo.Save(ctx, policy); err != nil {
 		return nil, errordef.ErrServer.Hide(err, "failed-to-save-upload-policy")
 	}
 
-	return dto.NewRegisterUploadResponse(policy.Token), nil
+	if !req.Direct {
+		return dto.NewRegisterUploadResponse(policy.Token, nil), nil
+	}
+
+	presignedUpload, err := usecase.fileStorageRepo.PresignStagingUpload(ctx, policy)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-presign-staging-upload")
+	}
+
+	return dto.NewRegisterUploadResponse(policy.Token, presignedUpload), nil
 }
 
 func (usecase *FileUsecase) Upload(ctx context.Context, req *dto.UploadRequest) (*dto.UploadResponse, error) {
@@ -79,56 +199,199 @@ func (usecase *FileUsecase) Upload(ctx context.Context, req *dto.UploadRequest)
 		return nil, xerror.Enrich(errordef.ErrUnauthenticated, middleware.RequireAuthenticationMessage)
 	}
 
-	file, metadata, err := usecase.checkAndParseFile(ctx, req.File, req.UploadToken)
+	policy, err := usecase.loadUploadPolicy(ctx, req.UploadToken)
 	if err != nil {
 		return nil, err
 	}
 
-	fileHash, err := xcrypto.Sha256(file)
+	// The type of some formats can only be detected by reading the end of the
+	// content, so the content is staged before being checked.
+	return usecase.storeFile(ctx, req.File, policy)
+}
+
+// InstantUpload gives the ownership of an already stored file to the user
+// without receiving its content. If the file is not stored yet, the upload
+// token is kept so that the client can upload the content with it, and the
+// uploaded file must match the declared hash and size.
+func (usecase *FileUsecase) InstantUpload(ctx context.Context, req *dto.InstantUploadRequest) (*dto.UploadResponse, error) {
+	if xcontext.RequestSubjectID(ctx) == 0 {
+		return nil, xerror.Enrich(errordef.ErrUnauthenticated, middleware.RequireAuthenticationMessage)
+	}
+
+	if len(req.SHA256) != sha256.Size {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid sha256")
+	}
+
+	if req.Size <= 0 {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "require a positive size")
+	}
+
+	policy, err := usecase.loadUploadPolicy(ctx, req.UploadToken)
 	if err != nil {
-		return nil, errordef.ErrServer.Hide(err, "failed-to-hash-file")
+		return nil, err
 	}
 
-	fileInfo := usecase.fileDomain.NewFileInfo(base64.RawURLEncoding.EncodeToString(fileHash), metadata)
+	if req.Size > policy.MaxSize {
+		return nil, xerror.Enrich(errordef.ErrRequestTooLarge, "file too large (limit %d)", policy.MaxSize)
+	}
 
-	ctx = xcontext.WithDBTransaction(ctx)
-	if err := usecase.fileInfoRepo.Create(ctx, fileInfo); err == nil {
-		if _, err := file.Seek(0, io.SeekStart); err != nil {
-			ctx = xcontext.DBRollback(ctx)
-			return nil, errordef.ErrServer.Hide(err, "failed-to-seek-file")
+	// The stored content of the file would be the sanitized one, whose hash is
+	// unknown to the client.
+	if policy.StripMetadata || policy.SanitizeSVG {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "the upload token does not allow instant uploads")
+	}
+
+	fileID := base64.RawURLEncoding.EncodeToString(req.SHA256)
+	fileInfo, err := usecase.fileInfoRepo.GetByID(ctx, fileID)
+	if err == nil && fileInfo.Status != domain.FileStatusStored {
+		// Only stored files can be owned, the content must be uploaded in case
+		// the pending uploader fails.
+		err = errordef.ErrNotFound
+	}
+
+	if err != nil {
+		if !errors.Is(err, errordef.ErrNotFound) {
+			return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-info", "id", fileID)
 		}
 
-		if err := usecase.fileStorageRepo.Store(ctx, fileInfo, file); err != nil {
-			ctx = xcontext.DBRollback(ctx)
-			return nil, errordef.ErrServer.Hide(err, "failed-to-store-file")
+		policy.DeclaredFileID = fileID
+		policy.DeclaredSize = req.Size
+		if err := usecase.fileUploadPolicyRepo.Save(ctx, policy); err != nil {
+			return nil, errordef.ErrServer.Hide(err, "failed-to-save-upload-policy")
 		}
-	} else if !errors.Is(err, errordef.ErrDuplicated) {
-		ctx = xcontext.DBRollback(ctx)
-		return nil, errordef.ErrServer.Hide(err, "failed-to-create-file-info")
+
+		return nil, xerror.Enrich(errordef.ErrNotFound, "not found file, upload its content with the same token")
 	}
 
-	// The transaction of storing the file must be committed before storing the
-	// file's ownership.
-	//
-	// Why? It prevents the situation where the file is uploaded again, wasting
-	// the server's resources.
-	//
-	// Don't worry if no one uses this file; it will be deleted periodically
-	// by the janitor.
-	ctx = xcontext.DBCommit(ctx)
+	if int64(fileInfo.Metadata.Size) != req.Size {
+		return nil, xerror.Enrich(errordef.ErrFileMismatchedSize,
+			"mismatched file size (got %d, expected %d)", req.Size, fileInfo.Metadata.Size)
+	}
 
-	ownership := usecase.fileDomain.NewFileOwnership(fileInfo.ID, xcontext.RequestSubjectID(ctx))
-	if err := usecase.fileOwnershipRepo.Create(ctx, ownership); err != nil && !errors.Is(err, errordef.ErrDuplicated) {
-		return nil, errordef.ErrServer.Hide(err, "failed-to-create-file-owner-info")
+	if !policy.AllowsType(fileInfo.Metadata.Type) {
+		return nil, xerror.Enrich(errordef.ErrFileMismatchedType,
+			"mismachted uploaded file type (got %s, expected %s)", fileInfo.Metadata.Type, policy.AllowedTypes)
 	}
 
-	fileToken := usecase.fileDomain.NewFileToken(fileInfo, ownership)
-	fileTokenString, err := usecase.tokenEngine.Generate(ctx, dto.FileTokenFromDomain(fileToken))
+	if err := checkInstantImageLimits(fileInfo, policy.ImageLimits); err != nil {
+		return nil, err
+	}
+
+	return usecase.issueOwnership(ctx, fileInfo)
+}
+
+// checkInstantImageLimits checks the recorded metadata of a stored image
+// against the limits, since the image is not decoded again. The number of
+// frames is not recorded, so an animated image can't be checked against the
+// frame limits, nor an image without metadata against any limit.
+func checkInstantImageLimits(file *domain.FileInfo, limits domain.ImageLimits) error {
+	if !mime.IsImage(file.Metadata.Type) || file.Metadata.Type == domain.SVGContentType || limits.IsZero() {
+		return nil
+	}
+
+	image := file.Metadata.Image
+	if image == nil || (image.Format == "gif" && (limits.MaxFrames > 0 || limits.MaxTotalMegapixels > 0)) {
+		return xerror.Enrich(errordef.ErrRequestInvalid,
+			"the upload token does not allow instant uploads of %s images", file.Metadata.Type)
+	}
+
+	if err := limits.Check(image.Width, image.Height, 1); err != nil {
+		return xerror.Enrich(errordef.ErrFileInvalidContent, "invalid %s image: %s", file.Metadata.Type, err)
+	}
+
+	return nil
+}
+
+// FinalizeUpload checks the file which has been uploaded directly to the
+// storage, then stores it in the same way as Upload. The upload token is
+// consumed even if the check fails.
+func (usecase *FileUsecase) FinalizeUpload(ctx context.Context, req *dto.FinalizeUploadRequest) (*dto.UploadResponse, error) {
+	if xcontext.RequestSubjectID(ctx) == 0 {
+		return nil, xerror.Enrich(errordef.ErrUnauthenticated, middleware.RequireAuthenticationMessage)
+	}
+
+	policy, err := usecase.loadUploadPolicy(ctx, req.UploadToken)
 	if err != nil {
-		return nil, errordef.ErrServer.Hide(err, "failed-to-generate-file-token")
+		return nil, err
+	}
+
+	if policy.StagingKey == "" {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "the upload token does not allow direct uploads")
+	}
+
+	// The staged object is useless once the token is consumed, it is promoted
+	// or dropped here.
+	defer func() {
+		if err := usecase.fileStorageRepo.DeleteStaged(ctx, policy.StagingKey); err != nil {
+			xcontext.Logger(ctx).Warn("failed-to-delete-staged-object", "key", policy.StagingKey, "err", err)
+		}
+	}()
+
+	file, size, err := usecase.fileStorageRepo.OpenStaged(ctx, policy.StagingKey)
+	if err != nil {
+		if errors.Is(err, errordef.ErrNotFound) {
+			return nil, xerror.Enrich(errordef.ErrRequestInvalid, "not found the uploaded file")
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-open-staged-object")
+	}
+	defer file.Close()
+
+	if size > policy.MaxSize {
+		return nil, xerror.Enrich(errordef.ErrRequestTooLarge, "file too large (limit %d)", policy.MaxSize)
+	}
+
+	contentType, err := usecase.checkContentType(file, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := usecase.checkImageLimits(file, contentType, policy.ImageLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-seek-staged-object")
+	}
+
+	// Sanitizing changes the content, so it is staged again in the same way as
+	// Upload.
+	if policy.SanitizesContent(contentType) {
+		return usecase.storeFile(ctx, file, policy)
+	}
+
+	fileHash, err := xcrypto.Sha256(file)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-hash-file")
+	}
+
+	fileInfo := usecase.fileDomain.NewFileInfo(
+		base64.RawURLEncoding.EncodeToString(fileHash),
+		&domain.FileMetadata{
+			Bucket:  usecase.fileDomain.ClassifyBucket(contentType, int(size), policy.Purpose),
+			Type:    contentType,
+			Size:    int(size),
+			Image:   image,
+			Purpose: policy.Purpose,
+		},
+	)
+
+	if !policy.MatchesDeclaration(fileInfo) {
+		return nil, xerror.Enrich(errordef.ErrFileInvalidContent, "the file does not match the declared content")
 	}
 
-	return dto.NewUploadResponse(fileInfo.ID, fileInfo.Metadata.Bucket, ownership.ID, fileTokenString), nil
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-seek-staged-object")
+	}
+
+	if err := usecase.scanContent(ctx, file, fileInfo); err != nil {
+		return nil, err
+	}
+
+	return usecase.saveFile(ctx, fileInfo, func(ctx context.Context) error {
+		return usecase.fileStorageRepo.Promote(ctx, policy.StagingKey, fileInfo)
+	})
 }
 
 func (usecase *FileUsecase) RetrieveFileToken(
@@ -153,6 +416,10 @@ func (usecase *FileUsecase) RetrieveFileToken(
 		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-info")
 	}
 
+	if err := checkNotQuarantined(file); err != nil {
+		return nil, err
+	}
+
 	fileToken := usecase.fileDomain.NewFileToken(file, ownership)
 	fileTokenString, err := usecase.tokenEngine.Generate(ctx, dto.FileTokenFromDomain(fileToken))
 	if err != nil {
@@ -162,6 +429,234 @@ func (usecase *FileUsecase) RetrieveFileToken(
 	return dto.NewRetrieveFileTokenResponse(fileTokenString), nil
 }
 
+// GetFileInfo returns the metadata of the file to its owner, or to a service
+// which is allowed to presign any file.
+func (usecase *FileUsecase) GetFileInfo(ctx context.Context, req *dto.GetFileInfoRequest) (*dto.GetFileInfoResponse, error) {
+	ownership, err := usecase.fileOwnershipRepo.GetByID(ctx, req.OwnershipID)
+	if err != nil {
+		if errors.Is(err, errordef.ErrNotFound) {
+			return nil, xerror.Enrich(errordef.ErrForbidden, "not found file ownership")
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-ownership")
+	}
+
+	if !usecase.canReadOwnership(ctx, ownership) {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "the file is not owned by this user")
+	}
+
+	file, err := usecase.fileInfoRepo.GetByID(ctx, ownership.FileID)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-info")
+	}
+
+	return dto.NewGetFileInfoResponse(ownership, file), nil
+}
+
+// GetOwnership returns the ownership and the metadata of its file to the owner,
+// or to a service which is allowed to presign any file.
+func (usecase *FileUsecase) GetOwnership(ctx context.Context, req *dto.GetOwnershipRequest) (*dto.GetOwnershipResponse, error) {
+	ownership, err := usecase.fileOwnershipRepo.GetByID(ctx, req.OwnershipID)
+	if err != nil {
+		if errors.Is(err, errordef.ErrNotFound) {
+			return nil, xerror.Enrich(errordef.ErrForbidden, "not found file ownership")
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-ownership")
+	}
+
+	if !usecase.canReadOwnership(ctx, ownership) {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "the file is not owned by this user")
+	}
+
+	file, err := usecase.fileInfoRepo.GetByID(ctx, ownership.FileID)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-info")
+	}
+
+	return dto.NewGetOwnershipResponse(ownership, file), nil
+}
+
+// BatchGetOwnerships returns the ownerships in the order of the request. The
+// ownerships which don't exist or which the caller is not allowed to read are
+// omitted, so a single inaccessible attachment doesn't fail the whole list.
+func (usecase *FileUsecase) BatchGetOwnerships(
+	ctx context.Context,
+	req *dto.BatchGetOwnershipsRequest,
+) (*dto.BatchGetOwnershipsResponse, error) {
+	if len(req.OwnershipIDs) > maxBatchOwnerships {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "too many ownerships (limit %d)", maxBatchOwnerships)
+	}
+
+	ownerships, err := usecase.fileOwnershipRepo.GetByIDs(ctx, req.OwnershipIDs)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-ownerships")
+	}
+
+	ownershipByID := make(map[snowflake.ID]*domain.FileOwnership, len(ownerships))
+	fileIDs := make([]string, 0, len(ownerships))
+	for _, ownership := range ownerships {
+		if !usecase.canReadOwnership(ctx, ownership) {
+			continue
+		}
+
+		ownershipByID[ownership.ID] = ownership
+		fileIDs = append(fileIDs, ownership.FileID)
+	}
+
+	files, err := usecase.fileInfoRepo.GetByIDs(ctx, fileIDs)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-infos")
+	}
+
+	fileByID := make(map[string]*domain.FileInfo, len(files))
+	for _, file := range files {
+		fileByID[file.ID] = file
+	}
+
+	resp := &dto.BatchGetOwnershipsResponse{Ownerships: make([]*dto.OwnershipInfo, 0, len(ownershipByID))}
+	for _, id := range req.OwnershipIDs {
+		ownership, ok := ownershipByID[id]
+		if !ok {
+			continue
+		}
+
+		file, ok := fileByID[ownership.FileID]
+		if !ok {
+			continue
+		}
+
+		// Skip duplicated ids.
+		delete(ownershipByID, id)
+		resp.Ownerships = append(resp.Ownerships, dto.NewOwnershipInfo(ownership, file))
+	}
+
+	return resp, nil
+}
+
+func (usecase *FileUsecase) canReadOwnership(ctx context.Context, ownership *domain.FileOwnership) bool {
+	return ownership.UserID == xcontext.RequestSubjectID(ctx) ||
+		!scopedef.Eval(xcontext.Scope(ctx)).RequireAdmin(scopedef.AdminCreatePresignedFile).IsUnsatisfied()
+}
+
+func (usecase *FileUsecase) Download(ctx context.Context, req *dto.DownloadRequest) (*dto.DownloadResponse, error) {
+	ownership, err := usecase.fileOwnershipRepo.GetByID(ctx, req.OwnershipID)
+	if err != nil {
+		if errors.Is(err, errordef.ErrNotFound) {
+			return nil, xerror.Enrich(errordef.ErrForbidden, "not found file ownership")
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-ownership")
+	}
+
+	if ownership.UserID != xcontext.RequestSubjectID(ctx) {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "the file is not owned by this user")
+	}
+
+	return usecase.openFile(ctx, ownership.FileID, req.Variant)
+}
+
+// DownloadByFileToken allows anyone holding a valid file token to download the
+// file, even if they are not authenticated.
+func (usecase *FileUsecase) DownloadByFileToken(
+	ctx context.Context,
+	req *dto.DownloadByFileTokenRequest,
+) (*dto.DownloadResponse, error) {
+	fileToken, err := usecase.parseFileToken(ctx, req.FileToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ownership may have been deleted after the token was issued.
+	if _, err := usecase.fileOwnershipRepo.GetByID(ctx, fileToken.OwnershipID); err != nil {
+		if errors.Is(err, errordef.ErrNotFound) {
+			return nil, xerror.Enrich(errordef.ErrForbidden, "not found file ownership")
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-ownership")
+	}
+
+	return usecase.openFile(ctx, fileToken.FileID, req.Variant)
+}
+
+// VerifyFileToken lets a service check a file token it received and read its
+// claims instead of parsing the token itself. The token can be bound to the
+// user which the service expects, so a token issued for another user is
+// rejected.
+func (usecase *FileUsecase) VerifyFileToken(
+	ctx context.Context,
+	req *dto.VerifyFileTokenRequest,
+) (*dto.VerifyFileTokenResponse, error) {
+	if req.FileToken == "" {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "require file token")
+	}
+
+	fileToken, err := usecase.parseFileToken(ctx, req.FileToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ExpectedUserID != 0 && fileToken.UserID != req.ExpectedUserID {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "the file token is issued for another user")
+	}
+
+	// The ownership may have been deleted after the token was issued.
+	ownership, err := usecase.fileOwnershipRepo.GetByID(ctx, fileToken.OwnershipID)
+	if err != nil {
+		if errors.Is(err, errordef.ErrNotFound) {
+			return nil, xerror.Enrich(errordef.ErrForbidden, "not found file ownership")
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-ownership")
+	}
+
+	file, err := usecase.fileInfoRepo.GetByID(ctx, ownership.FileID)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-info")
+	}
+
+	if err := checkNotQuarantined(file); err != nil {
+		return nil, err
+	}
+
+	return dto.NewVerifyFileTokenResponse(fileToken, file), nil
+}
+
+// RevokeFileToken revokes a single file token, or all file tokens of an
+// ownership or of a user issued until now. The tokens issued later are not
+// affected.
+//
+// It requires the same admin scope as presigning any file on purpose: it is
+// held by the trusted backend services which act on the files of any user, and
+// a service which can read any file without a token is already trusted with
+// more than revoking tokens.
+func (usecase *FileUsecase) RevokeFileToken(
+	ctx context.Context,
+	req *dto.RevokeFileTokenRequest,
+) (*dto.RevokeFileTokenResponse, error) {
+	if scopedef.Eval(xcontext.Scope(ctx)).RequireAdmin(scopedef.AdminCreatePresignedFile).IsUnsatisfied() {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "insufficient scope")
+	}
+
+	n := 0
+	for _, id := range []snowflake.ID{req.TokenID, req.OwnershipID, req.UserID} {
+		if id != 0 {
+			n++
+		}
+	}
+
+	if n != 1 {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "require exactly one of token_id, ownership_id and user_id")
+	}
+
+	revocation := usecase.fileDomain.NewFileTokenRevocation(req.TokenID, req.OwnershipID, req.UserID)
+	if err := usecase.fileTokenRevocationRepo.Save(ctx, revocation); err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-save-file-token-revocation")
+	}
+
+	return dto.NewRevokeFileTokenResponse(revocation), nil
+}
+
 func (usecase *FileUsecase) CreatePresignedURL(
 	ctx context.Context,
 	req *dto.CreatePresignedURLRequest,
@@ -197,6 +692,24 @@ func (usecase *FileUsecase) CreatePresignedURL(
 		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file", "id", fileID)
 	}
 
+	if err := checkNotQuarantined(info); err != nil {
+		return nil, err
+	}
+
+	if req.Variant != "" {
+		variant, err := usecase.getVariant(ctx, info, req.Variant)
+		if err != nil {
+			return nil, err
+		}
+
+		presignedURL, err := usecase.fileStorageRepo.PresignVariant(ctx, variant, req.Expiration)
+		if err != nil {
+			return nil, errordef.ErrServer.Hide(err, "failed-to-generate-presigned-url")
+		}
+
+		return dto.NewCreatePresignedURLResponse(presignedURL), nil
+	}
+
 	presignedURL, err := usecase.fileStorageRepo.Presign(ctx, info, req.Expiration)
 	if err != nil {
 		return nil, errordef.ErrServer.Hide(err, "failed-to-generate-presigned-url")
@@ -205,6 +718,8 @@ func (usecase *FileUsecase) CreatePresignedURL(
 	return dto.NewCreatePresignedURLResponse(presignedURL), nil
 }
 
+// ChangeRefCount is kept for the services which have not moved to AddReference
+// and RemoveReference yet.
 func (usecase *FileUsecase) ChangeRefCount(
 	ctx context.Context,
 	req *dto.ChangeRefcountRequest,
@@ -216,15 +731,18 @@ func (usecase *FileUsecase) ChangeRefCount(
 	ctx = xcontext.WithDBTransaction(ctx)
 	defer xcontext.DBCommit(ctx)
 
+	// Each increment adds a legacy reference, so the refcount is still the
+	// number of references. A non-existing ownership is ignored.
 	for i := range req.IncOwnershipID {
-		if err := usecase.fileOwnershipRepo.ChangeRefCount(ctx, req.IncOwnershipID[i], 1); err != nil {
+		reference := usecase.fileDomain.NewLegacyFileReference(req.IncOwnershipID[i])
+		if _, err := usecase.addReference(ctx, reference); err != nil && !errors.Is(err, errordef.ErrNotFound) {
 			ctx = xcontext.DBRollback(ctx)
 			return nil, errordef.ErrServer.Hide(err, "failed-to-increase-ref-count", "oid", req.IncOwnershipID[i])
 		}
 	}
 
 	for i := range req.DecOwnershipID {
-		if err := usecase.fileOwnershipRepo.ChangeRefCount(ctx, req.DecOwnershipID[i], -1); err != nil {
+		if err := usecase.removeLegacyReference(ctx, req.DecOwnershipID[i]); err != nil {
 			ctx = xcontext.DBRollback(ctx)
 			return nil, errordef.ErrServer.Hide(err, "failed-to-decrease-ref-count", "oid", req.DecOwnershipID[i])
 		}
@@ -233,69 +751,502 @@ func (usecase *FileUsecase) ChangeRefCount(
 	return dto.NewChangeRefcountResponse(), nil
 }
 
-func (usecase *FileUsecase) checkAndParseFile(
-	ctx context.Context,
-	file *xhttp.File,
-	uploadToken string,
-) (io.ReadSeeker, *domain.FileMetadata, error) {
+// openFile opens the content of the file, or of its variant if variantName is
+// not empty.
+func (usecase *FileUsecase) openFile(ctx context.Context, fileID string, variantName string) (*dto.DownloadResponse, error) {
+	file, err := usecase.fileInfoRepo.GetByID(ctx, fileID)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-info", "id", fileID)
+	}
+
+	if err := checkNotQuarantined(file); err != nil {
+		return nil, err
+	}
+
+	if variantName != "" {
+		variant, err := usecase.getVariant(ctx, file, variantName)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := usecase.fileStorageRepo.OpenVariant(ctx, variant)
+		if err != nil {
+			return nil, errordef.ErrServer.Hide(err, "failed-to-open-variant", "id", fileID, "variant", variantName)
+		}
+
+		return dto.NewVariantDownloadResponse(variant, content), nil
+	}
+
+	content, err := usecase.fileStorageRepo.Open(ctx, file)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-open-file", "id", fileID)
+	}
+
+	return dto.NewDownloadResponse(file, content), nil
+}
+
+// parseFileToken validates the signature and the expiration of a file token,
+// and checks that it has not been revoked.
+func (usecase *FileUsecase) parseFileToken(ctx context.Context, fileTokenString string) (*domain.FileToken, error) {
+	claims := tokendef.FileToken{}
+	ok, err := usecase.tokenEngine.Validate(ctx, fileTokenString, &claims)
+	if err != nil || !ok {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "invalid file token")
+	}
+
+	fileToken := dto.FileTokenToDomain(&claims)
+	if time.Now().After(fileToken.ExpiresAt) {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "the file token has expired")
+	}
+
+	// The token is rejected if the revocations cannot be read, so a revoked
+	// token is never accepted.
+	revocations, err := usecase.fileTokenRevocationRepo.GetByToken(ctx, fileToken)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-token-revocations")
+	}
+
+	for _, revocation := range revocations {
+		if fileToken.IsRevokedBy(revocation) {
+			return nil, xerror.Enrich(errordef.ErrForbidden, "the file token has been revoked")
+		}
+	}
+
+	return fileToken, nil
+}
+
+// loadUploadPolicy consumes the upload token and returns the policy it stands
+// for. The policy must belong to the requesting user.
+func (usecase *FileUsecase) loadUploadPolicy(ctx context.Context, uploadToken string) (*domain.UploadPolicy, error) {
 	policy, err := usecase.fileUploadPolicyRepo.LoadAndDelete(ctx, uploadToken)
 	if err != nil {
 		if errors.Is(err, errordef.ErrNotFound) {
-			return nil, nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid token")
+			return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid token")
 		}
 
-		return nil, nil, err
+		return nil, err
 	}
 
 	if xcontext.RequestSubjectID(ctx) != policy.UserID {
-		return nil, nil, xerror.Enrich(errordef.ErrForbidden, "not allow the user to use this token")
+		return nil, xerror.Enrich(errordef.ErrForbidden, "not allow the user to use this token")
+	}
+
+	return policy, nil
+}
+
+// storeFile streams the content to a staging key while hashing it, checks the
+// staged object, then promotes it to the location of the file, or drops it if
+// the file has already been stored. The type is checked before staging if the
+// header is enough to detect it, the staged object is read again only when a
+// check needs the whole content.
+func (usecase *FileUsecase) storeFile(
+	ctx context.Context,
+	content io.Reader,
+	policy *domain.UploadPolicy,
+) (*dto.UploadResponse, error) {
+	// Read one more byte than allowed to know if the content is too large.
+	contentType, content, err := usecase.contentDetector.Peek(io.LimitReader(content, policy.MaxSize+1))
+	if err != nil {
+		if mberr := xhttp.AsMaxBytesError(err); mberr != nil {
+			return nil, xerror.Enrich(errordef.ErrRequestTooLarge, "file too large (limit %d)", policy.MaxSize)
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-read-file")
+	}
+
+	if contentType != "" {
+		if err := checkAllowedType(contentType, policy); err != nil {
+			return nil, err
+		}
+	}
+
+	key := usecase.fileDomain.NewStagingKey()
+	defer func() {
+		if err := usecase.fileStorageRepo.DeleteStaged(ctx, key); err != nil {
+			xcontext.Logger(ctx).Warn("failed-to-delete-staged-object", "key", key, "err", err)
+		}
+	}()
+
+	hash := sha256.New()
+	size, err := usecase.fileStorageRepo.Stage(ctx, key, io.TeeReader(content, hash), -1)
+	if err != nil {
+		if mberr := xhttp.AsMaxBytesError(err); mberr != nil {
+			return nil, xerror.Enrich(errordef.ErrRequestTooLarge, "file too large (limit %d)", policy.MaxSize)
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-stage-file")
+	}
+
+	if size > policy.MaxSize {
+		return nil, xerror.Enrich(errordef.ErrRequestTooLarge, "file too large (limit %d)", policy.MaxSize)
+	}
+
+	if contentType == "" {
+		contentType, err = usecase.checkStagedContentType(ctx, key, policy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	image, sanitized, err := usecase.checkStagedImage(ctx, key, contentType, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	// The original content is discarded, the file is identified by the
+	// sanitized content.
+	if sanitized != nil {
+		if err := usecase.fileStorageRepo.DeleteStaged(ctx, key); err != nil {
+			xcontext.Logger(ctx).Warn("failed-to-delete-staged-object", "key", key, "err", err)
+		}
+
+		key = usecase.fileDomain.NewStagingKey()
+		hash.Reset()
+		size, err = usecase.fileStorageRepo.Stage(ctx, key, io.TeeReader(bytes.NewReader(sanitized), hash), int64(len(sanitized)))
+		if err != nil {
+			return nil, errordef.ErrServer.Hide(err, "failed-to-stage-file")
+		}
+	}
+
+	fileInfo := usecase.fileDomain.NewFileInfo(
+		base64.RawURLEncoding.EncodeToString(hash.Sum(nil)),
+		&domain.FileMetadata{
+			Bucket:  usecase.fileDomain.ClassifyBucket(contentType, int(size), policy.Purpose),
+			Type:    contentType,
+			Size:    int(size),
+			Image:   image,
+			Purpose: policy.Purpose,
+		},
+	)
+
+	if !policy.MatchesDeclaration(fileInfo) {
+		return nil, xerror.Enrich(errordef.ErrFileInvalidContent, "the file does not match the declared content")
+	}
+
+	if sanitized != nil {
+		err = usecase.scanContent(ctx, bytes.NewReader(sanitized), fileInfo)
+	} else {
+		err = usecase.scanStaged(ctx, key, fileInfo)
 	}
 
-	contentType, err := usecase.checkContentType(file, policy.AllowedTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	return usecase.saveFile(ctx, fileInfo, func(ctx context.Context) error {
+		return usecase.fileStorageRepo.Promote(ctx, key, fileInfo)
+	})
+}
+
+// checkStagedContentType detects the type of the staged object, when its
+// header is not enough.
+func (usecase *FileUsecase) checkStagedContentType(
+	ctx context.Context,
+	key string,
+	policy *domain.UploadPolicy,
+) (string, error) {
+	content, _, err := usecase.fileStorageRepo.OpenStaged(ctx, key)
+	if err != nil {
+		return "", errordef.ErrServer.Hide(err, "failed-to-open-staged-object", "key", key)
+	}
+	defer content.Close()
+
+	return usecase.checkContentType(content, policy)
+}
+
+// checkStagedImage reads the staged object once to check the image limits and
+// to sanitize it if required by the policy. It returns the metadata of the
+// image, and the sanitized content or nil if the content is unchanged.
+func (usecase *FileUsecase) checkStagedImage(
+	ctx context.Context,
+	key string,
+	contentType string,
+	policy *domain.UploadPolicy,
+) (*domain.ImageMetadata, []byte, error) {
+	sanitizes := policy.SanitizesContent(contentType)
+	if !mime.IsImage(contentType) && !sanitizes {
+		return nil, nil, nil
+	}
+
+	content, _, err := usecase.fileStorageRepo.OpenStaged(ctx, key)
+	if err != nil {
+		return nil, nil, errordef.ErrServer.Hide(err, "failed-to-open-staged-object", "key", key)
+	}
+	defer content.Close()
+
+	// The content read while checking the limits is kept to be sanitized.
+	original := bytes.Buffer{}
+	reader := io.Reader(content)
+	if sanitizes {
+		reader = io.TeeReader(content, &original)
+	}
+
+	image, err := usecase.checkImageLimits(reader, contentType, policy.ImageLimits)
+	if err != nil || !sanitizes {
+		return image, nil, err
+	}
+
+	if _, err := original.ReadFrom(content); err != nil {
+		return nil, nil, errordef.ErrServer.Hide(err, "failed-to-read-staged-object", "key", key)
+	}
+
+	sanitized, err := usecase.sanitizeImage(&original, contentType)
+	if err != nil || sanitized == nil {
+		return image, nil, err
+	}
+
+	image, err = usecase.readImageMetadata(bytes.NewReader(sanitized), contentType)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	var fileContent io.ReadSeeker
-	file.SetMaxSize(policy.MaxSize)
-	if policy.MaxSize > usecase.maxInMemory {
-		fileContent, err = file.AsFile()
+	return image, sanitized, nil
+}
+
+// checkImageLimits rejects images which cannot be decoded or exceed the limits,
+// so that they never reach the decoders generating variants. It returns the
+// metadata of the image.
+func (usecase *FileUsecase) checkImageLimits(
+	content io.Reader,
+	contentType string,
+	limits domain.ImageLimits,
+) (*domain.ImageMetadata, error) {
+	if !mime.IsImage(contentType) {
+		return nil, nil
+	}
+
+	image, err := usecase.imageProcessor.CheckLimits(content, contentType, limits)
+	if err != nil {
+		return nil, xerror.Enrich(errordef.ErrFileInvalidContent, "invalid %s image: %s", contentType, err)
+	}
+
+	return image, nil
+}
+
+// sanitizeImage returns the SVG image without its active content, or the image
+// without its metadata. It returns nil if the format of the image is not
+// supported.
+func (usecase *FileUsecase) sanitizeImage(content io.Reader, contentType string) ([]byte, error) {
+	var sanitized []byte
+	var err error
+	if contentType == domain.SVGContentType {
+		sanitized, err = usecase.imageProcessor.SanitizeSVG(content)
 	} else {
-		fileContent, err = file.AsBytes()
+		sanitized, err = usecase.imageProcessor.StripMetadata(content, contentType)
 	}
 
 	if err != nil {
-		if mberr := xhttp.AsMaxBytesError(err); mberr != nil {
-			return nil, nil, xerror.Enrich(errordef.ErrRequestTooLarge, "file too large (limit %d)", policy.MaxSize)
+		return nil, xerror.Enrich(errordef.ErrFileInvalidContent, "invalid %s image", contentType)
+	}
+
+	return sanitized, nil
+}
+
+// readImageMetadata returns nil if the content is not an image or its format is
+// not supported. An image which cannot be decoded is rejected.
+func (usecase *FileUsecase) readImageMetadata(content io.Reader, contentType string) (*domain.ImageMetadata, error) {
+	if !mime.IsImage(contentType) {
+		return nil, nil
+	}
+
+	image, err := usecase.imageProcessor.DecodeMetadata(content, contentType)
+	if err != nil {
+		return nil, xerror.Enrich(errordef.ErrFileInvalidContent, "invalid %s image", contentType)
+	}
+
+	return image, nil
+}
+
+// saveFile creates the file info and calls store to put the content into the
+// storage if the file is not stored yet, then gives the ownership of the file
+// to the requesting user.
+//
+// The file info is created as pending before storing the content and marked as
+// stored after that. Concurrent uploaders of the same content wait for the
+// pending file, or store the content themselves if the pending uploader has
+// failed or stalled. So an ownership never points to a missing content.
+//
+// Don't worry if no one uses the stored file; it will be deleted periodically
+// by the janitor.
+func (usecase *FileUsecase) saveFile(
+	ctx context.Context,
+	fileInfo *domain.FileInfo,
+	store func(ctx context.Context) error,
+) (*dto.UploadResponse, error) {
+	delay := pendingPollMinDelay
+	for {
+		err := usecase.fileInfoRepo.Create(ctx, fileInfo)
+		if err == nil {
+			if err := usecase.storeContent(ctx, fileInfo, store); err != nil {
+				return nil, err
+			}
+
+			return usecase.issueOwnership(ctx, fileInfo)
+		}
+
+		if !errors.Is(err, errordef.ErrDuplicated) {
+			return nil, errordef.ErrServer.Hide(err, "failed-to-create-file-info")
+		}
+
+		existingFile, err := usecase.fileInfoRepo.GetByID(ctx, fileInfo.ID)
+		if err != nil {
+			if errors.Is(err, errordef.ErrNotFound) {
+				// The failed file has just been deleted by the janitor.
+				continue
+			}
+
+			return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-info", "id", fileInfo.ID)
+		}
+
+		if existingFile.Status == domain.FileStatusStored {
+			return usecase.issueOwnership(ctx, existingFile)
+		}
+
+		staleBefore := time.Now().Add(-usecase.pendingTimeout)
+		if existingFile.Status == domain.FileStatusFailed || existingFile.UpdatedAt.Before(staleBefore) {
+			err := usecase.fileInfoRepo.Claim(ctx, existingFile.ID, staleBefore)
+			if err == nil {
+				if err := usecase.storeContent(ctx, existingFile, store); err != nil {
+					return nil, err
+				}
+
+				return usecase.issueOwnership(ctx, existingFile)
+			}
+
+			// Another uploader has claimed the file first, wait for it.
+			if !errors.Is(err, errordef.ErrNotFound) {
+				return nil, errordef.ErrServer.Hide(err, "failed-to-claim-file", "id", fileInfo.ID)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errordef.ErrServer.Hide(ctx.Err(), "failed-to-wait-for-pending-file", "id", fileInfo.ID)
+		case <-time.After(delay):
 		}
 
-		return nil, nil, errordef.ErrServer.Hide(err, "failed-to-parse-file")
+		delay = min(2*delay, pendingPollMaxDelay)
+	}
+}
+
+// storeContent calls store for a file which has been claimed by this request,
+// then marks it as stored. If store fails, the file is marked as failed so the
+// next uploader can store it again.
+func (usecase *FileUsecase) storeContent(
+	ctx context.Context,
+	fileInfo *domain.FileInfo,
+	store func(ctx context.Context) error,
+) error {
+	if err := store(ctx); err != nil {
+		// If the file cannot be marked as failed, it will be considered stale
+		// after the pending timeout.
+		if err := usecase.fileInfoRepo.MarkFailed(ctx, fileInfo.ID); err != nil {
+			xcontext.Logger(ctx).Warn("failed-to-mark-file-as-failed", "fid", fileInfo.ID, "err", err)
+		}
+
+		return errordef.ErrServer.Hide(err, "failed-to-store-file")
+	}
+
+	ctx = xcontext.WithDBTransaction(ctx)
+	if err := usecase.fileInfoRepo.MarkStored(ctx, fileInfo.ID); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return errordef.ErrServer.Hide(err, "failed-to-mark-file-as-stored", "id", fileInfo.ID)
+	}
+
+	events := []*domain.FileEvent{usecase.fileDomain.NewFileStoredEvent(fileInfo)}
+	if fileInfo.IsQuarantined() {
+		events = append(events, usecase.fileDomain.NewFileQuarantinedEvent(fileInfo))
+	}
+
+	if err := usecase.fileOutboxRepo.Create(ctx, events...); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return errordef.ErrServer.Hide(err, "failed-to-create-file-event")
+	}
+
+	ctx = xcontext.DBCommit(ctx)
+
+	fileInfo.Status = domain.FileStatusStored
+
+	// The uploader waits neither for the variants nor for the asynchronous
+	// scan. The variants are generated first, so the scan deletes them if the
+	// file is quarantined.
+	go func(ctx context.Context) {
+		usecase.generateVariants(ctx, fileInfo)
+		usecase.scanStoredFile(ctx, fileInfo)
+	}(context.WithoutCancel(ctx))
+
+	return nil
+}
+
+// issueOwnership gives the ownership of a stored file to the requesting user
+// and returns a file token for it.
+func (usecase *FileUsecase) issueOwnership(ctx context.Context, fileInfo *domain.FileInfo) (*dto.UploadResponse, error) {
+	if fileInfo.IsQuarantined() {
+		return nil, xerror.Enrich(errordef.ErrFileInvalidContent,
+			"the file is quarantined because it contains %s", fileInfo.Threat)
+	}
+
+	ownership, err := usecase.createOrGetOwnership(ctx, fileInfo.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	fileToken := usecase.fileDomain.NewFileToken(fileInfo, ownership)
+	fileTokenString, err := usecase.tokenEngine.Generate(ctx, dto.FileTokenFromDomain(fileToken))
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-generate-file-token")
 	}
 
-	return fileContent, &domain.FileMetadata{
-		Bucket: usecase.fileDomain.ClassifyBucket(contentType),
-		Type:   contentType,
-		Size:   file.ContentLength(),
-	}, nil
+	return dto.NewUploadResponse(fileInfo, ownership.ID, fileTokenString), nil
 }
 
-func (usecase *FileUsecase) checkContentType(file *xhttp.File, allowedTypes []string) (string, error) {
-	nSniff := int64(512)
-	if mime.IsImage(allowedTypes...) {
-		// Although http.DetectContentType considers at most 512 bytes to detect
-		// the file type, detecting image types needs only first 12 bytes.
-		nSniff = 12
+// createOrGetOwnership gives the ownership of the file to the requesting user.
+// If the user has already owned the file, the existing ownership is returned.
+func (usecase *FileUsecase) createOrGetOwnership(ctx context.Context, fileID string) (*domain.FileOwnership, error) {
+	userID := xcontext.RequestSubjectID(ctx)
+
+	ownership := usecase.fileDomain.NewFileOwnership(fileID, userID)
+
+	ctx = xcontext.WithDBTransaction(ctx)
+	err := usecase.fileOwnershipRepo.Create(ctx, ownership)
+	if err == nil {
+		if err := usecase.fileOutboxRepo.Create(ctx, usecase.fileDomain.NewOwnershipCreatedEvent(ownership)); err != nil {
+			ctx = xcontext.DBRollback(ctx)
+			return nil, errordef.ErrServer.Hide(err, "failed-to-create-file-event")
+		}
+
+		ctx = xcontext.DBCommit(ctx)
+		return ownership, nil
+	}
+
+	ctx = xcontext.DBRollback(ctx)
+	if !errors.Is(err, errordef.ErrDuplicated) {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-create-file-owner-info")
 	}
 
-	detectedType, err := file.ContentType(nSniff)
+	ownership, err = usecase.fileOwnershipRepo.Get(ctx, fileID, userID)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-owner-info")
+	}
+
+	return ownership, nil
+}
+
+// checkContentType detects the type of the content, then rewinds it.
+func (usecase *FileUsecase) checkContentType(content io.ReadSeeker, policy *domain.UploadPolicy) (string, error) {
+	detectedType, err := usecase.contentDetector.Detect(content)
 	if err != nil {
 		return detectedType, errordef.ErrServer.Hide(err, "failed-to-detect-content-type")
 	}
 
-	if !slices.Contains(allowedTypes, detectedType) {
-		return detectedType, xerror.Enrich(errordef.ErrFileMismatchedType,
-			"mismachted uploaded file type (got %s, expected %s)", detectedType, allowedTypes)
+	return detectedType, checkAllowedType(detectedType, policy)
+}
+
+func checkAllowedType(contentType string, policy *domain.UploadPolicy) error {
+	if !policy.AllowsType(contentType) {
+		return xerror.Enrich(errordef.ErrFileMismatchedType,
+			"mismachted uploaded file type (got %s, expected %s)", contentType, policy.AllowedTypes)
 	}
 
-	return detectedType, nil
+	return nil
 }
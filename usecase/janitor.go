@@ -0,0 +1,300 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/todennus/file-service/usecase/abstraction"
+	"github.com/todennus/file-service/usecase/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/xcontext"
+	"github.com/xybor-x/snowflake"
+)
+
+// JanitorUsecase removes the files which are not owned by anyone, the
+// ownerships which are not referenced by anything, the temporary content of
+// expired uploads and the cached transformations. It also marks the files which
+// have been pending for too long as failed, and retries the asynchronous scans
+// which have not completed.
+//
+// It is safe to run several janitors at the same time: every record is only
+// deleted if it still satisfies the clean-up condition at the time of deletion,
+// and files are locked while their content is being removed.
+type JanitorUsecase struct {
+	gracePeriod          time.Duration
+	uploadExpiration     time.Duration
+	temporaryGracePeriod time.Duration
+	pendingTimeout       time.Duration
+	cacheTTL             time.Duration
+	batchSize            int
+
+	fileDomain abstraction.FileDomain
+
+	fileInfoRepo      abstraction.FileInfoRepository
+	fileOwnershipRepo abstraction.FileOwnershipRepository
+	fileVariantRepo   abstraction.FileVariantRepository
+	fileOutboxRepo    abstraction.FileOutboxRepository
+	fileStorageRepo   abstraction.FileStorageRepository
+
+	storedFileScanner *storedFileScanner
+}
+
+func NewJanitorUsecase(
+	gracePeriod time.Duration,
+	uploadExpiration time.Duration,
+	temporaryGracePeriod time.Duration,
+	pendingTimeout time.Duration,
+	cacheTTL time.Duration,
+	batchSize int,
+	fileDomain abstraction.FileDomain,
+	fileInfoRepo abstraction.FileInfoRepository,
+	fileOwnershipRepo abstraction.FileOwnershipRepository,
+	fileVariantRepo abstraction.FileVariantRepository,
+	fileOutboxRepo abstraction.FileOutboxRepository,
+	fileStorageRepo abstraction.FileStorageRepository,
+	contentScanner abstraction.ContentScanner,
+) *JanitorUsecase {
+	return &JanitorUsecase{
+		gracePeriod:          gracePeriod,
+		uploadExpiration:     uploadExpiration,
+		temporaryGracePeriod: temporaryGracePeriod,
+		pendingTimeout:       pendingTimeout,
+		cacheTTL:             cacheTTL,
+		batchSize:            batchSize,
+
+		fileDomain: fileDomain,
+
+		fileInfoRepo:      fileInfoRepo,
+		fileOwnershipRepo: fileOwnershipRepo,
+		fileVariantRepo:   fileVariantRepo,
+		fileOutboxRepo:    fileOutboxRepo,
+		fileStorageRepo:   fileStorageRepo,
+
+		storedFileScanner: &storedFileScanner{
+			fileDomain:      fileDomain,
+			fileInfoRepo:    fileInfoRepo,
+			fileVariantRepo: fileVariantRepo,
+			fileOutboxRepo:  fileOutboxRepo,
+			fileStorageRepo: fileStorageRepo,
+			contentScanner:  contentScanner,
+		},
+	}
+}
+
+// CleanUp is not exposed to any API, so it does not check the request scope.
+func (usecase *JanitorUsecase) CleanUp(ctx context.Context, req *dto.CleanUpRequest) (*dto.CleanUpResponse, error) {
+	before := time.Now().Add(-usecase.gracePeriod)
+
+	// The uploaders of stale files have crashed or stalled, failing them allows
+	// the files to be deleted if no one uploads them again.
+	failedStaleFiles, err := usecase.fileInfoRepo.FailStale(ctx, time.Now().Add(-usecase.pendingTimeout))
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-fail-stale-files")
+	}
+
+	// The uploader scans the file right after it is stored, so a scan which is
+	// still pending after the pending timeout has failed or been interrupted.
+	rescannedFiles, err := usecase.rescanPendingFiles(ctx, time.Now().Add(-usecase.pendingTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	// Ownerships are cleaned up first, so the files they held can be deleted in
+	// the same run.
+	deletedOwnerships, err := usecase.cleanUpOwnerships(ctx, before)
+	if err != nil {
+		return nil, err
+	}
+
+	deletedFiles, err := usecase.cleanUpFiles(ctx, before)
+	if err != nil {
+		return nil, err
+	}
+
+	// A temporary object is always stored before its upload policy expires,
+	// but it may still be checked or stored for a while after that. So only
+	// the objects older than the policy lifetime and the grace period belong
+	// to abandoned uploads.
+	deletedTemporaryObjects, err := usecase.fileStorageRepo.DeleteExpiredTemporaryObjects(
+		ctx, time.Now().Add(-usecase.uploadExpiration-usecase.temporaryGracePeriod))
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-delete-expired-temporary-objects")
+	}
+
+	// A transformation whose cached result is deleted is computed again on the
+	// next request, so it does not matter if the file is still used.
+	deletedCachedObjects, err := usecase.fileStorageRepo.DeleteExpiredCachedObjects(
+		ctx, time.Now().Add(-usecase.cacheTTL))
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-delete-expired-cached-objects")
+	}
+
+	return dto.NewCleanUpResponse(failedStaleFiles, rescannedFiles,
+		deletedOwnerships, deletedFiles, deletedTemporaryObjects, deletedCachedObjects), nil
+}
+
+func (usecase *JanitorUsecase) rescanPendingFiles(ctx context.Context, updatedBefore time.Time) (int, error) {
+	if usecase.storedFileScanner.contentScanner == nil {
+		// The scanner has been disabled since these files were stored.
+		return 0, nil
+	}
+
+	rescanned := 0
+	lastID := ""
+
+	for {
+		files, err := usecase.fileInfoRepo.GetPendingScans(ctx, updatedBefore, lastID, usecase.batchSize)
+		if err != nil {
+			return rescanned, errordef.ErrServer.Hide(err, "failed-to-get-pending-scans")
+		}
+
+		for _, file := range files {
+			if err := usecase.storedFileScanner.scan(ctx, file); err != nil {
+				xcontext.Logger(ctx).Warn("failed-to-rescan-file", "fid", file.ID, "err", err)
+			} else {
+				rescanned++
+			}
+		}
+
+		if len(files) < usecase.batchSize {
+			return rescanned, nil
+		}
+
+		lastID = files[len(files)-1].ID
+	}
+}
+
+func (usecase *JanitorUsecase) cleanUpOwnerships(ctx context.Context, updatedBefore time.Time) (int, error) {
+	deleted := 0
+	lastID := snowflake.ID(0)
+
+	for {
+		ownerships, err := usecase.fileOwnershipRepo.GetUnreferenced(ctx, updatedBefore, lastID, usecase.batchSize)
+		if err != nil {
+			return deleted, errordef.ErrServer.Hide(err, "failed-to-get-unreferenced-ownerships")
+		}
+
+		for _, ownership := range ownerships {
+			err := usecase.deleteUnreferencedOwnership(ctx, ownership, updatedBefore)
+			switch {
+			case err == nil:
+				deleted++
+			case errors.Is(err, errordef.ErrNotFound):
+				// It has been referenced again or deleted by another janitor.
+			default:
+				xcontext.Logger(ctx).Warn("failed-to-delete-ownership", "oid", ownership.ID, "err", err)
+			}
+		}
+
+		if len(ownerships) < usecase.batchSize {
+			return deleted, nil
+		}
+
+		lastID = ownerships[len(ownerships)-1].ID
+	}
+}
+
+func (usecase *JanitorUsecase) deleteUnreferencedOwnership(
+	ctx context.Context,
+	ownership *domain.FileOwnership,
+	updatedBefore time.Time,
+) error {
+	ctx = xcontext.WithDBTransaction(ctx)
+
+	if err := usecase.fileOwnershipRepo.DeleteUnreferenced(ctx, ownership.ID, updatedBefore); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return err
+	}
+
+	if err := usecase.fileOutboxRepo.Create(ctx, usecase.fileDomain.NewOwnershipDeletedEvent(ownership)); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return err
+	}
+
+	ctx = xcontext.DBCommit(ctx)
+	return nil
+}
+
+func (usecase *JanitorUsecase) cleanUpFiles(ctx context.Context, createdBefore time.Time) (int, error) {
+	deleted := 0
+	lastID := ""
+
+	for {
+		files, err := usecase.fileInfoRepo.GetOrphaned(ctx, createdBefore, lastID, usecase.batchSize)
+		if err != nil {
+			return deleted, errordef.ErrServer.Hide(err, "failed-to-get-orphaned-files")
+		}
+
+		for _, file := range files {
+			ok, err := usecase.deleteOrphanedFile(ctx, file.ID, createdBefore)
+			if err != nil {
+				xcontext.Logger(ctx).Warn("failed-to-delete-file", "fid", file.ID, "err", err)
+			} else if ok {
+				deleted++
+			}
+		}
+
+		if len(files) < usecase.batchSize {
+			return deleted, nil
+		}
+
+		lastID = files[len(files)-1].ID
+	}
+}
+
+func (usecase *JanitorUsecase) deleteOrphanedFile(ctx context.Context, fileID string, createdBefore time.Time) (bool, error) {
+	ctx = xcontext.WithDBTransaction(ctx)
+
+	// The file row is locked until the transaction ends. Meanwhile, any upload
+	// of the same content or any new ownership of this file must wait, so they
+	// never see a file row whose content has already been removed.
+	file, err := usecase.fileInfoRepo.LockOrphaned(ctx, fileID, createdBefore)
+	if err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		if errors.Is(err, errordef.ErrNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	variants, err := usecase.fileVariantRepo.GetByFile(ctx, file.ID)
+	if err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return false, err
+	}
+
+	if err := usecase.fileVariantRepo.DeleteByFile(ctx, file.ID); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return false, err
+	}
+
+	if err := usecase.fileInfoRepo.Delete(ctx, file.ID); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return false, err
+	}
+
+	if err := usecase.fileOutboxRepo.Create(ctx, usecase.fileDomain.NewFileDeletedEvent(file)); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return false, err
+	}
+
+	// The variants are removed before the original content, so a failed
+	// deletion is retried by the next run with all of them.
+	for _, variant := range variants {
+		if err := usecase.fileStorageRepo.DeleteVariant(ctx, variant); err != nil {
+			ctx = xcontext.DBRollback(ctx)
+			return false, err
+		}
+	}
+
+	if err := usecase.fileStorageRepo.Delete(ctx, file); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return false, err
+	}
+
+	ctx = xcontext.DBCommit(ctx)
+	return true, nil
+}
@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/todennus/file-service/usecase/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/xcontext"
+	"github.com/todennus/x/xerror"
+	"github.com/xybor-x/snowflake"
+)
+
+const (
+	defaultListOwnershipsLimit = 20
+	maxListOwnershipsLimit     = 100
+)
+
+// ListOwnerships returns the ownerships of the requesting user from the newest
+// to the oldest.
+func (usecase *FileUsecase) ListOwnerships(
+	ctx context.Context,
+	req *dto.ListOwnershipsRequest,
+) (*dto.ListOwnershipsResponse, error) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultListOwnershipsLimit
+	}
+
+	if limit < 0 || limit > maxListOwnershipsLimit {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid limit (max %d)", maxListOwnershipsLimit)
+	}
+
+	if req.Type != "" {
+		if err := domain.ValidateTypePattern(req.Type); err != nil {
+			return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid type: %s", err)
+		}
+	}
+
+	filter := domain.OwnershipFilter{
+		Type:          req.Type,
+		Bucket:        req.Bucket,
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+	}
+
+	ownerships, err := usecase.fileOwnershipRepo.ListByUser(
+		ctx, xcontext.RequestSubjectID(ctx), filter, req.Cursor, limit)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-list-file-ownerships")
+	}
+
+	fileIDs := make([]string, 0, len(ownerships))
+	for _, ownership := range ownerships {
+		fileIDs = append(fileIDs, ownership.FileID)
+	}
+
+	files, err := usecase.fileInfoRepo.GetByIDs(ctx, fileIDs)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-infos")
+	}
+
+	fileByID := make(map[string]*domain.FileInfo, len(files))
+	for _, file := range files {
+		fileByID[file.ID] = file
+	}
+
+	resp := &dto.ListOwnershipsResponse{Ownerships: make([]*dto.OwnershipInfo, 0, len(ownerships))}
+	for _, ownership := range ownerships {
+		if file, ok := fileByID[ownership.FileID]; ok {
+			resp.Ownerships = append(resp.Ownerships, dto.NewOwnershipInfo(ownership, file))
+		}
+	}
+
+	if len(ownerships) == limit {
+		resp.NextCursor = ownerships[len(ownerships)-1].ID
+	}
+
+	return resp, nil
+}
+
+// UpdateOwnership sets the display name and the description of an ownership of
+// the requesting user.
+func (usecase *FileUsecase) UpdateOwnership(
+	ctx context.Context,
+	req *dto.UpdateOwnershipRequest,
+) (*dto.UpdateOwnershipResponse, error) {
+	ownership, err := usecase.getOwnOwnership(ctx, req.OwnershipID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DisplayName != nil {
+		ownership.DisplayName = *req.DisplayName
+	}
+
+	if req.Description != nil {
+		ownership.Description = *req.Description
+	}
+
+	if err := domain.ValidateOwnershipDetails(ownership.DisplayName, ownership.Description); err != nil {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid ownership details: %s", err)
+	}
+
+	if err := usecase.fileOwnershipRepo.UpdateDetails(ctx, ownership); err != nil {
+		if errors.Is(err, errordef.ErrNotFound) {
+			return nil, xerror.Enrich(errordef.ErrForbidden, "not found file ownership")
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-update-file-ownership")
+	}
+
+	file, err := usecase.fileInfoRepo.GetByID(ctx, ownership.FileID)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-info")
+	}
+
+	return &dto.UpdateOwnershipResponse{Ownership: dto.NewOwnershipInfo(ownership, file)}, nil
+}
+
+// DeleteOwnership deletes an ownership of the requesting user. It is refused
+// while the ownership is referenced. The file itself is removed later by the
+// janitor if no other ownership holds it.
+func (usecase *FileUsecase) DeleteOwnership(
+	ctx context.Context,
+	req *dto.DeleteOwnershipRequest,
+) (*dto.DeleteOwnershipResponse, error) {
+	ownership, err := usecase.getOwnOwnership(ctx, req.OwnershipID)
+	if err != nil {
+		return nil, err
+	}
+
+	if ownership.RefCount > 0 {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid,
+			"the file is still referenced by %d entities", ownership.RefCount)
+	}
+
+	ctx = xcontext.WithDBTransaction(ctx)
+
+	// The ownership may have been referenced since it was read, the deletion
+	// only happens if it is still unreferenced.
+	if err := usecase.fileOwnershipRepo.DeleteUnreferenced(ctx, ownership.ID, time.Now()); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		if errors.Is(err, errordef.ErrNotFound) {
+			return nil, xerror.Enrich(errordef.ErrRequestInvalid, "the file is still referenced")
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-delete-file-ownership")
+	}
+
+	if err := usecase.fileOutboxRepo.Create(ctx, usecase.fileDomain.NewOwnershipDeletedEvent(ownership)); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return nil, errordef.ErrServer.Hide(err, "failed-to-create-file-event")
+	}
+
+	ctx = xcontext.DBCommit(ctx)
+
+	return &dto.DeleteOwnershipResponse{}, nil
+}
+
+// getOwnOwnership returns the ownership if it belongs to the requesting user.
+func (usecase *FileUsecase) getOwnOwnership(ctx context.Context, id snowflake.ID) (*domain.FileOwnership, error) {
+	ownership, err := usecase.fileOwnershipRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, errordef.ErrNotFound) {
+			return nil, xerror.Enrich(errordef.ErrForbidden, "not found file ownership")
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-ownership")
+	}
+
+	if ownership.UserID != xcontext.RequestSubjectID(ctx) {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "the file is not owned by this user")
+	}
+
+	return ownership, nil
+}
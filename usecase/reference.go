@@ -0,0 +1,198 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/todennus/file-service/usecase/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/scopedef"
+	"github.com/todennus/shared/xcontext"
+	"github.com/todennus/x/xerror"
+	"github.com/xybor-x/snowflake"
+)
+
+// AddReference records that an entity uses the file of the ownership. Adding
+// an existing reference does nothing, so the request can be retried safely.
+func (usecase *FileUsecase) AddReference(
+	ctx context.Context,
+	req *dto.AddReferenceRequest,
+) (*dto.AddReferenceResponse, error) {
+	if scopedef.Eval(xcontext.Scope(ctx)).RequireAdmin(scopedef.AdminChangeRefcountFileOwnership).IsUnsatisfied() {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "insufficient scope")
+	}
+
+	if err := validateReference(req.Service, req.EntityType, req.EntityID); err != nil {
+		return nil, err
+	}
+
+	reference := usecase.fileDomain.NewFileReference(req.OwnershipID, req.Service, req.EntityType, req.EntityID)
+
+	ctx = xcontext.WithDBTransaction(ctx)
+	ownership, err := usecase.addReference(ctx, reference)
+	if err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		if errors.Is(err, errordef.ErrNotFound) {
+			return nil, xerror.Enrich(errordef.ErrNotFound, "not found ownership %d", req.OwnershipID)
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-add-reference", "oid", req.OwnershipID)
+	}
+	ctx = xcontext.DBCommit(ctx)
+
+	return dto.NewAddReferenceResponse(ownership.RefCount), nil
+}
+
+// RemoveReference removes the reference of an entity to the file of the
+// ownership. Removing a non-existing reference does nothing, so the request can
+// be retried safely.
+func (usecase *FileUsecase) RemoveReference(
+	ctx context.Context,
+	req *dto.RemoveReferenceRequest,
+) (*dto.RemoveReferenceResponse, error) {
+	if scopedef.Eval(xcontext.Scope(ctx)).RequireAdmin(scopedef.AdminChangeRefcountFileOwnership).IsUnsatisfied() {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "insufficient scope")
+	}
+
+	if err := validateReference(req.Service, req.EntityType, req.EntityID); err != nil {
+		return nil, err
+	}
+
+	reference := usecase.fileDomain.NewFileReference(req.OwnershipID, req.Service, req.EntityType, req.EntityID)
+
+	ctx = xcontext.WithDBTransaction(ctx)
+	ownership, err := usecase.removeReference(ctx, reference)
+	if err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		if errors.Is(err, errordef.ErrNotFound) {
+			return nil, xerror.Enrich(errordef.ErrNotFound, "not found ownership %d", req.OwnershipID)
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-remove-reference", "oid", req.OwnershipID)
+	}
+	ctx = xcontext.DBCommit(ctx)
+
+	return dto.NewRemoveReferenceResponse(ownership.RefCount), nil
+}
+
+func (usecase *FileUsecase) ListReferences(
+	ctx context.Context,
+	req *dto.ListReferencesRequest,
+) (*dto.ListReferencesResponse, error) {
+	if scopedef.Eval(xcontext.Scope(ctx)).RequireAdmin(scopedef.AdminChangeRefcountFileOwnership).IsUnsatisfied() {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "insufficient scope")
+	}
+
+	if _, err := usecase.fileOwnershipRepo.GetByID(ctx, req.OwnershipID); err != nil {
+		if errors.Is(err, errordef.ErrNotFound) {
+			return nil, xerror.Enrich(errordef.ErrNotFound, "not found ownership %d", req.OwnershipID)
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-ownership", "oid", req.OwnershipID)
+	}
+
+	references, err := usecase.fileReferenceRepo.GetByOwnership(ctx, req.OwnershipID)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-references", "oid", req.OwnershipID)
+	}
+
+	return dto.NewListReferencesResponse(references), nil
+}
+
+// addReference creates the reference and increases the refcount of its
+// ownership in the current transaction, then returns the ownership. It returns
+// ErrNotFound if the ownership does not exist.
+func (usecase *FileUsecase) addReference(
+	ctx context.Context,
+	reference *domain.FileReference,
+) (*domain.FileOwnership, error) {
+	ownership, err := usecase.fileOwnershipRepo.GetByID(ctx, reference.OwnershipID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := usecase.fileReferenceRepo.Create(ctx, reference); err != nil {
+		if errors.Is(err, errordef.ErrDuplicated) {
+			return ownership, nil
+		}
+
+		return nil, err
+	}
+
+	return usecase.changeRefCount(ctx, reference, 1)
+}
+
+// removeReference deletes the reference and decreases the refcount of its
+// ownership in the current transaction, then returns the ownership. It returns
+// ErrNotFound if the ownership does not exist.
+func (usecase *FileUsecase) removeReference(
+	ctx context.Context,
+	reference *domain.FileReference,
+) (*domain.FileOwnership, error) {
+	ownership, err := usecase.fileOwnershipRepo.GetByID(ctx, reference.OwnershipID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := usecase.fileReferenceRepo.Delete(ctx, reference); err != nil {
+		if errors.Is(err, errordef.ErrNotFound) {
+			return ownership, nil
+		}
+
+		return nil, err
+	}
+
+	return usecase.changeRefCount(ctx, reference, -1)
+}
+
+// removeLegacyReference removes any legacy reference of the ownership. The
+// refcount is not decreased if there is none, so it never goes negative. The
+// refcounts which existed before the references are backfilled as legacy
+// references by the migration, so they can be decreased too.
+func (usecase *FileUsecase) removeLegacyReference(ctx context.Context, ownershipID snowflake.ID) error {
+	reference, err := usecase.fileReferenceRepo.DeleteAny(
+		ctx, ownershipID, domain.LegacyReferenceService, domain.LegacyReferenceEntityType)
+	if err != nil {
+		if errors.Is(err, errordef.ErrNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	_, err = usecase.changeRefCount(ctx, reference, -1)
+	return err
+}
+
+// changeRefCount changes the refcount of the ownership of the reference and
+// writes the event in the current transaction.
+func (usecase *FileUsecase) changeRefCount(
+	ctx context.Context,
+	reference *domain.FileReference,
+	change int,
+) (*domain.FileOwnership, error) {
+	ownership, err := usecase.fileOwnershipRepo.ChangeRefCount(ctx, reference.OwnershipID, change)
+	if err != nil {
+		return nil, err
+	}
+
+	event := usecase.fileDomain.NewOwnershipRefcountChangedEvent(ownership, reference, change)
+	if err := usecase.fileOutboxRepo.Create(ctx, event); err != nil {
+		return nil, err
+	}
+
+	return ownership, nil
+}
+
+func validateReference(service, entityType, entityID string) error {
+	if service == "" || entityType == "" || entityID == "" {
+		return xerror.Enrich(errordef.ErrRequestInvalid, "require service, entity_type and entity_id")
+	}
+
+	if service == domain.LegacyReferenceService {
+		return xerror.Enrich(errordef.ErrRequestInvalid, "service %s is reserved", service)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/todennus/file-service/usecase/abstraction"
+	"github.com/todennus/file-service/usecase/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/xcontext"
+	"github.com/xybor-x/snowflake"
+)
+
+// RelayUsecase publishes the events of the outbox to the event stream. An
+// event is deleted from the outbox only after it has been published, so it is
+// published at least once. The events of the same file are published in the
+// order they were committed.
+type RelayUsecase struct {
+	batchSize int
+
+	fileOutboxRepo      abstraction.FileOutboxRepository
+	fileEventStreamRepo abstraction.FileEventStreamRepository
+}
+
+func NewRelayUsecase(
+	batchSize int,
+	fileOutboxRepo abstraction.FileOutboxRepository,
+	fileEventStreamRepo abstraction.FileEventStreamRepository,
+) *RelayUsecase {
+	return &RelayUsecase{
+		batchSize: batchSize,
+
+		fileOutboxRepo:      fileOutboxRepo,
+		fileEventStreamRepo: fileEventStreamRepo,
+	}
+}
+
+// Relay publishes all events in the outbox. It is not exposed to any API, so it
+// does not check the request scope.
+func (usecase *RelayUsecase) Relay(ctx context.Context, req *dto.RelayRequest) (*dto.RelayResponse, error) {
+	published := 0
+	for {
+		n, err := usecase.relayBatch(ctx)
+		published += n
+		if err != nil {
+			return nil, err
+		}
+
+		if n < usecase.batchSize {
+			return dto.NewRelayResponse(published), nil
+		}
+	}
+}
+
+func (usecase *RelayUsecase) relayBatch(ctx context.Context) (int, error) {
+	ctx = xcontext.WithDBTransaction(ctx)
+
+	// The events are locked until they are deleted, other relays wait for them
+	// instead of publishing the next ones.
+	events, err := usecase.fileOutboxRepo.LockNext(ctx, usecase.batchSize)
+	if err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return 0, errordef.ErrServer.Hide(err, "failed-to-get-outbox-events")
+	}
+
+	if len(events) == 0 {
+		ctx = xcontext.DBRollback(ctx)
+		return 0, nil
+	}
+
+	if err := usecase.fileEventStreamRepo.Publish(ctx, events); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return 0, errordef.ErrServer.Hide(err, "failed-to-publish-events")
+	}
+
+	ids := make([]snowflake.ID, 0, len(events))
+	for i := range events {
+		ids = append(ids, events[i].ID)
+	}
+
+	// If the events cannot be deleted, they will be published again.
+	if err := usecase.fileOutboxRepo.Delete(ctx, ids); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return 0, errordef.ErrServer.Hide(err, "failed-to-delete-outbox-events")
+	}
+
+	ctx = xcontext.DBCommit(ctx)
+	return len(events), nil
+}
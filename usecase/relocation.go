@@ -0,0 +1,173 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/todennus/file-service/usecase/abstraction"
+	"github.com/todennus/file-service/usecase/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/xcontext"
+)
+
+// RelocationUsecase moves the stored files whose bucket doesn't match the
+// current bucket rules anymore, with their variants.
+//
+// The content is copied before the file info is updated and removed after, so
+// the file is always readable at the bucket in the database. The presigned URLs
+// created before the relocation of a file stop working.
+type RelocationUsecase struct {
+	batchSize int
+
+	fileDomain abstraction.FileDomain
+
+	fileInfoRepo    abstraction.FileInfoRepository
+	fileVariantRepo abstraction.FileVariantRepository
+	fileStorageRepo abstraction.FileStorageRepository
+}
+
+func NewRelocationUsecase(
+	batchSize int,
+	fileDomain abstraction.FileDomain,
+	fileInfoRepo abstraction.FileInfoRepository,
+	fileVariantRepo abstraction.FileVariantRepository,
+	fileStorageRepo abstraction.FileStorageRepository,
+) *RelocationUsecase {
+	return &RelocationUsecase{
+		batchSize: batchSize,
+
+		fileDomain: fileDomain,
+
+		fileInfoRepo:    fileInfoRepo,
+		fileVariantRepo: fileVariantRepo,
+		fileStorageRepo: fileStorageRepo,
+	}
+}
+
+// Relocate is not exposed to any API, so it does not check the request scope.
+func (usecase *RelocationUsecase) Relocate(ctx context.Context, req *dto.RelocateRequest) (*dto.RelocateResponse, error) {
+	resp := &dto.RelocateResponse{}
+	lastID := ""
+
+	for {
+		files, err := usecase.fileInfoRepo.GetStored(ctx, lastID, usecase.batchSize)
+		if err != nil {
+			return resp, errordef.ErrServer.Hide(err, "failed-to-get-stored-files")
+		}
+
+		for _, file := range files {
+			resp.Checked++
+
+			bucket := usecase.fileDomain.ClassifyBucket(file.Metadata.Type, file.Metadata.Size, file.Metadata.Purpose)
+			if bucket == file.Metadata.Bucket {
+				continue
+			}
+
+			if req.DryRun {
+				resp.Relocated++
+				continue
+			}
+
+			ok, err := usecase.relocateFile(ctx, file.ID, bucket)
+			switch {
+			case err != nil:
+				resp.Failed++
+				xcontext.Logger(ctx).Warn("failed-to-relocate-file", "fid", file.ID, "bucket", bucket, "err", err)
+			case ok:
+				resp.Relocated++
+			}
+		}
+
+		if len(files) < usecase.batchSize {
+			return resp, nil
+		}
+
+		lastID = files[len(files)-1].ID
+	}
+}
+
+// relocateFile returns false if the file has been deleted, quarantined or is
+// locked by another transaction, it is then checked again by the next run.
+func (usecase *RelocationUsecase) relocateFile(ctx context.Context, fileID string, bucket string) (bool, error) {
+	ctx = xcontext.WithDBTransaction(ctx)
+
+	// The file row is locked until the transaction ends, so the janitor cannot
+	// delete the file meanwhile.
+	file, err := usecase.fileInfoRepo.LockStored(ctx, fileID)
+	if err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		if errors.Is(err, errordef.ErrNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	variants, err := usecase.fileVariantRepo.GetByFile(ctx, file.ID)
+	if err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return false, err
+	}
+
+	metadata := *file.Metadata
+	metadata.Bucket = bucket
+	relocated := *file
+	relocated.Metadata = &metadata
+
+	relocatedVariants := make([]*domain.FileVariant, 0, len(variants))
+	for _, variant := range variants {
+		relocatedVariant := *variant
+		relocatedVariant.Bucket = bucket
+		relocatedVariants = append(relocatedVariants, &relocatedVariant)
+	}
+
+	// The copies are left behind if the relocation fails, they are overwritten
+	// by the next attempt.
+	if err := usecase.fileStorageRepo.Copy(ctx, file, &relocated); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return false, err
+	}
+
+	for i := range variants {
+		if err := usecase.fileStorageRepo.CopyVariant(ctx, variants[i], relocatedVariants[i]); err != nil {
+			ctx = xcontext.DBRollback(ctx)
+			return false, err
+		}
+	}
+
+	if err := usecase.fileInfoRepo.ChangeBucket(ctx, &relocated); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return false, err
+	}
+
+	if err := usecase.fileVariantRepo.ChangeBucket(ctx, &relocated); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return false, err
+	}
+
+	ctx = xcontext.DBCommit(ctx)
+
+	// The original content is only removed once the new bucket is saved.
+	saved, err := usecase.fileInfoRepo.GetByID(ctx, file.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if saved.Metadata.Bucket != bucket {
+		return false, errors.New("the new bucket has not been saved")
+	}
+
+	for _, variant := range variants {
+		if err := usecase.fileStorageRepo.DeleteVariant(ctx, variant); err != nil {
+			xcontext.Logger(ctx).Warn("failed-to-delete-relocated-variant",
+				"fid", file.ID, "variant", variant.Name, "err", err)
+		}
+	}
+
+	if err := usecase.fileStorageRepo.Delete(ctx, file); err != nil {
+		xcontext.Logger(ctx).Warn("failed-to-delete-relocated-file", "fid", file.ID, "err", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,218 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/todennus/file-service/usecase/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/middleware"
+	"github.com/todennus/shared/xcontext"
+	"github.com/todennus/x/xerror"
+)
+
+func (usecase *FileUsecase) CreateResumableUpload(
+	ctx context.Context,
+	req *dto.CreateResumableUploadRequest,
+) (*dto.CreateResumableUploadResponse, error) {
+	if xcontext.RequestSubjectID(ctx) == 0 {
+		return nil, xerror.Enrich(errordef.ErrUnauthenticated, middleware.RequireAuthenticationMessage)
+	}
+
+	if req.Length <= 0 {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "require a positive upload length")
+	}
+
+	policy, err := usecase.loadUploadPolicy(ctx, req.UploadToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Length > policy.MaxSize {
+		return nil, xerror.Enrich(errordef.ErrRequestTooLarge, "file too large (limit %d)", policy.MaxSize)
+	}
+
+	upload := usecase.fileDomain.NewResumableUpload(policy, req.Length)
+	if err := usecase.resumableUploadRepo.Save(ctx, upload); err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-save-resumable-upload")
+	}
+
+	return dto.NewCreateResumableUploadResponse(upload.ID, upload.Policy.ExpiresAt), nil
+}
+
+func (usecase *FileUsecase) GetResumableUpload(
+	ctx context.Context,
+	req *dto.GetResumableUploadRequest,
+) (*dto.GetResumableUploadResponse, error) {
+	upload, err := usecase.getResumableUpload(ctx, req.UploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	return dto.NewGetResumableUploadResponse(upload.Offset, upload.Length, upload.Policy.ExpiresAt), nil
+}
+
+func (usecase *FileUsecase) AppendResumableUpload(
+	ctx context.Context,
+	req *dto.AppendResumableUploadRequest,
+) (*dto.AppendResumableUploadResponse, error) {
+	upload, err := usecase.getResumableUpload(ctx, req.UploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Offset != upload.Offset {
+		return nil, xerror.Enrich(errordef.ErrFileMismatchedSize,
+			"mismatched upload offset (got %d, expected %d)", req.Offset, upload.Offset)
+	}
+
+	// A completed upload may be appended again with an empty content if the
+	// previous attempt failed to store the file.
+	if !upload.IsCompleted() {
+		if err := usecase.appendChunk(ctx, upload, req.Content, req.ContentLength); err != nil {
+			return nil, err
+		}
+	}
+
+	if !upload.IsCompleted() {
+		return dto.NewAppendResumableUploadResponse(upload.Offset, upload.Policy.ExpiresAt, nil), nil
+	}
+
+	resp, err := usecase.completeResumableUpload(ctx, upload)
+	if err != nil {
+		return nil, err
+	}
+
+	return dto.NewAppendResumableUploadResponse(upload.Offset, upload.Policy.ExpiresAt, resp), nil
+}
+
+func (usecase *FileUsecase) TerminateResumableUpload(
+	ctx context.Context,
+	req *dto.TerminateResumableUploadRequest,
+) (*dto.TerminateResumableUploadResponse, error) {
+	upload, err := usecase.getResumableUpload(ctx, req.UploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := usecase.resumableUploadRepo.Delete(ctx, upload.ID); err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-delete-resumable-upload")
+	}
+
+	// Chunks which cannot be deleted now will expire and be deleted by the
+	// janitor.
+	if err := usecase.fileStorageRepo.DeleteChunks(ctx, upload.Chunks); err != nil {
+		xcontext.Logger(ctx).Warn("failed-to-delete-chunks", "uid", upload.ID, "err", err)
+	}
+
+	return dto.NewTerminateResumableUploadResponse(), nil
+}
+
+func (usecase *FileUsecase) getResumableUpload(ctx context.Context, uploadID string) (*domain.ResumableUpload, error) {
+	if xcontext.RequestSubjectID(ctx) == 0 {
+		return nil, xerror.Enrich(errordef.ErrUnauthenticated, middleware.RequireAuthenticationMessage)
+	}
+
+	upload, err := usecase.resumableUploadRepo.Get(ctx, uploadID)
+	if err != nil {
+		if errors.Is(err, errordef.ErrNotFound) {
+			return nil, xerror.Enrich(errordef.ErrNotFound, "not found upload %s", uploadID)
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-resumable-upload")
+	}
+
+	if upload.Policy.UserID != xcontext.RequestSubjectID(ctx) {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "the upload is not owned by this user")
+	}
+
+	return upload, nil
+}
+
+func (usecase *FileUsecase) appendChunk(
+	ctx context.Context,
+	upload *domain.ResumableUpload,
+	content io.Reader,
+	contentLength int64,
+) error {
+	remaining := upload.Length - upload.Offset
+	if contentLength > remaining {
+		return xerror.Enrich(errordef.ErrRequestTooLarge, "chunk exceeds upload length (remaining %d)", remaining)
+	}
+
+	chunk := domain.ResumableUploadChunk{Name: usecase.fileDomain.NewResumableUploadChunkName(upload)}
+
+	// Read one more byte than remaining to know if the content exceeds the
+	// upload length.
+	size, err := usecase.fileStorageRepo.StoreChunk(ctx, chunk.Name, io.LimitReader(content, remaining+1), contentLength)
+	if err != nil {
+		return errordef.ErrServer.Hide(err, "failed-to-store-chunk")
+	}
+	chunk.Size = size
+
+	if chunk.Size == 0 || chunk.Size > remaining {
+		usecase.deleteChunk(ctx, chunk)
+		if chunk.Size > remaining {
+			return xerror.Enrich(errordef.ErrRequestTooLarge, "chunk exceeds upload length (remaining %d)", remaining)
+		}
+
+		return nil
+	}
+
+	offset := upload.Offset
+	upload.Append(chunk)
+
+	ok, err := usecase.resumableUploadRepo.SaveIfOffset(ctx, upload, offset)
+	if err != nil {
+		usecase.deleteChunk(ctx, chunk)
+		if errors.Is(err, errordef.ErrNotFound) {
+			return xerror.Enrich(errordef.ErrNotFound, "the upload has expired")
+		}
+
+		return errordef.ErrServer.Hide(err, "failed-to-save-resumable-upload")
+	}
+
+	if !ok {
+		usecase.deleteChunk(ctx, chunk)
+		return xerror.Enrich(errordef.ErrFileMismatchedSize, "the upload has been appended by another request")
+	}
+
+	return nil
+}
+
+// completeResumableUpload checks and stores the file in the same way as a
+// normal upload, then removes the upload and its chunks. The chunks are copied
+// to a staging key, so the file never depends on them.
+func (usecase *FileUsecase) completeResumableUpload(
+	ctx context.Context,
+	upload *domain.ResumableUpload,
+) (*dto.UploadResponse, error) {
+	content, err := usecase.fileStorageRepo.OpenChunks(ctx, upload.Chunks)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-open-chunks")
+	}
+	defer content.Close()
+
+	resp, err := usecase.storeFile(ctx, content, upload.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := usecase.resumableUploadRepo.Delete(ctx, upload.ID); err != nil {
+		xcontext.Logger(ctx).Warn("failed-to-delete-resumable-upload", "uid", upload.ID, "err", err)
+	}
+
+	if err := usecase.fileStorageRepo.DeleteChunks(ctx, upload.Chunks); err != nil {
+		xcontext.Logger(ctx).Warn("failed-to-delete-chunks", "uid", upload.ID, "err", err)
+	}
+
+	return resp, nil
+}
+
+func (usecase *FileUsecase) deleteChunk(ctx context.Context, chunk domain.ResumableUploadChunk) {
+	if err := usecase.fileStorageRepo.DeleteChunks(ctx, []domain.ResumableUploadChunk{chunk}); err != nil {
+		xcontext.Logger(ctx).Warn("failed-to-delete-chunk", "chunk", chunk.Name, "err", err)
+	}
+}
@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/todennus/file-service/usecase/abstraction"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/xcontext"
+	"github.com/todennus/x/xerror"
+)
+
+// storedFileScanner scans the content of the files after they are stored, if
+// the content is scanned asynchronously. It is shared by the uploads, which
+// scan every new file, and the janitor, which retries the scans which have not
+// completed.
+type storedFileScanner struct {
+	fileDomain abstraction.FileDomain
+
+	fileInfoRepo    abstraction.FileInfoRepository
+	fileVariantRepo abstraction.FileVariantRepository
+	fileOutboxRepo  abstraction.FileOutboxRepository
+	fileStorageRepo abstraction.FileStorageRepository
+
+	// contentScanner is nil if the content is not scanned.
+	contentScanner abstraction.ContentScanner
+}
+
+// scanStaged scans the staged content of the file before it is stored, if the
+// content is scanned synchronously. An infected file is quarantined, then
+// stored as usual but never owned.
+func (usecase *FileUsecase) scanStaged(ctx context.Context, key string, file *domain.FileInfo) error {
+	if usecase.contentScanner == nil || usecase.asyncScan {
+		usecase.deferScan(file)
+		return nil
+	}
+
+	content, _, err := usecase.fileStorageRepo.OpenStaged(ctx, key)
+	if err != nil {
+		return errordef.ErrServer.Hide(err, "failed-to-open-staged-object", "key", key)
+	}
+	defer content.Close()
+
+	return usecase.scanContent(ctx, content, file)
+}
+
+func (usecase *FileUsecase) scanContent(ctx context.Context, content io.Reader, file *domain.FileInfo) error {
+	if usecase.contentScanner == nil || usecase.asyncScan {
+		usecase.deferScan(file)
+		return nil
+	}
+
+	threat, err := usecase.contentScanner.Scan(ctx, content)
+	if err != nil {
+		return errordef.ErrServer.Hide(err, "failed-to-scan-file", "id", file.ID)
+	}
+
+	if threat != "" {
+		usecase.fileDomain.QuarantineFile(file, threat)
+	} else {
+		file.ScanStatus = domain.ScanStatusClean
+	}
+
+	return nil
+}
+
+// deferScan marks the scan of the file as pending if the content is scanned
+// after it is stored.
+func (usecase *FileUsecase) deferScan(file *domain.FileInfo) {
+	if usecase.contentScanner != nil && usecase.asyncScan {
+		file.ScanStatus = domain.ScanStatusPending
+	}
+}
+
+// scanStoredFile scans the content of the file after it is stored, if its scan
+// is pending. The uploader doesn't wait for it, so failures are only logged;
+// the scan is retried by the janitor.
+func (usecase *FileUsecase) scanStoredFile(ctx context.Context, file *domain.FileInfo) {
+	if file.ScanStatus != domain.ScanStatusPending {
+		return
+	}
+
+	if err := usecase.storedFileScanner.scan(ctx, file); err != nil {
+		xcontext.Logger(ctx).Warn("failed-to-scan-file", "fid", file.ID, "err", err)
+	}
+}
+
+// scan scans the content of a stored file whose scan is pending. An infected
+// file is moved to the quarantine bucket and its variants are deleted. The
+// scan stays pending if it fails.
+func (scanner *storedFileScanner) scan(ctx context.Context, file *domain.FileInfo) error {
+	if scanner.contentScanner == nil {
+		return errors.New("no scanner is configured")
+	}
+
+	threat, err := scanner.scanContent(ctx, file)
+	if err != nil {
+		return err
+	}
+
+	if threat == "" {
+		return scanner.fileInfoRepo.MarkClean(ctx, file.ID)
+	}
+
+	metadata := *file.Metadata
+	quarantined := *file
+	quarantined.Metadata = &metadata
+	scanner.fileDomain.QuarantineFile(&quarantined, threat)
+
+	// The content is moved first, so an infected content is never served even
+	// if the file info cannot be updated.
+	if err := scanner.fileStorageRepo.Move(ctx, file, &quarantined); err != nil {
+		return err
+	}
+
+	ctx = xcontext.WithDBTransaction(ctx)
+	if err := scanner.fileInfoRepo.Quarantine(ctx, &quarantined); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return err
+	}
+
+	if err := scanner.fileOutboxRepo.Create(ctx, scanner.fileDomain.NewFileQuarantinedEvent(&quarantined)); err != nil {
+		ctx = xcontext.DBRollback(ctx)
+		return err
+	}
+
+	ctx = xcontext.DBCommit(ctx)
+	xcontext.Logger(ctx).Warn("quarantined-infected-file", "fid", file.ID, "threat", threat)
+
+	scanner.deleteVariants(ctx, file)
+	return nil
+}
+
+func (scanner *storedFileScanner) scanContent(ctx context.Context, file *domain.FileInfo) (string, error) {
+	content, err := scanner.fileStorageRepo.Open(ctx, file)
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+
+	return scanner.contentScanner.Scan(ctx, content)
+}
+
+// deleteVariants deletes the variants of a quarantined file. It is
+// best-effort, failures are only logged.
+func (scanner *storedFileScanner) deleteVariants(ctx context.Context, file *domain.FileInfo) {
+	variants, err := scanner.fileVariantRepo.GetByFile(ctx, file.ID)
+	if err != nil {
+		xcontext.Logger(ctx).Warn("failed-to-get-variants", "fid", file.ID, "err", err)
+		return
+	}
+
+	if err := scanner.fileVariantRepo.DeleteByFile(ctx, file.ID); err != nil {
+		xcontext.Logger(ctx).Warn("failed-to-delete-variants", "fid", file.ID, "err", err)
+		return
+	}
+
+	for _, variant := range variants {
+		if err := scanner.fileStorageRepo.DeleteVariant(ctx, variant); err != nil {
+			xcontext.Logger(ctx).Warn("failed-to-delete-variant", "fid", file.ID, "variant", variant.Name, "err", err)
+		}
+	}
+}
+
+// checkNotQuarantined refuses to serve an infected file.
+func checkNotQuarantined(file *domain.FileInfo) error {
+	if file.IsQuarantined() {
+		return xerror.Enrich(errordef.ErrForbidden, "the file is quarantined because it contains %s", file.Threat)
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/todennus/file-service/usecase/abstraction"
+	"github.com/todennus/file-service/usecase/dto"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/scopedef"
+	"github.com/todennus/shared/xcontext"
+	"github.com/todennus/x/xerror"
+)
+
+// TransformUsecase serves images transformed on demand. The options of a
+// transformation are signed by this service, so clients can only request the
+// transformations which have been issued to them by a trusted service.
+type TransformUsecase struct {
+	// secret signs the transformation options. Transformations are disabled
+	// if it is empty.
+	secret []byte
+
+	fileInfoRepo      abstraction.FileInfoRepository
+	fileOwnershipRepo abstraction.FileOwnershipRepository
+	fileStorageRepo   abstraction.FileStorageRepository
+	imageProcessor    abstraction.ImageProcessor
+}
+
+func NewTransformUsecase(
+	secret string,
+	fileInfoRepo abstraction.FileInfoRepository,
+	fileOwnershipRepo abstraction.FileOwnershipRepository,
+	fileStorageRepo abstraction.FileStorageRepository,
+	imageProcessor abstraction.ImageProcessor,
+) *TransformUsecase {
+	return &TransformUsecase{
+		secret: []byte(secret),
+
+		fileInfoRepo:      fileInfoRepo,
+		fileOwnershipRepo: fileOwnershipRepo,
+		fileStorageRepo:   fileStorageRepo,
+		imageProcessor:    imageProcessor,
+	}
+}
+
+// CreateTransformURL signs the transformation of a file for a trusted service,
+// which then hands the URL to its clients.
+func (usecase *TransformUsecase) CreateTransformURL(
+	ctx context.Context,
+	req *dto.CreateTransformURLRequest,
+) (*dto.CreateTransformURLResponse, error) {
+	if scopedef.Eval(xcontext.Scope(ctx)).RequireAdmin(scopedef.AdminCreatePresignedFile).IsUnsatisfied() {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "insufficient scope")
+	}
+
+	if len(usecase.secret) == 0 {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "image transformation is disabled")
+	}
+
+	if req.FileID != "" && req.OwnershipID != 0 {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "not allow providing both file_id and ownership_id")
+	}
+
+	transform, err := domain.ParseImageTransform(req.Options)
+	if err != nil {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid options: %s", err.Error())
+	}
+
+	fileID := req.FileID
+	if fileID == "" {
+		ownership, err := usecase.fileOwnershipRepo.GetByID(ctx, req.OwnershipID)
+		if err != nil {
+			if errors.Is(err, errordef.ErrNotFound) {
+				return nil, xerror.Enrich(errordef.ErrNotFound, "not found ownership %d", req.OwnershipID)
+			}
+
+			return nil, errordef.ErrServer.Hide(err, "failed-to-get-file-ownership", "id", req.OwnershipID)
+		}
+
+		fileID = ownership.FileID
+	}
+
+	file, err := usecase.getTransformableFile(ctx, fileID, transform)
+	if err != nil {
+		return nil, err
+	}
+
+	options := transform.String()
+	path := fmt.Sprintf("/files/transform/%s/%s/%s", usecase.sign(options, file.ID), options, file.ID)
+	return dto.NewCreateTransformURLResponse(path), nil
+}
+
+// Transform returns the transformed image. The result is cached in the storage,
+// so a transformation is usually computed only once.
+func (usecase *TransformUsecase) Transform(ctx context.Context, req *dto.TransformRequest) (*dto.DownloadResponse, error) {
+	if len(usecase.secret) == 0 {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "image transformation is disabled")
+	}
+
+	if !hmac.Equal([]byte(req.Signature), []byte(usecase.sign(req.Options, req.FileID))) {
+		return nil, xerror.Enrich(errordef.ErrForbidden, "invalid signature")
+	}
+
+	transform, err := domain.ParseImageTransform(req.Options)
+	if err != nil {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "invalid options: %s", err.Error())
+	}
+
+	file, err := usecase.getTransformableFile(ctx, req.FileID, transform)
+	if err != nil {
+		return nil, err
+	}
+
+	key := transform.CacheKey(file.ID)
+	contentType := "image/" + transform.OutputFormat(file.Metadata.Type)
+
+	cached, size, err := usecase.fileStorageRepo.OpenCached(ctx, key)
+	if err == nil {
+		return dto.NewTransformResponse(file, key, contentType, int(size), cached), nil
+	}
+
+	if !errors.Is(err, errordef.ErrNotFound) {
+		xcontext.Logger(ctx).Warn("failed-to-open-cached-object", "key", key, "err", err)
+	}
+
+	original, err := usecase.fileStorageRepo.Open(ctx, file)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-open-file", "id", file.ID)
+	}
+	defer original.Close()
+
+	result, err := usecase.imageProcessor.Transform(original, file.Metadata.Type, transform)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-transform-image", "id", file.ID)
+	}
+
+	if result == nil {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "the file is not a supported image")
+	}
+
+	err = usecase.fileStorageRepo.StoreCached(
+		ctx, key, bytes.NewReader(result.Content), int64(len(result.Content)), result.Type)
+	if err != nil {
+		xcontext.Logger(ctx).Warn("failed-to-store-cached-object", "key", key, "err", err)
+	}
+
+	content := nopReadSeekCloser{bytes.NewReader(result.Content)}
+	return dto.NewTransformResponse(file, key, result.Type, len(result.Content), content), nil
+}
+
+// getTransformableFile returns the stored image which the transformation can be
+// applied to.
+func (usecase *TransformUsecase) getTransformableFile(
+	ctx context.Context,
+	fileID string,
+	transform *domain.ImageTransform,
+) (*domain.FileInfo, error) {
+	file, err := usecase.fileInfoRepo.GetByID(ctx, fileID)
+	if err != nil {
+		if errors.Is(err, errordef.ErrNotFound) {
+			return nil, xerror.Enrich(errordef.ErrNotFound, "not found file %s", fileID)
+		}
+
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-file", "id", fileID)
+	}
+
+	if file.Status != domain.FileStatusStored {
+		return nil, xerror.Enrich(errordef.ErrNotFound, "not found file %s", fileID)
+	}
+
+	if err := checkNotQuarantined(file); err != nil {
+		return nil, err
+	}
+
+	if file.Metadata.Image == nil {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "the file is not a supported image")
+	}
+
+	if transform.Crop != nil {
+		bounds := image.Rect(0, 0, file.Metadata.Image.Width, file.Metadata.Image.Height)
+		crop := image.Rect(0, 0, transform.Crop.Width, transform.Crop.Height).
+			Add(image.Pt(transform.Crop.X, transform.Crop.Y))
+		if !crop.In(bounds) {
+			return nil, xerror.Enrich(errordef.ErrRequestInvalid, "the crop area is outside of the image")
+		}
+	}
+
+	return file, nil
+}
+
+func (usecase *TransformUsecase) sign(options string, fileID string) string {
+	mac := hmac.New(sha256.New, usecase.secret)
+	mac.Write([]byte(options + "/" + fileID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// nopReadSeekCloser serves an in-memory content as a downloaded file.
+type nopReadSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopReadSeekCloser) Close() error {
+	return nil
+}
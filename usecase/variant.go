@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/todennus/file-service/domain"
+	"github.com/todennus/shared/errordef"
+	"github.com/todennus/shared/xcontext"
+	"github.com/todennus/x/xerror"
+)
+
+// generateVariants creates all variants of a newly stored image, the original
+// is decoded only once. It runs after the upload has returned, a variant which
+// fails or is not created yet is created when it is first requested.
+func (usecase *FileUsecase) generateVariants(ctx context.Context, file *domain.FileInfo) {
+	if file.Metadata.Image == nil || file.IsQuarantined() || len(usecase.variants) == 0 {
+		return
+	}
+
+	transforms := make([]*domain.ImageTransform, 0, len(usecase.variants))
+	for _, spec := range usecase.variants {
+		transforms = append(transforms, spec.Transform())
+	}
+
+	images, err := usecase.transformStored(ctx, file, transforms)
+	if err != nil {
+		xcontext.Logger(ctx).Warn("failed-to-create-variants", "fid", file.ID, "err", err)
+		return
+	}
+
+	for i, spec := range usecase.variants {
+		if _, err := usecase.storeVariant(ctx, file, spec, images[i]); err != nil {
+			xcontext.Logger(ctx).Warn("failed-to-create-variant", "fid", file.ID, "variant", spec.Name, "err", err)
+		}
+	}
+}
+
+func (usecase *FileUsecase) transformStored(
+	ctx context.Context,
+	file *domain.FileInfo,
+	transforms []*domain.ImageTransform,
+) ([]*domain.EncodedImage, error) {
+	content, err := usecase.fileStorageRepo.Open(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	images, err := usecase.imageProcessor.TransformAll(content, file.Metadata.Type, transforms)
+	if err != nil {
+		return nil, err
+	}
+
+	if images == nil {
+		return nil, errors.New("unsupported image format")
+	}
+
+	return images, nil
+}
+
+// getVariant returns the variant of the file, it is created if it does not
+// exist yet (e.g. the variant has been added after the image was uploaded).
+func (usecase *FileUsecase) getVariant(ctx context.Context, file *domain.FileInfo, name string) (*domain.FileVariant, error) {
+	spec, ok := usecase.variantSpec(name)
+	if !ok {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "unknown variant %s", name)
+	}
+
+	if file.Metadata.Image == nil {
+		return nil, xerror.Enrich(errordef.ErrRequestInvalid, "the file has no variant")
+	}
+
+	variant, err := usecase.fileVariantRepo.Get(ctx, file.ID, name)
+	if err == nil {
+		return variant, nil
+	}
+
+	if !errors.Is(err, errordef.ErrNotFound) {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-get-variant", "fid", file.ID, "variant", name)
+	}
+
+	variant, err = usecase.createVariant(ctx, file, spec)
+	if err != nil {
+		return nil, errordef.ErrServer.Hide(err, "failed-to-create-variant", "fid", file.ID, "variant", name)
+	}
+
+	return variant, nil
+}
+
+// createVariant resizes the original image and stores the result.
+func (usecase *FileUsecase) createVariant(
+	ctx context.Context,
+	file *domain.FileInfo,
+	spec domain.VariantSpec,
+) (*domain.FileVariant, error) {
+	images, err := usecase.transformStored(ctx, file, []*domain.ImageTransform{spec.Transform()})
+	if err != nil {
+		return nil, err
+	}
+
+	return usecase.storeVariant(ctx, file, spec, images[0])
+}
+
+// storeVariant stores the resized image as the variant. If the variant is
+// created concurrently by another request, both store the same content and the
+// existing variant is returned.
+func (usecase *FileUsecase) storeVariant(
+	ctx context.Context,
+	file *domain.FileInfo,
+	spec domain.VariantSpec,
+	image *domain.EncodedImage,
+) (*domain.FileVariant, error) {
+	variant := usecase.fileDomain.NewFileVariant(file, spec.Name, image)
+	if err := usecase.fileStorageRepo.StoreVariant(ctx, variant, bytes.NewReader(image.Content)); err != nil {
+		return nil, err
+	}
+
+	if err := usecase.fileVariantRepo.Create(ctx, variant); err != nil {
+		if errors.Is(err, errordef.ErrDuplicated) {
+			return usecase.fileVariantRepo.Get(ctx, file.ID, spec.Name)
+		}
+
+		return nil, err
+	}
+
+	return variant, nil
+}
+
+func (usecase *FileUsecase) variantSpec(name string) (domain.VariantSpec, bool) {
+	for _, spec := range usecase.variants {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+
+	return domain.VariantSpec{}, false
+}
@@ -0,0 +1,168 @@
+package wiring
+
+import (
+	"github.com/joho/godotenv"
+	"github.com/kelseyhightower/envconfig"
+)
+
+// ServiceConfig contains the variables which are only meaningful to the file
+// service. The common variables (server, postgres, redis, minio, ...) are still
+// loaded by the shared todennus config.
+type ServiceConfig struct {
+	Storage StorageConfig `envconfig:"file_storage"`
+	Image   ImageConfig   `envconfig:"file_image"`
+	Types   TypesConfig   `envconfig:"file_types"`
+	Scanner ScannerConfig `envconfig:"file_scanner"`
+	Janitor JanitorConfig `envconfig:"file_janitor"`
+	Outbox  OutboxConfig  `envconfig:"file_outbox"`
+}
+
+const (
+	StorageBackendMinio = "minio"
+	StorageBackendLocal = "local"
+)
+
+type StorageConfig struct {
+	// Backend is where the file content is stored, minio or local. The local
+	// backend keeps the files on disk and should only be used for development
+	// and testing.
+	Backend string `envconfig:"backend" default:"minio"`
+
+	// LocalRoot is the directory of the local backend.
+	LocalRoot string `envconfig:"local_root" default:"data"`
+
+	// LocalURL is the public URL where the REST server serves the presigned
+	// URLs of the local backend. The server mounts the handler at its path.
+	LocalURL string `envconfig:"local_url" default:"http://localhost:8081/storage"`
+
+	// LocalSecret is the key signing the presigned URLs of the local backend.
+	LocalSecret string `envconfig:"local_secret"`
+
+	// TemporaryBucket is where the uploaded content is kept until it is
+	// checked (the chunks of resumable uploads, the content uploaded directly
+	// to the storage, ...). It may contain a folder path (e.g. files/tmp).
+	TemporaryBucket string `envconfig:"temporary_bucket" default:"files/tmp"`
+
+	// CacheBucket is where the results of image transformations are cached.
+	// It may contain a folder path too.
+	CacheBucket string `envconfig:"cache_bucket" default:"files/cache"`
+
+	// QuarantineBucket is where the infected files are moved to. It may
+	// contain a folder path too.
+	QuarantineBucket string `envconfig:"quarantine_bucket" default:"files/quarantine"`
+
+	// BucketRules route the files to other buckets than the image and other
+	// ones, in the form pattern:size:purpose:bucket (e.g. video/*:*:*:videos).
+	// The first matching rule wins. Run the relocate command to move the
+	// stored files after changing the rules.
+	BucketRules []string `envconfig:"bucket_rules"`
+
+	// PendingTimeout is the number of seconds a file can be pending (its
+	// content is being stored) before it is considered failed, then another
+	// uploader is allowed to store it again.
+	PendingTimeout int `envconfig:"pending_timeout" default:"300"`
+}
+
+type ImageConfig struct {
+	// Variants are generated for every stored image, in the form
+	// name:size:mode where mode is fit or fill.
+	Variants []string `envconfig:"variants" default:"thumb:64:fill,small:256:fit,large:1024:fit"`
+
+	// TransformSecret is the key signing the options of on-demand image
+	// transformations.
+	TransformSecret string `envconfig:"transform_secret"`
+
+	// TransformCacheTTL is the number of seconds a transformed image is kept
+	// in the cache. The janitor deletes the older ones.
+	TransformCacheTTL int `envconfig:"transform_cache_ttl" default:"604800"`
+
+	// The default limits of uploaded images, used when the upload policy
+	// doesn't set them. Zero means no limit.
+	MaxWidth      int     `envconfig:"max_width" default:"16384"`
+	MaxHeight     int     `envconfig:"max_height" default:"16384"`
+	MaxMegapixels float64 `envconfig:"max_megapixels" default:"100"`
+	MaxFrames     int     `envconfig:"max_frames" default:"500"`
+
+	// MaxTotalMegapixels bounds the pixels of all frames of an animated
+	// image together.
+	MaxTotalMegapixels float64 `envconfig:"max_total_megapixels" default:"1000"`
+}
+
+type TypesConfig struct {
+	// Groups are the type groups which the upload policies refer to as
+	// @name, in the form name:pattern... where the patterns are separated by
+	// spaces.
+	Groups []string `envconfig:"groups" default:"images:image/*,documents:application/pdf text/plain text/markdown text/csv application/rtf application/msword application/vnd.ms-excel application/vnd.ms-powerpoint application/vnd.openxmlformats-officedocument.wordprocessingml.document application/vnd.openxmlformats-officedocument.spreadsheetml.sheet application/vnd.openxmlformats-officedocument.presentationml.presentation application/vnd.oasis.opendocument.text application/vnd.oasis.opendocument.spreadsheet application/vnd.oasis.opendocument.presentation application/epub+zip,archives:application/zip application/x-tar application/x-gzip application/x-bzip2 application/x-xz application/x-7z-compressed application/zstd application/x-rar-compressed"`
+}
+
+const (
+	ScannerBackendNone   = "none"
+	ScannerBackendClamAV = "clamav"
+	ScannerBackendFake   = "fake"
+)
+
+type ScannerConfig struct {
+	// Backend is the malware scanner, none, clamav or fake. The fake scanner
+	// only detects the EICAR test file and should only be used for development
+	// and testing.
+	Backend string `envconfig:"backend" default:"none"`
+
+	// Async scans the content after it is stored, the infected files are then
+	// moved to the quarantine bucket. Otherwise, the upload waits for the scan
+	// and fails if the file is infected.
+	Async bool `envconfig:"async" default:"false"`
+
+	// ClamAVAddress is the address of clamd, host:port or unix:/path/to/socket.
+	ClamAVAddress string `envconfig:"clamav_address" default:"localhost:3310"`
+
+	// Timeout is the number of seconds a scan can take.
+	Timeout int `envconfig:"timeout" default:"60"`
+}
+
+type JanitorConfig struct {
+	// Interval is the number of seconds between two clean-up passes.
+	Interval int `envconfig:"interval" default:"3600"`
+
+	// GracePeriod is the number of seconds an unused file or an unreferenced
+	// ownership is kept before being deleted.
+	GracePeriod int `envconfig:"grace_period" default:"86400"`
+
+	// TemporaryGracePeriod is the number of seconds the temporary content of
+	// an upload is kept after its upload token has expired, so the content
+	// which is still being checked or stored is not deleted.
+	TemporaryGracePeriod int `envconfig:"temporary_grace_period" default:"3600"`
+
+	// BatchSize is the maximum number of records handled in one transaction.
+	BatchSize int `envconfig:"batch_size" default:"100"`
+}
+
+type OutboxConfig struct {
+	// Stream is the Redis stream which the file events are published to.
+	Stream string `envconfig:"stream" default:"file:events"`
+
+	// MaxLen is the approximate number of events kept in the stream.
+	MaxLen int64 `envconfig:"max_len" default:"100000"`
+
+	// Interval is the number of milliseconds between two relay passes.
+	Interval int `envconfig:"interval" default:"1000"`
+
+	// BatchSize is the maximum number of events published at once.
+	BatchSize int `envconfig:"batch_size" default:"100"`
+}
+
+func LoadServiceConfig(paths ...string) (*ServiceConfig, error) {
+	if len(paths) > 0 {
+		// Variables which are already set in the environment take precedence
+		// over the ones in env files.
+		if err := godotenv.Load(paths...); err != nil {
+			return nil, err
+		}
+	}
+
+	serviceConfig := &ServiceConfig{}
+	if err := envconfig.Process("", serviceConfig); err != nil {
+		return nil, err
+	}
+
+	return serviceConfig, nil
+}
@@ -13,15 +13,22 @@ type Domains struct {
 	abstraction.FileDomain
 }
 
-func InitializeDomains(ctx context.Context, config *config.Config) (*Domains, error) {
+func InitializeDomains(ctx context.Context, config *config.Config, serviceConfig *ServiceConfig) (*Domains, error) {
 	domains := &Domains{}
 
+	bucketRules, err := domain.ParseBucketRules(serviceConfig.Storage.BucketRules)
+	if err != nil {
+		return nil, err
+	}
+
 	domains.FileDomain = domain.NewFileDomain(
 		config.SnowflakeNode,
 		time.Duration(config.Variable.File.TokenExpiration)*time.Second,
 		time.Duration(config.Variable.File.UploadTokenExpiration)*time.Second,
 		config.Variable.File.StorageImageBucket,
 		config.Variable.File.StorageOtherBucket,
+		serviceConfig.Storage.QuarantineBucket,
+		bucketRules,
 	)
 
 	return domains, nil
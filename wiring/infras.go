@@ -2,10 +2,17 @@ package wiring
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/redis/go-redis/v9"
+	"github.com/todennus/file-service/infras/detector"
+	"github.com/todennus/file-service/infras/imaging"
+	"github.com/todennus/file-service/infras/scanner"
+	"github.com/todennus/file-service/infras/storage"
+	"github.com/todennus/file-service/usecase/abstraction"
 	"github.com/todennus/migration/postgres"
 	"github.com/todennus/shared/config"
 	"gorm.io/gorm"
@@ -15,9 +22,16 @@ type Infras struct {
 	GormPostgres *gorm.DB
 	Redis        *redis.Client
 	Minio        *minio.Client
+	LocalStorage *storage.LocalStorage
+
+	ImageProcessor  *imaging.ImageProcessor
+	ContentDetector *detector.Registry
+
+	// ContentScanner is nil if the content is not scanned.
+	ContentScanner abstraction.ContentScanner
 }
 
-func InitializeInfras(ctx context.Context, config *config.Config) (*Infras, error) {
+func InitializeInfras(ctx context.Context, config *config.Config, serviceConfig *ServiceConfig) (*Infras, error) {
 	infras := Infras{}
 	var err error
 
@@ -33,12 +47,44 @@ func InitializeInfras(ctx context.Context, config *config.Config) (*Infras, erro
 		Password: config.Secret.Redis.Password,
 	})
 
-	minioOpts := minio.Options{
-		Creds: credentials.NewStaticV4(config.Secret.Minio.AccessKey, config.Secret.Minio.SecretKey, ""),
+	switch serviceConfig.Storage.Backend {
+	case StorageBackendMinio:
+		minioOpts := minio.Options{
+			Creds: credentials.NewStaticV4(config.Secret.Minio.AccessKey, config.Secret.Minio.SecretKey, ""),
+		}
+
+		if infras.Minio, err = minio.New(config.Variable.Minio.Endpoint, &minioOpts); err != nil {
+			return nil, err
+		}
+
+	case StorageBackendLocal:
+		infras.LocalStorage, err = storage.NewLocalStorage(
+			serviceConfig.Storage.LocalRoot,
+			serviceConfig.Storage.LocalURL,
+			serviceConfig.Storage.LocalSecret,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", serviceConfig.Storage.Backend)
 	}
 
-	if infras.Minio, err = minio.New(config.Variable.Minio.Endpoint, &minioOpts); err != nil {
-		return nil, err
+	infras.ImageProcessor = imaging.NewImageProcessor()
+	infras.ContentDetector = detector.NewDefaultRegistry()
+
+	switch serviceConfig.Scanner.Backend {
+	case ScannerBackendNone:
+	case ScannerBackendClamAV:
+		infras.ContentScanner = scanner.NewClamAVScanner(
+			serviceConfig.Scanner.ClamAVAddress,
+			time.Duration(serviceConfig.Scanner.Timeout)*time.Second,
+		)
+	case ScannerBackendFake:
+		infras.ContentScanner = scanner.NewFakeScanner()
+	default:
+		return nil, fmt.Errorf("unknown scanner backend %q", serviceConfig.Scanner.Backend)
 	}
 
 	return &infras, nil
@@ -14,16 +14,40 @@ type Repositories struct {
 	abstraction.FileUploadPolicyRepository
 	abstraction.FileInfoRepository
 	abstraction.FileOwnershipRepository
+	abstraction.FileReferenceRepository
+	abstraction.FileVariantRepository
+	abstraction.FileOutboxRepository
+	abstraction.FileEventStreamRepository
 	abstraction.FileStorageRepository
+	abstraction.ResumableUploadRepository
+	abstraction.FileTokenRevocationRepository
 }
 
-func InitializeRepositories(ctx context.Context, config *config.Config, infras *Infras) (*Repositories, error) {
+func InitializeRepositories(
+	ctx context.Context,
+	config *config.Config,
+	serviceConfig *ServiceConfig,
+	infras *Infras,
+) (*Repositories, error) {
 	r := &Repositories{}
 
 	r.FileUploadPolicyRepository = redis.NewFilePolicyRepository(infras.Redis)
 	r.FileInfoRepository = postgres.NewFileInfoRepository(infras.GormPostgres)
 	r.FileOwnershipRepository = postgres.NewFileOwnershipRepository(infras.GormPostgres)
-	r.FileStorageRepository = storage.NewFileStorageRepository(infras.Minio)
+	r.FileReferenceRepository = postgres.NewFileReferenceRepository(infras.GormPostgres)
+	r.FileVariantRepository = postgres.NewFileVariantRepository(infras.GormPostgres)
+	r.FileOutboxRepository = postgres.NewFileOutboxRepository(infras.GormPostgres)
+	r.FileEventStreamRepository = redis.NewFileEventStreamRepository(
+		infras.Redis, serviceConfig.Outbox.Stream, serviceConfig.Outbox.MaxLen)
+	if infras.LocalStorage != nil {
+		r.FileStorageRepository = storage.NewLocalFileStorageRepository(
+			infras.LocalStorage, serviceConfig.Storage.TemporaryBucket, serviceConfig.Storage.CacheBucket)
+	} else {
+		r.FileStorageRepository = storage.NewFileStorageRepository(
+			infras.Minio, serviceConfig.Storage.TemporaryBucket, serviceConfig.Storage.CacheBucket)
+	}
+	r.ResumableUploadRepository = redis.NewResumableUploadRepository(infras.Redis)
+	r.FileTokenRevocationRepository = redis.NewFileTokenRevocationRepository(infras.Redis)
 
 	return r, nil
 }
@@ -8,11 +8,12 @@ import (
 )
 
 type System struct {
-	Config       *config.Config
-	Domains      *Domains
-	Infras       *Infras
-	Repositories *Repositories
-	Usecases     *Usecases
+	Config        *config.Config
+	ServiceConfig *ServiceConfig
+	Domains       *Domains
+	Infras        *Infras
+	Repositories  *Repositories
+	Usecases      *Usecases
 }
 
 func InitializeSystem(paths ...string) (*System, error) {
@@ -21,34 +22,40 @@ func InitializeSystem(paths ...string) (*System, error) {
 		return nil, fmt.Errorf("failed to load variable and secrets, err=%w", err)
 	}
 
+	serviceConfig, err := LoadServiceConfig(sources(paths)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load service variables, err=%w", err)
+	}
+
 	ctx := context.Background()
 
-	domains, err := InitializeDomains(ctx, config)
+	domains, err := InitializeDomains(ctx, config, serviceConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize domains, err=%w", err)
 	}
 
-	infras, err := InitializeInfras(ctx, config)
+	infras, err := InitializeInfras(ctx, config, serviceConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize infras, err=%w", err)
 	}
 
-	repositories, err := InitializeRepositories(ctx, config, infras)
+	repositories, err := InitializeRepositories(ctx, config, serviceConfig, infras)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize repositories, err=%w", err)
 	}
 
-	usecases, err := InitializeUsecases(ctx, config, infras, domains, repositories)
+	usecases, err := InitializeUsecases(ctx, config, serviceConfig, infras, domains, repositories)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize usecases, err=%w", err)
 	}
 
 	return &System{
-		Config:       config,
-		Infras:       infras,
-		Repositories: repositories,
-		Domains:      domains,
-		Usecases:     usecases,
+		Config:        config,
+		ServiceConfig: serviceConfig,
+		Infras:        infras,
+		Repositories:  repositories,
+		Domains:       domains,
+		Usecases:      usecases,
 	}, nil
 }
 
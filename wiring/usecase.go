@@ -2,34 +2,107 @@ package wiring
 
 import (
 	"context"
+	"time"
 
 	"github.com/todennus/file-service/adapter/abstraction"
+	"github.com/todennus/file-service/domain"
 	"github.com/todennus/file-service/usecase"
 	"github.com/todennus/shared/config"
 )
 
 type Usecases struct {
 	abstraction.FileUsecase
+	abstraction.TransformUsecase
+	abstraction.JanitorUsecase
+	abstraction.RelocationUsecase
+	abstraction.RelayUsecase
 }
 
 func InitializeUsecases(
 	ctx context.Context,
 	config *config.Config,
+	serviceConfig *ServiceConfig,
 	infras *Infras,
 	domains *Domains,
 	repositories *Repositories,
 ) (*Usecases, error) {
 	uc := &Usecases{}
 
+	variants, err := domain.ParseVariantSpecs(serviceConfig.Image.Variants)
+	if err != nil {
+		return nil, err
+	}
+
+	typeGroups, err := domain.ParseTypeGroups(serviceConfig.Types.Groups)
+	if err != nil {
+		return nil, err
+	}
+
 	uc.FileUsecase = usecase.NewFileUsecase(
-		config.Variable.File.MaxInMemory,
+		time.Duration(serviceConfig.Storage.PendingTimeout)*time.Second,
+		variants,
+		domain.ImageLimits{
+			MaxWidth:           serviceConfig.Image.MaxWidth,
+			MaxHeight:          serviceConfig.Image.MaxHeight,
+			MaxMegapixels:      serviceConfig.Image.MaxMegapixels,
+			MaxFrames:          serviceConfig.Image.MaxFrames,
+			MaxTotalMegapixels: serviceConfig.Image.MaxTotalMegapixels,
+		},
+		typeGroups,
+		serviceConfig.Scanner.Async,
 		config.TokenEngine,
 		domains.FileDomain,
 		repositories.FileUploadPolicyRepository,
 		repositories.FileInfoRepository,
 		repositories.FileOwnershipRepository,
+		repositories.FileReferenceRepository,
+		repositories.FileVariantRepository,
+		repositories.FileOutboxRepository,
+		repositories.FileStorageRepository,
+		repositories.ResumableUploadRepository,
+		repositories.FileTokenRevocationRepository,
+		infras.ImageProcessor,
+		infras.ContentDetector,
+		infras.ContentScanner,
+	)
+
+	uc.TransformUsecase = usecase.NewTransformUsecase(
+		serviceConfig.Image.TransformSecret,
+		repositories.FileInfoRepository,
+		repositories.FileOwnershipRepository,
+		repositories.FileStorageRepository,
+		infras.ImageProcessor,
+	)
+
+	uc.JanitorUsecase = usecase.NewJanitorUsecase(
+		time.Duration(serviceConfig.Janitor.GracePeriod)*time.Second,
+		time.Duration(config.Variable.File.UploadTokenExpiration)*time.Second,
+		time.Duration(serviceConfig.Janitor.TemporaryGracePeriod)*time.Second,
+		time.Duration(serviceConfig.Storage.PendingTimeout)*time.Second,
+		time.Duration(serviceConfig.Image.TransformCacheTTL)*time.Second,
+		serviceConfig.Janitor.BatchSize,
+		domains.FileDomain,
+		repositories.FileInfoRepository,
+		repositories.FileOwnershipRepository,
+		repositories.FileVariantRepository,
+		repositories.FileOutboxRepository,
+		repositories.FileStorageRepository,
+		infras.ContentScanner,
+	)
+
+	uc.RelocationUsecase = usecase.NewRelocationUsecase(
+		serviceConfig.Janitor.BatchSize,
+		domains.FileDomain,
+		repositories.FileInfoRepository,
+		repositories.FileVariantRepository,
 		repositories.FileStorageRepository,
 	)
 
+	uc.RelayUsecase = usecase.NewRelayUsecase(
+		serviceConfig.Outbox.BatchSize,
+		repositories.FileOutboxRepository,
+		repositories.FileEventStreamRepository,
+	)
+
 	return uc, nil
 }